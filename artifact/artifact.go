@@ -0,0 +1,30 @@
+// Package artifact stores the files produced by the actions of the workflows
+package artifact
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+)
+
+// ErrNotFound is returned when the requested blob does not exist
+var ErrNotFound = errors.New("artifact not found")
+
+var name = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9_.-]*$`)
+
+// Store persists the content of the artifacts, identified by a key
+type Store interface {
+	// Put stores the content read from r under key, replacing any previous
+	// content, and returns the number of bytes written
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	// Get returns the content stored under key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the content stored under key
+	Delete(ctx context.Context, key string) error
+}
+
+// IsValidName checks if name can be used as the name of an artifact
+func IsValidName(n string) bool {
+	return name.MatchString(n) && len(n) <= 200
+}
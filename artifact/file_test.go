@@ -0,0 +1,54 @@
+package artifact
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	s, err := NewFileStore(dir)
+	assert.NoError(t, err)
+
+	const key = "5a6d7564-d699-4e9f-a29c-a5890ccbd768/inventory.json"
+	n, err := s.Put(ctx, key, strings.NewReader(`{"cpus": 2}`))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), n)
+
+	r, err := s.Get(ctx, key)
+	assert.NoError(t, err)
+	data, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	r.Close()
+	assert.Equal(t, `{"cpus": 2}`, string(data))
+
+	assert.NoError(t, s.Delete(ctx, key))
+	_, err = s.Get(ctx, key)
+	assert.Equal(t, ErrNotFound, err)
+	assert.NoError(t, s.Delete(ctx, key))
+
+	for _, key := range []string{"", "../escape", "wf/../../escape", "/absolute", "wf//name"} {
+		_, err = s.Put(ctx, key, strings.NewReader("data"))
+		assert.Error(t, err, key)
+	}
+}
+
+func TestIsValidName(t *testing.T) {
+	assert.True(t, IsValidName("inventory.json"))
+	assert.True(t, IsValidName("smart_sda-1.log"))
+	assert.False(t, IsValidName(""))
+	assert.False(t, IsValidName(".."))
+	assert.False(t, IsValidName(".hidden"))
+	assert.False(t, IsValidName("dir/file"))
+}
@@ -9,8 +9,10 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/tinkerbell/tink/protos/hardware"
+	"github.com/tinkerbell/tink/protos/secret"
 	"github.com/tinkerbell/tink/protos/template"
 	"github.com/tinkerbell/tink/protos/workflow"
+	"github.com/tinkerbell/tink/tracing"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 )
@@ -20,6 +22,7 @@ var (
 	TemplateClient template.TemplateServiceClient
 	WorkflowClient workflow.WorkflowServiceClient
 	HardwareClient hardware.HardwareServiceClient
+	SecretClient   secret.SecretServiceClient
 )
 
 // GetConnection returns a gRPC client connection
@@ -50,7 +53,8 @@ func GetConnection() (*grpc.ClientConn, error) {
 		return nil, errors.New("undefined TINKERBELL_GRPC_AUTHORITY")
 	}
 	creds := credentials.NewClientTLSFromCert(cp, "")
-	conn, err := grpc.Dial(grpcAuthority, grpc.WithTransportCredentials(creds))
+	opts := append([]grpc.DialOption{grpc.WithTransportCredentials(creds)}, tracing.DialOptions()...)
+	conn, err := grpc.Dial(grpcAuthority, opts...)
 	if err != nil {
 		return nil, errors.Wrap(err, "connect to tinkerbell server")
 	}
@@ -66,6 +70,7 @@ func Setup() error {
 	TemplateClient = template.NewTemplateServiceClient(conn)
 	WorkflowClient = workflow.NewWorkflowServiceClient(conn)
 	HardwareClient = hardware.NewHardwareServiceClient(conn)
+	SecretClient = secret.NewSecretServiceClient(conn)
 	return nil
 }
 
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/tinkerbell/tink/cmd/tink-cli/cmd/secret"
+)
+
+// secretCmd represents the secret sub-command
+var secretCmd = &cobra.Command{
+	Use:     "secret",
+	Short:   "tink secret client",
+	Example: "tink secret [command]",
+	Args: func(c *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("%v requires arguments", c.UseLine())
+		}
+		return nil
+	},
+}
+
+func init() {
+	secretCmd.AddCommand(secret.SubCommands...)
+	rootCmd.AddCommand(secretCmd)
+}
@@ -0,0 +1,7 @@
+package secret
+
+import "github.com/spf13/cobra"
+
+// SubCommands holds the sub commands for secret command
+// Example: tinkerbell secret [subcommand]
+var SubCommands []*cobra.Command
@@ -0,0 +1,83 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tinkerbell/tink/client"
+	"github.com/tinkerbell/tink/protos/secret"
+)
+
+var (
+	fPath      = "path"
+	fName      = "name"
+	filePath   string
+	secretName string
+)
+
+// createCmd represents the create subcommand for secret command
+var createCmd = &cobra.Command{
+	Use:   "create",
+	Short: "create or update a secret",
+	Example: `tink secret create -n ipmi-password -p /tmp/ipmi-password
+cat /tmp/ipmi-password | tink secret create -n ipmi-password`,
+	PreRunE: func(c *cobra.Command, args []string) error {
+		if !isInputFromPipe() {
+			path, _ := c.Flags().GetString(fPath)
+			if path == "" {
+				return fmt.Errorf("either pipe the secret or provide the required '--path' flag")
+			}
+		}
+		return nil
+	},
+	Run: func(c *cobra.Command, args []string) {
+		var reader io.Reader
+		if isInputFromPipe() {
+			reader = os.Stdin
+		} else {
+			f, err := os.Open(filePath)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			reader = f
+		}
+
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			log.Fatal(err)
+		}
+		createSecret(strings.TrimRight(string(data), "\r\n"))
+	},
+}
+
+func addFlags() {
+	flags := createCmd.PersistentFlags()
+	flags.StringVarP(&filePath, "path", "p", "", "path to the file holding the value of the secret")
+	flags.StringVarP(&secretName, "name", "n", "", "unique name for the secret")
+	_ = createCmd.MarkPersistentFlagRequired(fName)
+}
+
+func createSecret(value string) {
+	req := secret.Secret{Name: secretName, Value: value}
+	if _, err := client.SecretClient.CreateSecret(context.Background(), &req); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Created Secret: ", secretName)
+}
+
+func isInputFromPipe() bool {
+	fileInfo, _ := os.Stdin.Stat()
+	return fileInfo.Mode()&os.ModeCharDevice == 0
+}
+
+func init() {
+	addFlags()
+	SubCommands = append(SubCommands, createCmd)
+}
@@ -0,0 +1,37 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/tinkerbell/tink/client"
+	"github.com/tinkerbell/tink/protos/secret"
+)
+
+// deleteCmd represents the delete subcommand for secret command
+var deleteCmd = &cobra.Command{
+	Use:     "delete [name]",
+	Short:   "delete a secret",
+	Example: "tink secret delete [name]",
+	Args: func(c *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("%v requires an argument", c.UseLine())
+		}
+		return nil
+	},
+	Run: func(c *cobra.Command, args []string) {
+		for _, arg := range args {
+			req := secret.GetRequest{Name: arg}
+			if _, err := client.SecretClient.DeleteSecret(context.Background(), &req); err != nil {
+				log.Fatal(err)
+			}
+		}
+	},
+}
+
+func init() {
+	deleteCmd.DisableFlagsInUseLine = true
+	SubCommands = append(SubCommands, deleteCmd)
+}
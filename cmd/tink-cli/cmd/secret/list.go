@@ -0,0 +1,67 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/spf13/cobra"
+	"github.com/tinkerbell/tink/client"
+	"github.com/tinkerbell/tink/protos/secret"
+)
+
+// table headers
+var (
+	name      = "Secret Name"
+	createdAt = "Created At"
+	updatedAt = "Updated At"
+)
+
+// listCmd represents the list subcommand for secret command
+var listCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "list all secrets, without their values",
+	Example: "tink secret list",
+	Args: func(c *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("%v takes no arguments", c.UseLine())
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.AppendHeader(table.Row{name, createdAt, updatedAt})
+		listSecrets(t)
+		t.Render()
+	},
+}
+
+func listSecrets(t table.Writer) {
+	list, err := client.SecretClient.ListSecrets(context.Background(), &secret.Empty{})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var s *secret.Secret
+	for s, err = list.Recv(); err == nil && s.Name != ""; s, err = list.Recv() {
+		cr := s.CreatedAt
+		up := s.UpdatedAt
+		t.AppendRows([]table.Row{
+			{s.Name, time.Unix(cr.Seconds, 0), time.Unix(up.Seconds, 0)},
+		})
+	}
+
+	if err != nil && err != io.EOF {
+		log.Fatal(err)
+	}
+}
+
+func init() {
+	listCmd.DisableFlagsInUseLine = true
+	SubCommands = append(SubCommands, listCmd)
+}
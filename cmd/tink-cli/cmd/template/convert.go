@@ -0,0 +1,62 @@
+package template
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	wflow "github.com/tinkerbell/tink/workflow"
+)
+
+var convertVersion string
+
+// convertCmd represents the convert subcommand for template command
+var convertCmd = &cobra.Command{
+	Use:   "convert [file]",
+	Short: "convert a template to a newer version of the template format",
+	Example: `tink template convert hello-world.yaml > hello-world-0.2.yaml
+cat hello-world.yaml | tink template convert --to 0.2`,
+	Args: func(c *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			return fmt.Errorf("%v takes at most one file", c.UseLine())
+		}
+		if len(args) == 0 && !isInputFromPipe() {
+			return fmt.Errorf("either pipe the template or provide the file to convert")
+		}
+		return nil
+	},
+	// the conversion is done locally, without tink-server
+	PersistentPreRunE: func(c *cobra.Command, args []string) error {
+		return nil
+	},
+	Run: func(c *cobra.Command, args []string) {
+		var data []byte
+		if len(args) == 0 {
+			data = readAll(os.Stdin)
+		} else {
+			var err error
+			data, err = ioutil.ReadFile(filepath.Clean(args[0]))
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		converted, err := wflow.Convert(data, convertVersion)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if _, err := os.Stdout.Write(converted); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	convertCmd.Flags().StringVar(&convertVersion, "to", wflow.CurrentVersion,
+		"version to convert the template to, one of "+strings.Join(wflow.SchemaVersions(), ", "))
+	SubCommands = append(SubCommands, convertCmd)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/tinkerbell/tink/client"
 	"github.com/tinkerbell/tink/protos/template"
+	wflow "github.com/tinkerbell/tink/workflow"
 )
 
 var (
@@ -75,7 +76,7 @@ func addFlags() {
 
 func tryParseTemplate(data string) error {
 	tmpl := *tt.New("")
-	if _, err := tmpl.Parse(data); err != nil {
+	if _, err := tmpl.Parse(wflow.EscapeRefs(data)); err != nil {
 		return err
 	}
 	return nil
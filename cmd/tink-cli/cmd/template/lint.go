@@ -0,0 +1,87 @@
+package template
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/tinkerbell/tink/client"
+	"github.com/tinkerbell/tink/protos/template"
+	wflow "github.com/tinkerbell/tink/workflow"
+)
+
+var lintFormat string
+
+// fileIssue is an issue of a linted file, as printed in the JSON output
+type fileIssue struct {
+	File string `json:"file"`
+	wflow.Issue
+}
+
+// lintCmd represents the lint subcommand for template command
+var lintCmd = &cobra.Command{
+	Use:   "lint [file...]",
+	Short: "report all the errors and warnings of template files",
+	Example: `tink template lint hello-world.yaml
+tink template lint --format json templates/*.yaml`,
+	Args: func(c *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("%v requires at least one file", c.UseLine())
+		}
+		if lintFormat != "text" && lintFormat != "json" {
+			return fmt.Errorf("invalid format %s, expected text or json", lintFormat)
+		}
+		return nil
+	},
+	Run: func(c *cobra.Command, args []string) {
+		issues := []fileIssue{}
+		for _, path := range args {
+			data, err := ioutil.ReadFile(filepath.Clean(path))
+			if err != nil {
+				log.Fatal(err)
+			}
+			res, err := client.TemplateClient.ValidateTemplate(context.Background(), &template.WorkflowTemplate{Data: string(data)})
+			if err != nil {
+				log.Fatal(err)
+			}
+			for _, i := range res.GetIssues() {
+				issues = append(issues, fileIssue{File: path, Issue: wflow.Issue{
+					Severity: i.GetSeverity(),
+					Line:     int(i.GetLine()),
+					Column:   int(i.GetColumn()),
+					Path:     i.GetPath(),
+					Message:  i.GetMessage(),
+				}})
+			}
+		}
+
+		if lintFormat == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(issues); err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			for _, i := range issues {
+				fmt.Printf("%s:%s\n", i.File, i.Issue)
+			}
+		}
+
+		// the templates with errors fail the command, for the CI jobs
+		for _, i := range issues {
+			if i.Severity == wflow.SeverityError {
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+func init() {
+	lintCmd.Flags().StringVar(&lintFormat, "format", "text", "output format, text or json")
+	SubCommands = append(SubCommands, lintCmd)
+}
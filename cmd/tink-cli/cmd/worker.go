@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/tinkerbell/tink/cmd/tink-cli/cmd/worker"
+)
+
+// workerCmd represents the worker sub-command
+var workerCmd = &cobra.Command{
+	Use:     "worker",
+	Short:   "tink worker client",
+	Example: "tink worker [command]",
+	Args: func(c *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("%v requires arguments", c.UseLine())
+		}
+		return nil
+	},
+}
+
+func init() {
+	workerCmd.AddCommand(worker.SubCommands...)
+	rootCmd.AddCommand(workerCmd)
+}
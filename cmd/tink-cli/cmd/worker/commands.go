@@ -0,0 +1,7 @@
+package worker
+
+import "github.com/spf13/cobra"
+
+// SubCommands holds the sub commands for worker command
+// Example: tinkerbell worker [subcommand]
+var SubCommands []*cobra.Command
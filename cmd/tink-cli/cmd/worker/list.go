@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/spf13/cobra"
+	"github.com/tinkerbell/tink/client"
+	"github.com/tinkerbell/tink/protos/workflow"
+)
+
+// table headers
+var (
+	id            = "Worker ID"
+	lastSeen      = "Last Seen"
+	alive         = "Alive"
+	workflowID    = "Workflow ID"
+	currentAction = "Current Action"
+)
+
+// listCmd represents the list subcommand for worker command
+var listCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "list all known workers",
+	Example: "tink worker list",
+	Args: func(c *cobra.Command, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("%v takes no arguments", c.UseLine())
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.AppendHeader(table.Row{id, lastSeen, alive, workflowID, currentAction})
+		listWorkers(t)
+		t.Render()
+	},
+}
+
+func listWorkers(t table.Writer) {
+	list, err := client.WorkflowClient.ListWorkers(context.Background(), &workflow.Empty{})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var w *workflow.WorkerStatus
+	for w, err = list.Recv(); err == nil && w.WorkerId != ""; w, err = list.Recv() {
+		seen := w.LastSeen
+		t.AppendRows([]table.Row{
+			{w.WorkerId, time.Unix(seen.Seconds, 0), w.Alive, w.WorkflowId, w.ActionName},
+		})
+	}
+
+	if err != nil && err != io.EOF {
+		log.Fatal(err)
+	}
+}
+
+func init() {
+	listCmd.DisableFlagsInUseLine = true
+	SubCommands = append(SubCommands, listCmd)
+}
@@ -0,0 +1,43 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"github.com/tinkerbell/tink/client"
+	"github.com/tinkerbell/tink/protos/workflow"
+)
+
+// approveCmd represents the approve subcommand for workflow command
+var approveCmd = &cobra.Command{
+	Use:     "approve [id]",
+	Short:   "approve the approval action a workflow is waiting on",
+	Example: "tink workflow approve [id]",
+	Args: func(c *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("%v requires an argument", c.UseLine())
+		}
+		for _, arg := range args {
+			if _, err := uuid.Parse(arg); err != nil {
+				return fmt.Errorf("invalid uuid: %s", arg)
+			}
+		}
+		return nil
+	},
+	Run: func(c *cobra.Command, args []string) {
+		for _, arg := range args {
+			req := workflow.GetRequest{Id: arg}
+			if _, err := client.WorkflowClient.ApproveWorkflow(context.Background(), &req); err != nil {
+				log.Fatal(err)
+			}
+		}
+	},
+}
+
+func init() {
+	approveCmd.DisableFlagsInUseLine = true
+	SubCommands = append(SubCommands, approveCmd)
+}
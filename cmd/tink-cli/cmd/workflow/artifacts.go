@@ -0,0 +1,133 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/spf13/cobra"
+	"github.com/tinkerbell/tink/client"
+	"github.com/tinkerbell/tink/protos/workflow"
+)
+
+var (
+	hArtifactName = "Name"
+	hSize         = "Size"
+
+	artifactOutput string
+)
+
+// artifactsCmd represents the artifacts subcommand for workflow command
+var artifactsCmd = &cobra.Command{
+	Use:     "artifacts",
+	Short:   "tink workflow artifacts client",
+	Example: "tink workflow artifacts [command]",
+	Args: func(c *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("%v requires arguments", c.UseLine())
+		}
+		return nil
+	},
+}
+
+// listArtifactsCmd represents the list subcommand for workflow artifacts command
+var listArtifactsCmd = &cobra.Command{
+	Use:     "list [id]",
+	Short:   "list the artifacts uploaded by the actions of a workflow",
+	Example: "tink workflow artifacts list [id]",
+	Args: func(c *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("%v requires an argument", c.UseLine())
+		}
+		if _, err := uuid.Parse(args[0]); err != nil {
+			return fmt.Errorf("invalid uuid: %s", args[0])
+		}
+		return nil
+	},
+	Run: func(c *cobra.Command, args []string) {
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.AppendHeader(table.Row{hActionName, hArtifactName, hSize, hWorkerID, hCreatedAt})
+		listArtifacts(t, args[0])
+		t.Render()
+	},
+}
+
+func listArtifacts(t table.Writer, id string) {
+	list, err := client.WorkflowClient.ListArtifacts(context.Background(), &workflow.GetRequest{Id: id})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var a *workflow.Artifact
+	for a, err = list.Recv(); err == nil && a != nil; a, err = list.Recv() {
+		t.AppendRows([]table.Row{
+			{a.ActionName, a.Name, a.Size, a.WorkerId, time.Unix(a.CreatedAt.GetSeconds(), 0)},
+		})
+	}
+
+	if err != nil && err != io.EOF {
+		log.Fatal(err)
+	}
+}
+
+// getArtifactCmd represents the get subcommand for workflow artifacts command
+var getArtifactCmd = &cobra.Command{
+	Use:     "get [id] [action-name] [name]",
+	Short:   "download an artifact uploaded by an action",
+	Example: "tink workflow artifacts get [id] [action-name] [name] --output [file]",
+	Args: func(c *cobra.Command, args []string) error {
+		if len(args) != 3 {
+			return fmt.Errorf("%v requires 3 arguments", c.UseLine())
+		}
+		if _, err := uuid.Parse(args[0]); err != nil {
+			return fmt.Errorf("invalid uuid: %s", args[0])
+		}
+		return nil
+	},
+	Run: func(c *cobra.Command, args []string) {
+		req := &workflow.GetArtifactRequest{WorkflowId: args[0], ActionName: args[1], Name: args[2]}
+		stream, err := client.WorkflowClient.GetArtifact(context.Background(), req)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// the first chunk is received before creating the output file, so
+		// that a missing artifact does not leave an empty file behind
+		chunk, err := stream.Recv()
+		if err != nil && err != io.EOF {
+			log.Fatal(err)
+		}
+
+		out := os.Stdout
+		if artifactOutput != "" && artifactOutput != "-" {
+			out, err = os.Create(artifactOutput)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer out.Close()
+		}
+
+		for ; chunk != nil; chunk, err = stream.Recv() {
+			if _, err := out.Write(chunk.GetChunk()); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if err != nil && err != io.EOF {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	getArtifactCmd.Flags().StringVarP(&artifactOutput, "output", "o", "", "file to write the artifact to, stdout by default")
+
+	listArtifactsCmd.DisableFlagsInUseLine = true
+	artifactsCmd.AddCommand(listArtifactsCmd, getArtifactCmd)
+	SubCommands = append(SubCommands, artifactsCmd)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/tinkerbell/tink/client"
 	"github.com/tinkerbell/tink/protos/workflow"
+	wflow "github.com/tinkerbell/tink/workflow"
 )
 
 var (
@@ -15,13 +16,15 @@ var (
 	fHardware = "hardware"
 	template  string
 	hardware  string
+	params    []string
 )
 
 // createCmd represents the create subcommand for worflow command
 var createCmd = &cobra.Command{
-	Use:     "create",
-	Short:   "create a workflow",
-	Example: "tink workflow create [flags]",
+	Use:   "create",
+	Short: "create a workflow",
+	Example: `tink workflow create [flags]
+tink workflow create -t [template-id] -r '{"device_1": "08:00:27:00:00:01"}' --param disk=/dev/sda --param wipe=true`,
 	PreRunE: func(c *cobra.Command, args []string) error {
 		tmp, _ := c.Flags().GetString(fTemplate)
 		err := validateID(tmp)
@@ -36,13 +39,18 @@ func addFlags() {
 	flags := createCmd.PersistentFlags()
 	flags.StringVarP(&template, "template", "t", "", "workflow template")
 	flags.StringVarP(&hardware, "hardware", "r", "", "workflow targeted hardwares")
+	flags.StringArrayVar(&params, "param", nil, "value of a template parameter as key=value, can be repeated")
 
 	_ = createCmd.MarkPersistentFlagRequired(fHardware)
 	_ = createCmd.MarkPersistentFlagRequired(fTemplate)
 }
 
 func createWorkflow(c *cobra.Command, args []string) {
-	req := workflow.CreateRequest{Template: template, Hardware: hardware}
+	values, err := wflow.ParseParameterValues(params)
+	if err != nil {
+		log.Fatal(err)
+	}
+	req := workflow.CreateRequest{Template: template, Hardware: hardware, Parameters: values}
 	res, err := client.WorkflowClient.CreateWorkflow(context.Background(), &req)
 	if err != nil {
 		log.Fatal(err)
@@ -0,0 +1,43 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"github.com/tinkerbell/tink/client"
+	"github.com/tinkerbell/tink/protos/workflow"
+)
+
+// resumeCmd represents the resume subcommand for workflow command
+var resumeCmd = &cobra.Command{
+	Use:     "resume [id]",
+	Short:   "resume a paused workflow",
+	Example: "tink workflow resume [id]",
+	Args: func(c *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("%v requires an argument", c.UseLine())
+		}
+		for _, arg := range args {
+			if _, err := uuid.Parse(arg); err != nil {
+				return fmt.Errorf("invalid uuid: %s", arg)
+			}
+		}
+		return nil
+	},
+	Run: func(c *cobra.Command, args []string) {
+		for _, arg := range args {
+			req := workflow.GetRequest{Id: arg}
+			if _, err := client.WorkflowClient.ResumeWorkflow(context.Background(), &req); err != nil {
+				log.Fatal(err)
+			}
+		}
+	},
+}
+
+func init() {
+	resumeCmd.DisableFlagsInUseLine = true
+	SubCommands = append(SubCommands, resumeCmd)
+}
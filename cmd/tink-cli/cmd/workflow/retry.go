@@ -0,0 +1,43 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"github.com/tinkerbell/tink/client"
+	"github.com/tinkerbell/tink/protos/workflow"
+)
+
+var fromAction string
+
+// retryCmd represents the retry subcommand for workflow command
+var retryCmd = &cobra.Command{
+	Use:     "retry [id]",
+	Short:   "retry a failed workflow",
+	Example: "tink workflow retry [id] [--from-action [task/]action]",
+	Args: func(c *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("%v requires one argument", c.UseLine())
+		}
+		if _, err := uuid.Parse(args[0]); err != nil {
+			return fmt.Errorf("invalid uuid: %s", args[0])
+		}
+		return nil
+	},
+	Run: func(c *cobra.Command, args []string) {
+		req := &workflow.RetryWorkflowRequest{Id: args[0], FromAction: fromAction}
+		if _, err := client.WorkflowClient.RetryWorkflow(context.Background(), req); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	flags := retryCmd.PersistentFlags()
+	flags.StringVar(&fromAction, "from-action", "", "name of the action to start again from, as task/action when several tasks have an action of that name, the failed action by default")
+
+	SubCommands = append(SubCommands, retryCmd)
+}
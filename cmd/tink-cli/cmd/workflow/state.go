@@ -41,6 +41,13 @@ var stateCmd = &cobra.Command{
 			t.AppendRow(table.Row{"Current Action", wf.CurrentAction})
 			t.AppendRow(table.Row{"Current Worker", wf.CurrentWorker})
 			t.AppendRow(table.Row{"Current Action State", wf.CurrentActionState})
+			if wf.CurrentActionState == workflow.State_STATE_RUNNING {
+				t.AppendRow(table.Row{"Current Action Progress", strconv.Itoa(int(wf.CurrentActionProgress)) + "%"})
+				t.AppendRow(table.Row{"Current Action Message", wf.CurrentActionMessage})
+			}
+			if wf.Paused {
+				t.AppendRow(table.Row{"Paused", "true"})
+			}
 
 			t.Render()
 
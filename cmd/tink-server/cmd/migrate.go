@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/packethost/pkg/log"
+	migrate "github.com/rubenv/sql-migrate"
+	"github.com/spf13/cobra"
+	"github.com/tinkerbell/tink/db"
+)
+
+// NewMigrateCommand creates the command managing the migrations of the
+// database schema
+func NewMigrateCommand(logger log.Logger) *cobra.Command {
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage the migrations of the database schema",
+	}
+	migrateCmd.PersistentFlags().Bool("dry-run", false, "Print the SQL statements of the migrations instead of running them")
+
+	migrateCmd.AddCommand(
+		newMigrateUpCommand(logger),
+		newMigrateDownCommand(logger),
+		newMigrateStatusCommand(logger),
+		newMigrateRedoCommand(logger),
+	)
+	return migrateCmd
+}
+
+func newMigrateUpCommand(logger log.Logger) *cobra.Command {
+	upCmd := &cobra.Command{
+		Use:   "up",
+		Short: "Apply the pending migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			limit, _ := cmd.Flags().GetInt("limit")
+			return runMigrations(cmd, logger, migrate.Up, limit)
+		},
+	}
+	upCmd.Flags().Int("limit", 0, "Maximum number of migrations to apply, 0 applies all of them")
+	return upCmd
+}
+
+func newMigrateDownCommand(logger log.Logger) *cobra.Command {
+	downCmd := &cobra.Command{
+		Use:   "down",
+		Short: "Roll back the last applied migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			limit, _ := cmd.Flags().GetInt("limit")
+			return runMigrations(cmd, logger, migrate.Down, limit)
+		},
+	}
+	downCmd.Flags().Int("limit", 1, "Maximum number of migrations to roll back, 0 rolls back all of them")
+	return downCmd
+}
+
+func newMigrateRedoCommand(logger log.Logger) *cobra.Command {
+	return &cobra.Command{
+		Use:   "redo",
+		Short: "Roll back the last applied migration and apply it again",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := runMigrations(cmd, logger, migrate.Down, 1); err != nil {
+				return err
+			}
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			if dryRun {
+				// nothing was rolled back, the migration to apply again
+				// is the one planned for the roll back
+				tinkDB, err := connect(cmd, logger)
+				if err != nil {
+					return err
+				}
+				planned, err := tinkDB.PlanMigrations(migrate.Down, 1)
+				if err != nil {
+					return err
+				}
+				for _, m := range planned {
+					printPlannedMigration(cmd.OutOrStdout(), m.Id, migrate.Up, m.Up)
+				}
+				return nil
+			}
+			return runMigrations(cmd, logger, migrate.Up, 1)
+		},
+	}
+}
+
+func newMigrateStatusCommand(logger log.Logger) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "List the migrations and when they were applied",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tinkDB, err := connect(cmd, logger)
+			if err != nil {
+				return err
+			}
+			statuses, err := tinkDB.MigrationStatus()
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "MIGRATION\tAPPLIED")
+			for _, s := range statuses {
+				applied := "pending"
+				if s.AppliedAt != nil {
+					applied = s.AppliedAt.Format(time.RFC3339)
+				}
+				if s.Unknown {
+					applied += " (unknown to this version)"
+				}
+				fmt.Fprintf(w, "%s\t%s\n", s.ID, applied)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+// runMigrations applies or rolls back up to limit migrations, or prints
+// their statements in dry-run mode
+func runMigrations(cmd *cobra.Command, logger log.Logger, dir migrate.MigrationDirection, limit int) error {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	tinkDB, err := connect(cmd, logger)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		planned, err := tinkDB.PlanMigrations(dir, limit)
+		if err != nil {
+			return err
+		}
+		for _, m := range planned {
+			printPlannedMigration(cmd.OutOrStdout(), m.Id, dir, m.Queries)
+		}
+		if len(planned) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "-- no migration to run")
+		}
+		return nil
+	}
+
+	n, err := tinkDB.ApplyMigrations(dir, limit)
+	if err != nil {
+		return err
+	}
+	verb := "applied"
+	if dir == migrate.Down {
+		verb = "rolled back"
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "%d migrations %s\n", n, verb)
+	return nil
+}
+
+// printPlannedMigration prints the statements a migration would run, as a
+// SQL script
+func printPlannedMigration(w io.Writer, id string, dir migrate.MigrationDirection, queries []string) {
+	direction := "up"
+	if dir == migrate.Down {
+		direction = "down"
+	}
+	fmt.Fprintf(w, "-- %s (%s)\n", id, direction)
+	for _, q := range queries {
+		fmt.Fprintln(w, strings.TrimSpace(q))
+	}
+	fmt.Fprintln(w)
+}
+
+// connect connects to the database configured by the flags of the command
+func connect(cmd *cobra.Command, logger log.Logger) (*db.TinkDB, error) {
+	return db.Connect(context.Background(), logger, dbConfig(cmd.Flags()))
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/packethost/pkg/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/tinkerbell/tink/artifact"
+	"github.com/tinkerbell/tink/db"
+	rpcServer "github.com/tinkerbell/tink/grpc-server"
+)
+
+// NewPurgeCommand creates the command deleting the expired rows of the
+// database once
+func NewPurgeCommand(logger log.Logger) *cobra.Command {
+	purgeCmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Delete the soft-deleted rows and the workflow events older than a duration",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			olderThan, _ := cmd.Flags().GetDuration("older-than")
+			dataVersions, _ := cmd.Flags().GetInt("data-versions")
+			if olderThan <= 0 {
+				return errors.New("--older-than must be a positive duration")
+			}
+			policy := db.RetentionPolicy{
+				Deleted:      olderThan,
+				Events:       olderThan,
+				DataVersions: dataVersions,
+			}
+
+			tinkDB, err := connect(cmd, logger)
+			if err != nil {
+				return err
+			}
+			result, err := tinkDB.Purge(context.Background(), policy, time.Now(), artifactStore(logger))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "deleted %d hardware, %d templates, %d workflows, %d events, %d workflow data versions and %d artifacts\n",
+				result.Hardware, result.Templates, result.Workflows, result.Events, result.DataVersions, result.Artifacts)
+			return nil
+		},
+	}
+	purgeCmd.Flags().Duration("older-than", 0, "Minimum age of the soft-deleted rows and of the workflow events to delete, the events, data and artifacts of the deleted workflows are deleted with them")
+	purgeCmd.Flags().Int("data-versions", 0, "Number of latest versions of the workflow data to keep, 0 keeps all of them")
+	_ = purgeCmd.MarkFlagRequired("older-than")
+	return purgeCmd
+}
+
+// addRetentionFlags adds the flags configuring the background purge of the
+// expired rows of the database
+func addRetentionFlags(flags *pflag.FlagSet) {
+	flags.Duration("retention-deleted", 0, "How long the deleted hardware, templates and workflows are kept, the events, data and artifacts of the workflows being deleted with them, 0 keeps them forever (TINKERBELL_RETENTION_DELETED)")
+	flags.Duration("retention-events", 0, "How long the workflow events are kept, 0 keeps them forever (TINKERBELL_RETENTION_EVENTS)")
+	flags.Int("retention-data-versions", 0, "Number of latest versions of the workflow data kept, 0 keeps all of them (TINKERBELL_RETENTION_DATA_VERSIONS)")
+	flags.Duration("purge-interval", time.Hour, "Interval between two purges of the expired rows (TINKERBELL_PURGE_INTERVAL)")
+}
+
+// retentionPolicy returns the retention policy set by the flags
+func retentionPolicy(flags *pflag.FlagSet) db.RetentionPolicy {
+	var policy db.RetentionPolicy
+	policy.Deleted, _ = flags.GetDuration("retention-deleted")
+	policy.Events, _ = flags.GetDuration("retention-events")
+	policy.DataVersions, _ = flags.GetInt("retention-data-versions")
+	return policy
+}
+
+// artifactStore returns the store the artifacts of the purged workflows are
+// deleted from, nil if it cannot be opened and the artifacts are then kept
+func artifactStore(logger log.Logger) artifact.Store {
+	store, err := rpcServer.ArtifactStore()
+	if err != nil {
+		logger.With("error", err).Info("artifacts disabled, the artifacts of the purged workflows are kept")
+		return nil
+	}
+	return store
+}
+
+// runPurger periodically deletes the rows which expired according to the
+// retention policy, until the context is done
+func runPurger(ctx context.Context, logger log.Logger, tinkDB *db.TinkDB, policy db.RetentionPolicy, interval time.Duration) {
+	blobs := artifactStore(logger)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		result, err := tinkDB.Purge(ctx, policy, time.Now(), blobs)
+		if err != nil {
+			logger.Error(err, "purging the expired rows")
+		} else if result != (db.PurgeResult{}) {
+			logger.With(
+				"hardware", result.Hardware,
+				"templates", result.Templates,
+				"workflows", result.Workflows,
+				"events", result.Events,
+				"dataVersions", result.DataVersions,
+				"artifacts", result.Artifacts,
+			).Info("purged the expired rows")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/packethost/pkg/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/tinkerbell/tink/db"
+	"github.com/tinkerbell/tink/db/memory"
+	rpcServer "github.com/tinkerbell/tink/grpc-server"
+	httpServer "github.com/tinkerbell/tink/http-server"
+	"github.com/tinkerbell/tink/tracing"
+)
+
+const (
+	databasePostgres = "postgres"
+	databaseMemory   = "memory"
+)
+
+// NewRootCommand creates a new Tink Server Cobra root command
+func NewRootCommand(version string, logger log.Logger) *cobra.Command {
+	rootCmd := &cobra.Command{
+		Use:     "tink-server",
+		Short:   "Tink Server",
+		Version: version,
+		// errors at runtime are not caused by a misuse of the flags
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			viper, err := createViper(logger)
+			if err != nil {
+				return err
+			}
+			return applyViper(viper, cmd)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, _ := cmd.Flags().GetString("database")
+
+			logger.With("version", version).Info("starting")
+			flushSpans, err := tracing.Setup("tink-server", true)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				if err := flushSpans(ctx); err != nil {
+					logger.Error(err)
+				}
+			}()
+
+			ctx, closer := context.WithCancel(context.Background())
+			defer closer()
+			errCh := make(chan error, 2)
+			facility := os.Getenv("FACILITY")
+
+			var d db.Database
+			switch database {
+			case databaseMemory:
+				if !retentionPolicy(cmd.Flags()).IsZero() {
+					return errors.New("the --retention-* flags require --database postgres, the memory database is never purged")
+				}
+				logger.Info("storing the data in memory, it will be lost when the server exits")
+				d = memory.New()
+			case databasePostgres:
+				tinkDB, err := connectPostgres(ctx, logger, dbConfig(cmd.Flags()))
+				if err != nil {
+					return err
+				}
+				prometheus.MustRegister(tinkDB.StatsCollector())
+				if policy := retentionPolicy(cmd.Flags()); !policy.IsZero() {
+					interval, _ := cmd.Flags().GetDuration("purge-interval")
+					if interval <= 0 {
+						return errors.New("--purge-interval must be a positive duration")
+					}
+					go runPurger(ctx, logger, tinkDB, policy, interval)
+				}
+				d = tinkDB
+			default:
+				return fmt.Errorf("unknown database %q, expected %q or %q", database, databasePostgres, databaseMemory)
+			}
+
+			cert, modT := rpcServer.SetupGRPC(ctx, logger, facility, d, errCh)
+			httpServer.SetupHTTP(ctx, logger, cert, modT, errCh)
+
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
+			select {
+			case err = <-errCh:
+				return err
+			case sig := <-sigs:
+				logger.With("signal", sig.String()).Info("signal received, stopping servers")
+			}
+			closer()
+
+			// wait for grpc server to shutdown
+			if err := <-errCh; err != nil {
+				return err
+			}
+			return <-errCh
+		},
+	}
+
+	rootCmd.Flags().String("database", databasePostgres, "Where the data is stored: postgres, or memory to keep it in memory until the server exits (TINKERBELL_DATABASE)")
+
+	addDBFlags(rootCmd.PersistentFlags())
+	addRetentionFlags(rootCmd.Flags())
+
+	rootCmd.AddCommand(NewMigrateCommand(logger), NewPurgeCommand(logger))
+
+	return rootCmd
+}
+
+// addDBFlags adds the flags configuring the connection to the database
+func addDBFlags(flags *pflag.FlagSet) {
+	defaults := db.DefaultConfig()
+	flags.String("db-dsn", "", "Connection string of the database, as a URL or key=value pairs completed by the PG* env vars (TINKERBELL_DB_DSN)")
+	flags.String("db-sslmode", "", "TLS mode of the connection to the database, overriding the one of the connection string (TINKERBELL_DB_SSLMODE)")
+	flags.Int("db-max-open-conns", defaults.MaxOpenConns, "Maximum number of open connections to the database, 0 means unlimited (TINKERBELL_DB_MAX_OPEN_CONNS)")
+	flags.Int("db-max-idle-conns", defaults.MaxIdleConns, "Maximum number of idle connections to the database (TINKERBELL_DB_MAX_IDLE_CONNS)")
+	flags.Duration("db-conn-max-lifetime", defaults.ConnMaxLifetime, "Maximum time a connection to the database is reused, 0 means forever (TINKERBELL_DB_CONN_MAX_LIFETIME)")
+	flags.Duration("db-statement-timeout", defaults.StatementTimeout, "Maximum duration of a statement, 0 means no timeout (TINKERBELL_DB_STATEMENT_TIMEOUT)")
+	flags.Duration("db-connect-timeout", defaults.ConnectTimeout, "Time to wait for the database to be reachable at startup (TINKERBELL_DB_CONNECT_TIMEOUT)")
+}
+
+// dbConfig returns the configuration of the database set by the flags
+func dbConfig(flags *pflag.FlagSet) db.Config {
+	var cfg db.Config
+	cfg.DSN, _ = flags.GetString("db-dsn")
+	cfg.SSLMode, _ = flags.GetString("db-sslmode")
+	cfg.MaxOpenConns, _ = flags.GetInt("db-max-open-conns")
+	cfg.MaxIdleConns, _ = flags.GetInt("db-max-idle-conns")
+	cfg.ConnMaxLifetime, _ = flags.GetDuration("db-conn-max-lifetime")
+	cfg.StatementTimeout, _ = flags.GetDuration("db-statement-timeout")
+	cfg.ConnectTimeout, _ = flags.GetDuration("db-connect-timeout")
+	return cfg
+}
+
+// connectPostgres connects to the Postgres database and checks that its
+// schema is up to date. It only applies the migrations, then exits, when the
+// ONLY_MIGRATION env var is set.
+func connectPostgres(ctx context.Context, logger log.Logger, cfg db.Config) (*db.TinkDB, error) {
+	tinkDB, err := db.Connect(ctx, logger, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	_, onlyMigration := os.LookupEnv("ONLY_MIGRATION")
+	if onlyMigration {
+		logger.Info("Applying migrations. This process will end when migrations will take place.")
+		numAppliedMigrations, err := tinkDB.Migrate()
+		if err != nil {
+			return nil, err
+		}
+		logger.With("num_applied_migrations", numAppliedMigrations).Info("Migrations applied successfully")
+		os.Exit(0)
+	}
+
+	numAvailableMigrations, err := tinkDB.CheckRequiredMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if numAvailableMigrations != 0 {
+		logger.Info("Your database schema is not up to date. Please apply migrations running tink-server migrate up.")
+	}
+	return tinkDB, nil
+}
+
+// createViper creates a Viper object configured to read in configuration files
+// (from various paths with content type specific filename extensions) and loads
+// the TINKERBELL_ prefixed environment variables.
+func createViper(logger log.Logger) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetEnvPrefix("tinkerbell")
+	v.AutomaticEnv()
+	v.SetConfigName("tink-server")
+	v.AddConfigPath("/etc/tinkerbell")
+	v.AddConfigPath(".")
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+
+	// If a config file is found, read it in.
+	if err := v.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			logger.With("configFile", v.ConfigFileUsed()).Error(err, "could not load config file")
+			return nil, err
+		}
+		logger.Info("no config file found")
+	} else {
+		logger.With("configFile", v.ConfigFileUsed()).Info("loaded config file")
+	}
+
+	return v, nil
+}
+
+func applyViper(v *viper.Viper, cmd *cobra.Command) error {
+	errors := []error{}
+
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if !f.Changed && v.IsSet(f.Name) {
+			val := v.Get(f.Name)
+			if err := cmd.Flags().Set(f.Name, fmt.Sprintf("%v", val)); err != nil {
+				errors = append(errors, err)
+				return
+			}
+		}
+	})
+
+	if len(errors) > 0 {
+		errs := []string{}
+		for _, err := range errors {
+			errs = append(errs, err.Error())
+		}
+		return fmt.Errorf(strings.Join(errs, ", "))
+	}
+
+	return nil
+}
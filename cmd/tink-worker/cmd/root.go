@@ -3,25 +3,33 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/packethost/pkg/log"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"github.com/tinkerbell/tink/client"
-	"github.com/tinkerbell/tink/cmd/tink-worker/internal"
 	pb "github.com/tinkerbell/tink/protos/workflow"
+	"github.com/tinkerbell/tink/tracing"
+	"github.com/tinkerbell/tink/worker"
 	"google.golang.org/grpc"
 )
 
 const (
-	defaultRetryInterval        = 3
-	defaultRetryCount           = 3
-	defaultMaxFileSize    int64 = 10 * 1024 * 1024 //10MB
-	defaultTimeoutMinutes       = 60
+	defaultRetryInterval           = 3
+	defaultRetryCount              = 3
+	defaultMaxFileSize       int64 = 10 * 1024 * 1024 //10MB
+	defaultTimeoutMinutes          = 60
+	defaultHeartbeatInterval       = 10 * time.Second
 )
 
 // NewRootCommand creates a new Tink Worker Cobra root command
@@ -48,8 +56,23 @@ func NewRootCommand(version string, logger log.Logger) *cobra.Command {
 			user, _ := cmd.Flags().GetString("registry-username")
 			pwd, _ := cmd.Flags().GetString("registry-password")
 			registry, _ := cmd.Flags().GetString("docker-registry")
+			heartbeatInterval, _ := cmd.Flags().GetDuration("heartbeat-interval")
+			parallelism, _ := cmd.Flags().GetInt("parallelism")
+			drainTimeout, _ := cmd.Flags().GetDuration("drain-timeout")
+			metricsAddr, _ := cmd.Flags().GetString("metrics-addr")
 
 			logger.With("version", version).Info("starting")
+			flushSpans, err := tracing.Setup("tink-worker", false)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				if err := flushSpans(ctx); err != nil {
+					logger.Error(err)
+				}
+			}()
 			if setupErr := client.Setup(); setupErr != nil {
 				return setupErr
 			}
@@ -65,12 +88,31 @@ func NewRootCommand(version string, logger log.Logger) *cobra.Command {
 			if err != nil {
 				return err
 			}
-			rClient := pb.NewWorkflowServiceClient(conn)
-
-			regConn := internal.NewRegistryConnDetails(registry, user, pwd, logger)
-			worker := internal.NewWorker(rClient, regConn, logger, registry, retries, retryInterval, maxFileSize)
+			var registerer prometheus.Registerer
+			if metricsAddr != "" {
+				registerer = prometheus.DefaultRegisterer
+				go serveMetrics(logger, metricsAddr)
+			}
+			w, err := worker.New(worker.Options{
+				ID:                workerID,
+				Client:            pb.NewWorkflowServiceClient(conn),
+				Logger:            logger,
+				Registry:          registry,
+				RegistryUsername:  user,
+				RegistryPassword:  pwd,
+				MaxFileSize:       maxFileSize,
+				Retries:           retries,
+				RetryInterval:     retryInterval * time.Second,
+				HeartbeatInterval: heartbeatInterval,
+				Parallelism:       parallelism,
+				Registerer:        registerer,
+			})
+			if err != nil {
+				return err
+			}
 
-			err = worker.ProcessWorkflowActions(ctx, workerID)
+			go shutdownOnSignal(logger, w, drainTimeout)
+			err = w.Run(ctx)
 			if err != nil {
 				return errors.Wrap(err, "worker Finished with error")
 			}
@@ -82,6 +124,14 @@ func NewRootCommand(version string, logger log.Logger) *cobra.Command {
 
 	rootCmd.Flags().Duration("timeout", time.Duration(defaultTimeoutMinutes*time.Minute), "Max duration to wait for worker to complete (TIMEOUT)")
 
+	rootCmd.Flags().Duration("heartbeat-interval", defaultHeartbeatInterval, "Interval between heartbeats sent to the server, 0 disables them and the server no longer times out the actions of the worker (HEARTBEAT_INTERVAL)")
+
+	rootCmd.Flags().Int("parallelism", 1, "Maximum number of workflows executed concurrently (PARALLELISM)")
+
+	rootCmd.Flags().Duration("drain-timeout", 0, "Time to wait for the running actions to finish on SIGTERM before interrupting them (DRAIN_TIMEOUT)")
+
+	rootCmd.Flags().String("metrics-addr", "", "Address serving the Prometheus metrics at /metrics, empty disables it (METRICS_ADDR)")
+
 	rootCmd.Flags().Int("max-retry", defaultRetryCount, "Maximum number of retries to attempt (MAX_RETRY)")
 
 	rootCmd.Flags().Int64("max-file-size", defaultMaxFileSize, "Maximum file size in bytes (MAX_FILE_SIZE)")
@@ -106,6 +156,8 @@ func NewRootCommand(version string, logger log.Logger) *cobra.Command {
 	rootCmd.Flags().StringP("registry-password", "p", "", "Sets the registry-password (REGISTRY_PASSWORD)")
 	must(rootCmd.MarkFlagRequired("registry-password"))
 
+	rootCmd.AddCommand(NewRunCommand(logger))
+
 	return rootCmd
 }
 
@@ -171,3 +223,33 @@ func tryClientConnection(logger log.Logger, retryInterval time.Duration, retries
 	}
 	return nil, fmt.Errorf("retries exceeded")
 }
+
+// shutdownOnSignal shuts the worker down on SIGTERM or SIGINT, waiting for
+// the running actions up to the drain timeout. A second signal interrupts
+// them right away.
+func shutdownOnSignal(logger log.Logger, w *worker.Worker, drainTimeout time.Duration) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	sig := <-signals
+	logger.With("signal", sig.String(), "drainTimeout", drainTimeout.String()).Info("shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	go func() {
+		<-signals
+		cancel()
+	}()
+	if err := w.Shutdown(ctx); err != nil {
+		logger.With("error", err).Info("running actions interrupted")
+	}
+}
+
+// serveMetrics exposes the Prometheus metrics of the worker at /metrics
+func serveMetrics(logger log.Logger, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	logger.With("address", addr).Info("serving metrics")
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		logger.Error(errors.Wrap(err, "metrics server"))
+	}
+}
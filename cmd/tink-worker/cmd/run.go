@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/packethost/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/tinkerbell/tink/worker"
+	wflow "github.com/tinkerbell/tink/workflow"
+)
+
+const defaultLocalDataDir = ".tink-worker"
+
+// NewRunCommand creates the command executing a template on the local
+// machine, without tink-server
+func NewRunCommand(logger log.Logger) *cobra.Command {
+	runCmd := &cobra.Command{
+		Use:   "run",
+		Short: "Execute a template on the local machine, without tink-server",
+		Example: `tink-worker run --template hello-world.yaml --hardware hardware.json
+tink-worker run --template hello-world.yaml --hardware hardware.json --id 08:00:27:00:00:01
+tink-worker run --template install.yaml --hardware hardware.json --param disk=/dev/sda --param wipe=true`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			viper, err := createViper(logger)
+			if err != nil {
+				return err
+			}
+			return applyViper(viper, cmd)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			templatePath, _ := cmd.Flags().GetString("template")
+			hardwarePath, _ := cmd.Flags().GetString("hardware")
+			workerID, _ := cmd.Flags().GetString("id")
+			dataDir, _ := cmd.Flags().GetString("data-dir")
+			maxFileSize, _ := cmd.Flags().GetInt64("max-file-size")
+			user, _ := cmd.Flags().GetString("registry-username")
+			pwd, _ := cmd.Flags().GetString("registry-password")
+			registry, _ := cmd.Flags().GetString("docker-registry")
+			paramPairs, _ := cmd.Flags().GetStringArray("param")
+
+			params, err := wflow.ParseParameterValues(paramPairs)
+			if err != nil {
+				return err
+			}
+
+			data, err := ioutil.ReadFile(filepath.Clean(templatePath))
+			if err != nil {
+				return err
+			}
+			hardware := []byte("{}")
+			if hardwarePath != "" {
+				hardware, err = ioutil.ReadFile(filepath.Clean(hardwarePath))
+				if err != nil {
+					return err
+				}
+			}
+			expanded, _, err := wflow.ExpandIncludes(filepath.Clean(templatePath), string(data), includeLookup(templatePath))
+			if err != nil {
+				return err
+			}
+			rendered, err := wflow.RenderTemplate(templatePath, expanded, hardware, params)
+			if err != nil {
+				return err
+			}
+			wf, err := wflow.Parse([]byte(rendered))
+			if err != nil {
+				return err
+			}
+
+			// interrupting the local run removes the action container
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+			defer signal.Stop(signals)
+			go func() {
+				select {
+				case <-signals:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+			err = worker.RunLocal(ctx, worker.Options{
+				ID:               workerID,
+				Logger:           logger,
+				Registry:         registry,
+				RegistryUsername: user,
+				RegistryPassword: pwd,
+				DataDir:          dataDir,
+				MaxFileSize:      maxFileSize,
+				Retries:          1,
+			}, wf)
+			if err != nil {
+				return errors.Wrap(err, "workflow finished with error")
+			}
+			return nil
+		},
+	}
+
+	runCmd.Flags().StringP("template", "t", "", "Path of the template to execute")
+	runCmd.Flags().String("hardware", "", "Path of a JSON file mapping the devices of the template to workers, e.g. {\"device_1\": \"08:00:27:00:00:01\"}")
+	runCmd.Flags().StringArray("param", nil, "Value of a parameter of the template as key=value, can be repeated")
+	runCmd.Flags().StringP("id", "i", "", "Execute only the tasks of this worker, all the tasks if empty (ID)")
+	runCmd.Flags().String("data-dir", defaultLocalDataDir, "Directory where the workflow data, outputs and artifacts are kept (DATA_DIR)")
+	runCmd.Flags().Int64("max-file-size", defaultMaxFileSize, "Maximum file size in bytes (MAX_FILE_SIZE)")
+	runCmd.Flags().StringP("docker-registry", "r", "", "Sets the Docker registry, the images are used as they are if empty (DOCKER_REGISTRY)")
+	runCmd.Flags().StringP("registry-username", "u", "", "Sets the registry username (REGISTRY_USERNAME)")
+	runCmd.Flags().StringP("registry-password", "p", "", "Sets the registry-password (REGISTRY_PASSWORD)")
+
+	if err := runCmd.MarkFlagRequired("template"); err != nil {
+		logger.Fatal(err)
+	}
+	return runCmd
+}
+
+// includeLookup resolves the includes of a local template against the files
+// of its directory, the .yaml extension of the files being optional
+func includeLookup(templatePath string) wflow.TemplateLookup {
+	dir := filepath.Dir(templatePath)
+	return func(ref string) (string, string, error) {
+		path := filepath.Join(dir, ref)
+		if filepath.Ext(path) == "" {
+			path += ".yaml"
+		}
+		data, err := ioutil.ReadFile(path)
+		return path, string(data), err
+	}
+}
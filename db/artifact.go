@@ -0,0 +1,131 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/pkg/errors"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+)
+
+// InsertIntoWorkflowArtifactTable records an artifact uploaded for an action,
+// stored under the given blob key. It returns the key of the artifact it
+// replaces, if any.
+func (d TinkDB) InsertIntoWorkflowArtifactTable(ctx context.Context, a *pb.Artifact, key string, time time.Time) (string, error) {
+	tx, err := d.instance.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return "", errors.Wrap(err, "BEGIN transaction")
+	}
+
+	var prevKey string
+	err = tx.QueryRow(`
+	SELECT blob_key
+	FROM workflow_artifact
+	WHERE
+		workflow_id = $1
+	AND
+		action_name = $2
+	AND
+		name = $3;
+	`, a.WorkflowId, a.ActionName, a.Name).Scan(&prevKey)
+	if err != nil && err != sql.ErrNoRows {
+		_ = tx.Rollback()
+		return "", errors.Wrap(err, "SELECT from workflow_artifact")
+	}
+
+	_, err = tx.Exec(`
+	INSERT INTO
+		workflow_artifact (workflow_id, worker_id, task_name, action_name, name, size, blob_key, created_at)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (workflow_id, action_name, name)
+	DO
+	UPDATE SET
+		(worker_id, task_name, size, blob_key, created_at) = ($2, $3, $6, $7, $8);
+	`, a.WorkflowId, a.WorkerId, a.TaskName, a.ActionName, a.Name, a.Size, key, time)
+	if err != nil {
+		_ = tx.Rollback()
+		return "", errors.Wrap(err, "INSERT in to workflow_artifact")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", errors.Wrap(err, "COMMIT")
+	}
+	return prevKey, nil
+}
+
+// GetWorkflowArtifactKey returns the blob key of an artifact
+func (d TinkDB) GetWorkflowArtifactKey(ctx context.Context, wfID, actionName, name string) (string, error) {
+	query := `
+	SELECT blob_key
+	FROM workflow_artifact
+	WHERE
+		workflow_id = $1
+	AND
+		action_name = $2
+	AND
+		name = $3;
+	`
+	var key string
+	err := d.instance.QueryRowContext(ctx, query, wfID, actionName, name).Scan(&key)
+	if err == nil {
+		return key, nil
+	}
+	if err != sql.ErrNoRows {
+		err = errors.Wrap(err, "SELECT from workflow_artifact")
+		logger.Error(err)
+	}
+	return "", err
+}
+
+// ListWorkflowArtifacts returns the artifacts uploaded for a workflow
+func (d TinkDB) ListWorkflowArtifacts(ctx context.Context, wfID string, fn func(a *pb.Artifact) error) error {
+	rows, err := d.instance.QueryContext(ctx, `
+	SELECT worker_id, task_name, action_name, name, size, created_at
+	FROM workflow_artifact
+	WHERE
+		workflow_id = $1
+	ORDER BY
+		created_at ASC;
+	`, wfID)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+	var (
+		workerID, taskName, actionName, name string
+		size                                 int64
+		createdAt                            time.Time
+	)
+
+	for rows.Next() {
+		err = rows.Scan(&workerID, &taskName, &actionName, &name, &size, &createdAt)
+		if err != nil {
+			err = errors.Wrap(err, "SELECT from workflow_artifact")
+			logger.Error(err)
+			return err
+		}
+		a := &pb.Artifact{
+			WorkflowId: wfID,
+			WorkerId:   workerID,
+			TaskName:   taskName,
+			ActionName: actionName,
+			Name:       name,
+			Size:       size,
+		}
+		a.CreatedAt, _ = ptypes.TimestampProto(createdAt)
+		err = fn(a)
+		if err != nil {
+			return err
+		}
+	}
+	err = rows.Err()
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	return err
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultMaxOpenConns   = 20
+	defaultMaxIdleConns   = 5
+	defaultConnectTimeout = time.Minute
+
+	minPingBackoff = 500 * time.Millisecond
+	maxPingBackoff = 10 * time.Second
+)
+
+// Config configures the connection to the database and its pool
+type Config struct {
+	// DSN is the connection string, as a URL or as key=value pairs. The
+	// PG* environment variables fill in what it leaves unset.
+	DSN string
+	// SSLMode overrides the sslmode of the DSN when set, e.g. disable or
+	// verify-full
+	SSLMode string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	// StatementTimeout aborts the statements running for longer, 0 means
+	// no timeout
+	StatementTimeout time.Duration
+	// ConnectTimeout is how long the database is waited for at startup
+	ConnectTimeout time.Duration
+}
+
+// DefaultConfig returns the configuration used when none is given
+func DefaultConfig() Config {
+	return Config{
+		MaxOpenConns:   defaultMaxOpenConns,
+		MaxIdleConns:   defaultMaxIdleConns,
+		ConnectTimeout: defaultConnectTimeout,
+	}
+}
+
+// connString returns the DSN with the settings of the configuration
+// appended, as key=value pairs
+func (c Config) connString() (string, error) {
+	dsn := c.DSN
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		var err error
+		dsn, err = pq.ParseURL(dsn)
+		if err != nil {
+			return "", errors.Wrap(err, "parse database URL")
+		}
+	}
+	var params []string
+	if dsn != "" {
+		params = append(params, dsn)
+	}
+	if c.SSLMode != "" {
+		params = append(params, "sslmode="+c.SSLMode)
+	}
+	if c.StatementTimeout > 0 {
+		// sent to the server as a run-time parameter of the session
+		params = append(params, fmt.Sprintf("statement_timeout=%d", c.StatementTimeout.Milliseconds()))
+	}
+	return strings.Join(params, " "), nil
+}
+
+// pingWithRetry pings the database until it answers, backing off between
+// the attempts, or the timeout expires
+func pingWithRetry(ctx context.Context, ping func(context.Context) error, timeout time.Duration, onRetry func(error, time.Duration)) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := minPingBackoff
+	for {
+		err := ping(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return errors.Wrap(err, "database not reachable")
+		}
+		onRetry(err, backoff)
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(err, "database not reachable")
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxPingBackoff {
+			backoff = maxPingBackoff
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnString(t *testing.T) {
+	testCases := map[string]struct {
+		cfg      Config
+		expected string
+	}{
+		"env vars only": {
+			expected: "",
+		},
+		"key values": {
+			cfg:      Config{DSN: "host=db user=tinkerbell", SSLMode: "disable"},
+			expected: "host=db user=tinkerbell sslmode=disable",
+		},
+		"url": {
+			cfg:      Config{DSN: "postgres://tinkerbell@db:5432/tinkerbell", StatementTimeout: 5 * time.Second},
+			expected: "dbname=tinkerbell host=db port=5432 user=tinkerbell statement_timeout=5000",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dsn, err := tc.cfg.connString()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, dsn)
+		})
+	}
+}
+
+func TestPingWithRetry(t *testing.T) {
+	var attempts, retries int
+	ping := func(context.Context) error {
+		if attempts++; attempts < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	onRetry := func(error, time.Duration) { retries++ }
+	assert.NoError(t, pingWithRetry(context.Background(), ping, time.Minute, onRetry))
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, 2, retries)
+
+	unreachable := func(context.Context) error { return errors.New("connection refused") }
+	err := pingWithRetry(context.Background(), unreachable, 100*time.Millisecond, func(error, time.Duration) {})
+	assert.EqualError(t, err, "database not reachable: connection refused")
+}
+
+func TestStatsCollector(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(newStatsCollector(func() sql.DBStats {
+		return sql.DBStats{MaxOpenConnections: 20, OpenConnections: 3, InUse: 2, Idle: 1}
+	}))
+	families, err := reg.Gather()
+	assert.NoError(t, err)
+	values := map[string]float64{}
+	for _, f := range families {
+		if m := f.GetMetric()[0]; m.GetGauge() != nil {
+			values[f.GetName()] = m.GetGauge().GetValue()
+		}
+	}
+	assert.Len(t, families, 8)
+	assert.Equal(t, float64(20), values["tink_db_max_open_connections"])
+	assert.Equal(t, float64(2), values["tink_db_in_use_connections"])
+}
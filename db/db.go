@@ -22,6 +22,9 @@ type Database interface {
 	hardware
 	template
 	workflow
+	worker
+	secret
+	artifact
 }
 
 type hardware interface {
@@ -33,9 +36,31 @@ type hardware interface {
 	GetAll(fn func([]byte) error) error
 }
 
+type worker interface {
+	RegisterWorker(ctx context.Context, id, bootID string, time time.Time) (string, error)
+	UpdateWorkerHeartbeat(ctx context.Context, hb *pb.HeartbeatRequest, time time.Time) error
+	ListWorkers(fn func(w *pb.WorkerStatus) error) error
+	GetOrphanedWorkflows(ctx context.Context, lastSeen time.Time) ([]string, error)
+	TimeoutOrphanedAction(ctx context.Context, wfID string, actionIndex int64) (bool, error)
+}
+
+type artifact interface {
+	InsertIntoWorkflowArtifactTable(ctx context.Context, a *pb.Artifact, key string, time time.Time) (string, error)
+	GetWorkflowArtifactKey(ctx context.Context, wfID, actionName, name string) (string, error)
+	ListWorkflowArtifacts(ctx context.Context, wfID string, fn func(a *pb.Artifact) error) error
+}
+
+type secret interface {
+	CreateSecret(ctx context.Context, name string, value []byte, time time.Time) error
+	GetSecret(ctx context.Context, name string) ([]byte, error)
+	DeleteSecret(ctx context.Context, name string) error
+	ListSecrets(fn func(name string, in, up *timestamp.Timestamp) error) error
+}
+
 type template interface {
 	CreateTemplate(ctx context.Context, name string, data string, id uuid.UUID) error
 	GetTemplate(ctx context.Context, id string) (string, string, error)
+	GetTemplateByName(ctx context.Context, name string) (string, string, error)
 	DeleteTemplate(ctx context.Context, name string) error
 	ListTemplates(fn func(id, n string, in, del *timestamp.Timestamp) error) error
 	UpdateTemplate(ctx context.Context, name string, data string, id uuid.UUID) error
@@ -53,10 +78,13 @@ type workflow interface {
 	ListWorkflows(fn func(wf Workflow) error) error
 	UpdateWorkflow(ctx context.Context, wf Workflow, state int32) error
 	UpdateWorkflowState(ctx context.Context, wfContext *pb.WorkflowContext) error
+	UpdateWorkflowPaused(ctx context.Context, wfID string, paused bool) error
 	GetWorkflowContexts(ctx context.Context, wfID string) (*pb.WorkflowContext, error)
 	GetWorkflowActions(ctx context.Context, wfID string) (*pb.WorkflowActionList, error)
 	InsertIntoWorkflowEventTable(ctx context.Context, wfEvent *pb.WorkflowActionStatus, time time.Time) error
 	ShowWorkflowEvents(wfID string, fn func(wfs *pb.WorkflowActionStatus) error) error
+	UpdateWorkflowStateWithOutputs(ctx context.Context, wfContext *pb.WorkflowContext, wfOutput *pb.WorkflowActionStatus, time time.Time) error
+	GetWorkflowOutputs(ctx context.Context, wfID string) (map[string]map[string]string, error)
 }
 
 // TinkDB implements the Database interface
@@ -64,24 +92,45 @@ type TinkDB struct {
 	instance *sql.DB
 }
 
-// Connect returns a connection to postgres database
-func Connect(lg log.Logger) *TinkDB {
+// Connect opens a pool of connections to the postgres database and waits
+// for the database to answer, up to the connect timeout of the configuration
+func Connect(ctx context.Context, lg log.Logger, cfg Config) (*TinkDB, error) {
 	logger = lg
-	db, err := sql.Open("postgres", "")
+	dsn, err := cfg.connString()
 	if err != nil {
-		logger.Error(err)
-		panic(err)
+		return nil, err
 	}
-	return &TinkDB{instance: db}
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, "database configuration")
+	}
+	db := sql.OpenDB(tracedConnector{connector})
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	err = pingWithRetry(ctx, db.PingContext, cfg.ConnectTimeout, func(err error, backoff time.Duration) {
+		logger.With("error", err, "backoff", backoff.String()).Info("database not reachable, retrying")
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &TinkDB{instance: db}, nil
+}
+
+// Ping checks that the database is reachable
+func (t *TinkDB) Ping(ctx context.Context) error {
+	return t.instance.PingContext(ctx)
 }
 
 func (t *TinkDB) Migrate() (int, error) {
-	return migrate.Exec(t.instance, "postgres", migration.GetMigrations(), migrate.Up)
+	return migrate.Exec(t.instance, dialect, migration.GetMigrations(), migrate.Up)
 }
 
 func (t *TinkDB) CheckRequiredMigrations() (int, error) {
 	migrations := migration.GetMigrations().Migrations
-	records, err := migrate.GetMigrationRecords(t.instance, "postgres")
+	records, err := migrate.GetMigrationRecords(t.instance, dialect)
 	if err != nil {
 		return 0, err
 	}
@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+)
+
+type artifactKey struct {
+	workflowID, actionName, name string
+}
+
+type artifactRecord struct {
+	workerID, taskName string
+	size               int64
+	blobKey            string
+	createdAt          time.Time
+}
+
+// InsertIntoWorkflowArtifactTable records an artifact uploaded for an action,
+// stored under the given blob key. It returns the key of the artifact it
+// replaces, if any.
+func (d *DB) InsertIntoWorkflowArtifactTable(ctx context.Context, a *pb.Artifact, key string, time time.Time) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	k := artifactKey{workflowID: a.GetWorkflowId(), actionName: a.GetActionName(), name: a.GetName()}
+	var prevKey string
+	if prev, ok := d.artifacts[k]; ok {
+		prevKey = prev.blobKey
+	}
+	d.artifacts[k] = &artifactRecord{
+		workerID:  a.GetWorkerId(),
+		taskName:  a.GetTaskName(),
+		size:      a.GetSize(),
+		blobKey:   key,
+		createdAt: time,
+	}
+	return prevKey, nil
+}
+
+// GetWorkflowArtifactKey returns the blob key of an artifact
+func (d *DB) GetWorkflowArtifactKey(ctx context.Context, wfID, actionName, name string) (string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	a, ok := d.artifacts[artifactKey{workflowID: wfID, actionName: actionName, name: name}]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return a.blobKey, nil
+}
+
+// ListWorkflowArtifacts passes the artifacts uploaded for a workflow to fn,
+// oldest first
+func (d *DB) ListWorkflowArtifacts(ctx context.Context, wfID string, fn func(a *pb.Artifact) error) error {
+	d.mu.RLock()
+	var all []*pb.Artifact
+	for k, a := range d.artifacts {
+		if k.workflowID != wfID {
+			continue
+		}
+		artifact := &pb.Artifact{
+			WorkflowId: wfID,
+			WorkerId:   a.workerID,
+			TaskName:   a.taskName,
+			ActionName: k.actionName,
+			Name:       k.name,
+			Size:       a.size,
+		}
+		artifact.CreatedAt, _ = ptypes.TimestampProto(a.createdAt)
+		all = append(all, artifact)
+	}
+	d.mu.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		ti, _ := ptypes.Timestamp(all[i].CreatedAt)
+		tj, _ := ptypes.Timestamp(all[j].CreatedAt)
+		if ti.Equal(tj) {
+			return all[i].ActionName+"/"+all[i].Name < all[j].ActionName+"/"+all[j].Name
+		}
+		return ti.Before(tj)
+	})
+	for _, a := range all {
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+type hardwareRecord struct {
+	data    string
+	deleted bool
+
+	macs, ips []string
+}
+
+// hardwareData holds the fields of the hardware data looked up by the queries
+// of the Postgres implementation
+type hardwareData struct {
+	ID       string `json:"id"`
+	Instance struct {
+		IPAddresses []struct {
+			Address string `json:"address"`
+		} `json:"ip_addresses"`
+	} `json:"instance"`
+	Network struct {
+		Interfaces []struct {
+			DHCP struct {
+				MAC string `json:"mac"`
+				IP  struct {
+					Address string `json:"address"`
+				} `json:"ip"`
+			} `json:"dhcp"`
+		} `json:"interfaces"`
+	} `json:"network"`
+}
+
+// DeleteFromDB marks a hardware as deleted
+func (d *DB) DeleteFromDB(ctx context.Context, id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if hw, ok := d.hardware[id]; ok {
+		hw.deleted = true
+	}
+	return nil
+}
+
+// InsertIntoDB creates or replaces a hardware, identified by the id field of
+// its data
+func (d *DB) InsertIntoDB(ctx context.Context, data string) error {
+	var hd hardwareData
+	if err := json.Unmarshal([]byte(data), &hd); err != nil {
+		return errors.Wrap(err, "INSERT")
+	}
+	id, err := uuid.Parse(hd.ID)
+	if err != nil {
+		return errors.Wrap(err, "INSERT")
+	}
+
+	hw := &hardwareRecord{data: data}
+	for _, iface := range hd.Network.Interfaces {
+		hw.macs = append(hw.macs, iface.DHCP.MAC)
+		hw.ips = append(hw.ips, iface.DHCP.IP.Address)
+	}
+	for _, ip := range hd.Instance.IPAddresses {
+		hw.ips = append(hw.ips, ip.Address)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hardware[id.String()] = hw
+	return nil
+}
+
+// GetByMAC returns the data of the hardware with an interface of the given
+// mac address
+func (d *DB) GetByMAC(ctx context.Context, mac string) (string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	_, hw := d.hardwareByMAC(mac)
+	if hw == nil {
+		return "", nil
+	}
+	return hw.data, nil
+}
+
+// GetByIP returns the data of the hardware with an interface or an instance
+// of the given ip address
+func (d *DB) GetByIP(ctx context.Context, ip string) (string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	_, hw := d.hardwareByIP(ip)
+	if hw == nil {
+		return "", nil
+	}
+	return hw.data, nil
+}
+
+// GetByID returns the data of a hardware
+func (d *DB) GetByID(ctx context.Context, id string) (string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	hw, ok := d.hardware[id]
+	if !ok || hw.deleted {
+		return "", nil
+	}
+	return hw.data, nil
+}
+
+// GetAll passes the data of every hardware to fn
+func (d *DB) GetAll(fn func([]byte) error) error {
+	d.mu.RLock()
+	var all [][]byte
+	for _, id := range d.hardwareIDs() {
+		all = append(all, []byte(d.hardware[id].data))
+	}
+	d.mu.RUnlock()
+
+	for _, data := range all {
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hardwareIDs returns the sorted ids of the hardware which are not deleted
+func (d *DB) hardwareIDs() []string {
+	var ids []string
+	for id, hw := range d.hardware {
+		if !hw.deleted {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (d *DB) hardwareByMAC(mac string) (string, *hardwareRecord) {
+	for _, id := range d.hardwareIDs() {
+		hw := d.hardware[id]
+		if contains(hw.macs, mac) {
+			return id, hw
+		}
+	}
+	return "", nil
+}
+
+func (d *DB) hardwareByIP(ip string) (string, *hardwareRecord) {
+	for _, id := range d.hardwareIDs() {
+		hw := d.hardware[id]
+		if contains(hw.ips, ip) {
+			return id, hw
+		}
+	}
+	return "", nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
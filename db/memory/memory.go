@@ -0,0 +1,56 @@
+// Package memory implements the db.Database interface in memory, so that the
+// server can run without Postgres in tests and demos. Its data is lost when
+// the process exits.
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tinkerbell/tink/db"
+)
+
+// DB is an in-memory implementation of the Database interface, safe for
+// concurrent use. It mirrors the behaviour of the Postgres implementation,
+// including soft deletions and the versioning of the workflow data.
+type DB struct {
+	mu sync.RWMutex
+
+	hardware  map[string]*hardwareRecord
+	templates map[string]*templateRecord
+
+	workflows      map[string]*workflowRecord
+	states         map[string]*stateRecord
+	workflowWorker []workflowWorker
+	data           map[string][]dataRecord
+	events         map[string][]eventRecord
+	outputs        map[string]map[string]map[string]string
+
+	workers   map[string]*workerRecord
+	secrets   map[string]*secretRecord
+	artifacts map[artifactKey]*artifactRecord
+}
+
+var _ db.Database = &DB{}
+
+// New returns an empty in-memory database
+func New() *DB {
+	return &DB{
+		hardware:  map[string]*hardwareRecord{},
+		templates: map[string]*templateRecord{},
+		workflows: map[string]*workflowRecord{},
+		states:    map[string]*stateRecord{},
+		data:      map[string][]dataRecord{},
+		events:    map[string][]eventRecord{},
+		outputs:   map[string]map[string]map[string]string{},
+		workers:   map[string]*workerRecord{},
+		secrets:   map[string]*secretRecord{},
+		artifacts: map[artifactKey]*artifactRecord{},
+	}
+}
+
+// timestamps are the creation, update and soft deletion times of a record
+type timestamps struct {
+	createdAt, updatedAt time.Time
+	deleted              bool
+}
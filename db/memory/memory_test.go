@@ -0,0 +1,261 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/tinkerbell/tink/db"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+)
+
+const (
+	hardwareID = "ce2e62ed-826f-4485-a39f-a82bb74338e2"
+	hardwareIP = "192.168.1.5"
+	mac        = "08:00:27:00:00:01"
+
+	workflowData = `version: "0.1"
+name: hello_world_workflow
+global_timeout: 600
+tasks:
+  - name: "hello world"
+    worker: "08:00:27:00:00:01"
+    actions:
+    - name: "hello_world"
+      image: hello-world
+      timeout: 60
+    - name: "goodbye_world"
+      image: hello-world
+      timeout: 60`
+)
+
+var testHardware = `{"id": "` + hardwareID + `", "network": {"interfaces": [{"dhcp": {"mac": "` + mac + `", "ip": {"address": "` + hardwareIP + `"}}}]}}`
+
+func TestHardware(t *testing.T) {
+	ctx := context.Background()
+	d := New()
+	assert.NoError(t, d.InsertIntoDB(ctx, testHardware))
+	assert.Error(t, d.InsertIntoDB(ctx, `{"id": "not-a-uuid"}`))
+
+	testCases := map[string]func() (string, error){
+		"by id":  func() (string, error) { return d.GetByID(ctx, hardwareID) },
+		"by mac": func() (string, error) { return d.GetByMAC(ctx, mac) },
+		"by ip":  func() (string, error) { return d.GetByIP(ctx, hardwareIP) },
+	}
+	for name, get := range testCases {
+		t.Run(name, func(t *testing.T) {
+			data, err := get()
+			assert.NoError(t, err)
+			assert.Equal(t, testHardware, data)
+		})
+	}
+
+	assert.NoError(t, d.DeleteFromDB(ctx, hardwareID))
+	data, err := d.GetByMAC(ctx, mac)
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+	assert.NoError(t, d.GetAll(func([]byte) error {
+		t.Error("deleted hardware listed")
+		return nil
+	}))
+}
+
+func TestTemplate(t *testing.T) {
+	ctx := context.Background()
+	d := New()
+	id := uuid.New()
+	assert.Error(t, d.CreateTemplate(ctx, "invalid", "tasks: [", id))
+	assert.NoError(t, d.CreateTemplate(ctx, "hello", workflowData, id))
+
+	assert.NoError(t, d.UpdateTemplate(ctx, "hello-again", "", id))
+	name, data, err := d.GetTemplate(ctx, id.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "hello-again", name)
+	assert.Equal(t, workflowData, data)
+
+	assert.NoError(t, d.DeleteTemplate(ctx, id.String()))
+	_, _, err = d.GetTemplate(ctx, id.String())
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestWorkflow(t *testing.T) {
+	ctx := context.Background()
+	d := New()
+	assert.NoError(t, d.InsertIntoDB(ctx, testHardware))
+
+	id := uuid.New()
+	wf := db.Workflow{ID: id.String(), Template: uuid.New().String(), Hardware: `{"device_1": "` + mac + `"}`}
+	err := d.CreateWorkflow(ctx, db.Workflow{ID: uuid.New().String()}, `version: "0.1"
+name: unknown
+global_timeout: 600
+tasks:
+  - name: "unknown"
+    worker: "08:00:27:00:00:02"
+    actions:
+    - name: "unknown"
+      image: hello-world
+      timeout: 60`, uuid.New())
+	assert.Error(t, err)
+	assert.NoError(t, d.CreateWorkflow(ctx, wf, workflowData, id))
+
+	got, err := d.GetWorkflow(ctx, id.String())
+	assert.NoError(t, err)
+	assert.Equal(t, wf.Template, got.Template)
+	assert.NotNil(t, got.CreatedAt)
+
+	wfIDs, err := d.GetWorkflowsForWorker(hardwareID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{id.String()}, wfIDs)
+
+	actions, err := d.GetWorkflowActions(ctx, id.String())
+	assert.NoError(t, err)
+	if assert.Len(t, actions.GetActionList(), 2) {
+		assert.Equal(t, hardwareID, actions.GetActionList()[0].GetWorkerId())
+	}
+
+	wfContext, err := d.GetWorkflowContexts(ctx, id.String())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), wfContext.GetTotalNumberOfActions())
+
+	wfContext.CurrentAction = "hello_world"
+	wfContext.CurrentActionState = pb.State_STATE_RUNNING
+	wfContext.CurrentWorker = hardwareID
+	wfContext.TotalNumberOfActions = 5
+	assert.NoError(t, d.UpdateWorkflowState(ctx, wfContext))
+	wfContext, err = d.GetWorkflowContexts(ctx, id.String())
+	assert.NoError(t, err)
+	assert.Equal(t, pb.State_STATE_RUNNING, wfContext.GetCurrentActionState())
+	assert.Equal(t, int64(2), wfContext.GetTotalNumberOfActions())
+
+	// the worker registered an hour ago without sending heartbeats, it has
+	// them disabled
+	_, err = d.RegisterWorker(ctx, hardwareID, "boot-1", time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	orphans, err := d.GetOrphanedWorkflows(ctx, time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.Empty(t, orphans)
+
+	// the worker has not sent a heartbeat for an hour
+	err = d.UpdateWorkerHeartbeat(ctx, &pb.HeartbeatRequest{WorkerId: hardwareID}, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	orphans, err = d.GetOrphanedWorkflows(ctx, time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{id.String()}, orphans)
+
+	// only the running action is timed out, once
+	timedOut, err := d.TimeoutOrphanedAction(ctx, id.String(), 1)
+	assert.NoError(t, err)
+	assert.False(t, timedOut)
+	timedOut, err = d.TimeoutOrphanedAction(ctx, id.String(), 0)
+	assert.NoError(t, err)
+	assert.True(t, timedOut)
+	timedOut, err = d.TimeoutOrphanedAction(ctx, id.String(), 0)
+	assert.NoError(t, err)
+	assert.False(t, timedOut)
+
+	assert.NoError(t, d.DeleteWorkflow(ctx, id.String(), 0))
+	got, err = d.GetWorkflow(ctx, id.String())
+	assert.NoError(t, err)
+	assert.Empty(t, got.ID)
+	wfIDs, err = d.GetWorkflowsForWorker(hardwareID)
+	assert.NoError(t, err)
+	assert.Empty(t, wfIDs)
+}
+
+func TestWorkflowEvents(t *testing.T) {
+	ctx := context.Background()
+	d := New()
+	now := time.Now()
+	for i, name := range []string{"second", "first"} {
+		err := d.InsertIntoWorkflowEventTable(ctx, &pb.WorkflowActionStatus{
+			WorkflowId: "wf",
+			ActionName: name,
+		}, now.Add(-time.Duration(i)*time.Second))
+		assert.NoError(t, err)
+	}
+
+	var names []string
+	assert.NoError(t, d.ShowWorkflowEvents("wf", func(wfs *pb.WorkflowActionStatus) error {
+		names = append(names, wfs.GetActionName())
+		return nil
+	}))
+	assert.Equal(t, []string{"first", "second"}, names)
+}
+
+func TestWorkflowData(t *testing.T) {
+	ctx := context.Background()
+	d := New()
+	versions := db.MaxDataVersions() + 1
+	for i := 1; i <= versions; i++ {
+		err := d.InsertIntoWfDataTable(ctx, &pb.UpdateWorkflowDataRequest{
+			WorkflowId: "wf",
+			Metadata:   []byte(fmt.Sprintf(`{"version": %d}`, i)),
+			Data:       []byte(fmt.Sprintf(`{"data": %d}`, i)),
+		})
+		assert.NoError(t, err)
+	}
+
+	version, err := d.GetWorkflowDataVersion(ctx, "wf")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(versions), version)
+
+	testCases := map[string]struct {
+		version        int32
+		data, metadata string
+	}{
+		"latest": {
+			data:     fmt.Sprintf(`{"data": %d}`, versions),
+			metadata: fmt.Sprintf(`{"version": %d}`, versions),
+		},
+		"cleaned up": {
+			version:  1,
+			metadata: `{"version": 1}`,
+		},
+		"unknown": {
+			version: int32(versions + 1),
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := &pb.GetWorkflowDataRequest{WorkflowId: "wf", Version: tc.version}
+			data, err := d.GetfromWfDataTable(ctx, req)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.data, string(data))
+			metadata, err := d.GetWorkflowMetadata(ctx, req)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.metadata, string(metadata))
+		})
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	d := New()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := fmt.Sprintf("secret-%d", i)
+			assert.NoError(t, d.CreateSecret(ctx, name, []byte(name), time.Now()))
+			_, err := d.GetSecret(ctx, name)
+			assert.NoError(t, err)
+			assert.NoError(t, d.UpdateWorkerHeartbeat(ctx, &pb.HeartbeatRequest{WorkerId: name}, time.Now()))
+		}()
+	}
+	wg.Wait()
+
+	var secrets int
+	assert.NoError(t, d.ListSecrets(func(string, *timestamp.Timestamp, *timestamp.Timestamp) error {
+		secrets++
+		return nil
+	}))
+	assert.Equal(t, 10, secrets)
+}
@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+type secretRecord struct {
+	timestamps
+	value []byte
+}
+
+// CreateSecret creates a secret, or updates its value if it already exists.
+// The value is expected to be encrypted.
+func (d *DB) CreateSecret(ctx context.Context, name string, value []byte, time time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s, ok := d.secrets[name]
+	if !ok {
+		s = &secretRecord{timestamps: timestamps{createdAt: time}}
+		d.secrets[name] = s
+	}
+	s.updatedAt = time
+	s.value = append([]byte{}, value...)
+	return nil
+}
+
+// GetSecret returns the encrypted value of a secret
+func (d *DB) GetSecret(ctx context.Context, name string) ([]byte, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	s, ok := d.secrets[name]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return append([]byte{}, s.value...), nil
+}
+
+// DeleteSecret deletes a secret
+func (d *DB) DeleteSecret(ctx context.Context, name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.secrets, name)
+	return nil
+}
+
+// ListSecrets passes the names of all the secrets to fn, without their
+// values, sorted by name
+func (d *DB) ListSecrets(fn func(name string, in, up *timestamp.Timestamp) error) error {
+	type entry struct {
+		name   string
+		cr, up *timestamp.Timestamp
+	}
+	d.mu.RLock()
+	var all []entry
+	for name, s := range d.secrets {
+		e := entry{name: name}
+		e.cr, _ = ptypes.TimestampProto(s.createdAt)
+		e.up, _ = ptypes.TimestampProto(s.updatedAt)
+		all = append(all, e)
+	}
+	d.mu.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool { return all[i].name < all[j].name })
+	for _, e := range all {
+		if err := fn(e.name, e.cr, e.up); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/google/uuid"
+	wflow "github.com/tinkerbell/tink/workflow"
+)
+
+type templateRecord struct {
+	timestamps
+	name, data string
+}
+
+// CreateTemplate creates a new workflow template, or replaces the one with
+// the same id
+func (d *DB) CreateTemplate(ctx context.Context, name string, data string, id uuid.UUID) error {
+	_, err := wflow.Parse([]byte(data))
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	t, ok := d.templates[id.String()]
+	if !ok {
+		t = &templateRecord{timestamps: timestamps{createdAt: now}}
+		d.templates[id.String()] = t
+	}
+	t.updatedAt, t.deleted = now, false
+	t.name, t.data = name, data
+	return nil
+}
+
+// GetTemplate returns the name and data of a workflow template
+func (d *DB) GetTemplate(ctx context.Context, id string) (string, string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	t, ok := d.templates[id]
+	if !ok || t.deleted {
+		return "", "", sql.ErrNoRows
+	}
+	return t.name, t.data, nil
+}
+
+// GetTemplateByName returns the id and data of the latest updated workflow
+// template with the given name
+func (d *DB) GetTemplateByName(ctx context.Context, name string) (string, string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var (
+		id     string
+		latest *templateRecord
+	)
+	for tid, t := range d.templates {
+		if t.deleted || t.name != name {
+			continue
+		}
+		if latest == nil || t.updatedAt.After(latest.updatedAt) {
+			id, latest = tid, t
+		}
+	}
+	if latest == nil {
+		return "", "", sql.ErrNoRows
+	}
+	return id, latest.data, nil
+}
+
+// DeleteTemplate marks a workflow template as deleted
+func (d *DB) DeleteTemplate(ctx context.Context, id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if t, ok := d.templates[id]; ok {
+		t.deleted = true
+	}
+	return nil
+}
+
+// ListTemplates passes every workflow template to fn, oldest first
+func (d *DB) ListTemplates(fn func(id, n string, in, del *timestamp.Timestamp) error) error {
+	type entry struct {
+		id string
+		templateRecord
+	}
+	d.mu.RLock()
+	var all []entry
+	for id, t := range d.templates {
+		if !t.deleted {
+			all = append(all, entry{id: id, templateRecord: *t})
+		}
+	}
+	d.mu.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].createdAt.Equal(all[j].createdAt) {
+			return all[i].id < all[j].id
+		}
+		return all[i].createdAt.Before(all[j].createdAt)
+	})
+	for _, t := range all {
+		tCr, _ := ptypes.TimestampProto(t.createdAt)
+		tUp, _ := ptypes.TimestampProto(t.updatedAt)
+		if err := fn(t.id, t.name, tCr, tUp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateTemplate updates the name and/or the data of a workflow template
+func (d *DB) UpdateTemplate(ctx context.Context, name string, data string, id uuid.UUID) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	t, ok := d.templates[id.String()]
+	if !ok {
+		return nil
+	}
+	t.updatedAt = time.Now()
+	if name != "" || data == "" {
+		t.name = name
+	}
+	if data != "" || name == "" {
+		t.data = data
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+)
+
+type workerRecord struct {
+	bootID                 string
+	registeredAt, lastSeen time.Time
+	workflowID, actionName string
+}
+
+// seenAt returns the last time a worker was seen, or registered if it has
+// not sent any heartbeat yet
+func (w *workerRecord) seenAt() time.Time {
+	if w.lastSeen.IsZero() {
+		return w.registeredAt
+	}
+	return w.lastSeen
+}
+
+// RegisterWorker records the boot id a worker is running with and returns
+// the boot id it was previously registered with, if any
+func (d *DB) RegisterWorker(ctx context.Context, id, bootID string, time time.Time) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	w, ok := d.workers[id]
+	if !ok {
+		w = &workerRecord{}
+		d.workers[id] = w
+	}
+	prevBootID := w.bootID
+	w.bootID, w.registeredAt = bootID, time
+	return prevBootID, nil
+}
+
+// UpdateWorkerHeartbeat records the time a worker was last seen, along with
+// the action it is currently executing
+func (d *DB) UpdateWorkerHeartbeat(ctx context.Context, hb *pb.HeartbeatRequest, time time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	w, ok := d.workers[hb.GetWorkerId()]
+	if !ok {
+		w = &workerRecord{registeredAt: time}
+		d.workers[hb.GetWorkerId()] = w
+	}
+	w.lastSeen = time
+	w.workflowID, w.actionName = hb.GetWorkflowId(), hb.GetActionName()
+	return nil
+}
+
+// ListWorkers passes every known worker to fn, sorted by id
+func (d *DB) ListWorkers(fn func(w *pb.WorkerStatus) error) error {
+	d.mu.RLock()
+	var all []*pb.WorkerStatus
+	for id, w := range d.workers {
+		ws := &pb.WorkerStatus{
+			WorkerId:   id,
+			BootId:     w.bootID,
+			WorkflowId: w.workflowID,
+			ActionName: w.actionName,
+		}
+		ws.RegisteredAt, _ = ptypes.TimestampProto(w.registeredAt)
+		ws.LastSeen, _ = ptypes.TimestampProto(w.seenAt())
+		all = append(all, ws)
+	}
+	d.mu.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool { return all[i].WorkerId < all[j].WorkerId })
+	for _, ws := range all {
+		if err := fn(ws); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetOrphanedWorkflows returns the workflows with a running action whose
+// worker has not sent a heartbeat since the given time. The workers which
+// never sent a heartbeat have them disabled and are not considered.
+func (d *DB) GetOrphanedWorkflows(ctx context.Context, lastSeen time.Time) ([]string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var wfIDs []string
+	for wfID, s := range d.states {
+		if s.context.GetCurrentActionState() != pb.State_STATE_RUNNING {
+			continue
+		}
+		w, ok := d.workers[s.context.GetCurrentWorker()]
+		if ok && !w.lastSeen.IsZero() && w.lastSeen.Before(lastSeen) {
+			wfIDs = append(wfIDs, wfID)
+		}
+	}
+	sort.Strings(wfIDs)
+	return wfIDs, nil
+}
+
+// TimeoutOrphanedAction marks as timed out the running action of a workflow,
+// provided it is still the action at actionIndex and it is still running
+func (d *DB) TimeoutOrphanedAction(ctx context.Context, wfID string, actionIndex int64) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s, ok := d.states[wfID]
+	if !ok {
+		return false, nil
+	}
+	c := s.context
+	if c.GetCurrentActionIndex() != actionIndex || c.GetCurrentActionState() != pb.State_STATE_RUNNING {
+		return false, nil
+	}
+	c.CurrentActionState = pb.State_STATE_TIMEOUT
+	return true, nil
+}
@@ -0,0 +1,441 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/tink/db"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+	wflow "github.com/tinkerbell/tink/workflow"
+)
+
+type workflowRecord struct {
+	timestamps
+	template, hardware string
+	parameters         map[string]string
+	includes           []wflow.Include
+}
+
+type stateRecord struct {
+	context    *pb.WorkflowContext
+	actionList []byte
+}
+
+type workflowWorker struct {
+	workflowID, workerID string
+}
+
+type dataRecord struct {
+	metadata, data []byte
+}
+
+type eventRecord struct {
+	event     *pb.WorkflowActionStatus
+	createdAt time.Time
+}
+
+// CreateWorkflow creates a new workflow, along with its action list resolved
+// against the known hardware
+func (d *DB) CreateWorkflow(ctx context.Context, wf db.Workflow, data string, id uuid.UUID) error {
+	parsed, err := wflow.Parse([]byte(data))
+	if err != nil {
+		return errors.Wrap(err, "Failed to insert in workflow_state")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var (
+		actionList []*pb.WorkflowAction
+		workerIDs  []string
+	)
+	for _, task := range parsed.Tasks {
+		workerID, err := d.workerID(task.WorkerAddr)
+		if err != nil {
+			return errors.Wrap(err, "Failed to insert in workflow_state")
+		}
+		if !contains(workerIDs, workerID) {
+			workerIDs = append(workerIDs, workerID)
+		}
+		actionList = append(actionList, wflow.TaskActions(task, workerID)...)
+	}
+	actionData, err := json.Marshal(actionList)
+	if err != nil {
+		return errors.Wrap(err, "Failed to insert in workflow_state")
+	}
+
+	for _, workerID := range workerIDs {
+		if !d.hasWorkflowWorker(id.String(), workerID) {
+			d.workflowWorker = append(d.workflowWorker, workflowWorker{workflowID: id.String(), workerID: workerID})
+		}
+	}
+	d.states[id.String()] = &stateRecord{
+		context: &pb.WorkflowContext{
+			WorkflowId:           id.String(),
+			TotalNumberOfActions: int64(len(actionList)),
+		},
+		actionList: actionData,
+	}
+
+	now := time.Now()
+	w, ok := d.workflows[wf.ID]
+	if !ok {
+		w = &workflowRecord{timestamps: timestamps{createdAt: now}}
+		d.workflows[wf.ID] = w
+	}
+	w.updatedAt, w.deleted = now, false
+	w.template, w.hardware = wf.Template, wf.Hardware
+	w.parameters = map[string]string{}
+	for k, v := range wf.Parameters {
+		w.parameters[k] = v
+	}
+	w.includes = append([]wflow.Include{}, wf.Includes...)
+	return nil
+}
+
+// InsertIntoWfDataTable stores a new version of the ephemeral data of a
+// workflow, and drops the data of the versions beyond db.MaxDataVersions
+func (d *DB) InsertIntoWfDataTable(ctx context.Context, req *pb.UpdateWorkflowDataRequest) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	wfID := req.GetWorkflowId()
+	versions := append(d.data[wfID], dataRecord{
+		metadata: append([]byte{}, req.GetMetadata()...),
+		data:     append([]byte{}, req.GetData()...),
+	})
+	if clean := len(versions) - db.MaxDataVersions(); clean > 0 {
+		versions[clean-1].data = nil
+	}
+	d.data[wfID] = versions
+	return nil
+}
+
+// GetfromWfDataTable returns the ephemeral data of a workflow at the given
+// version, the latest one if zero
+func (d *DB) GetfromWfDataTable(ctx context.Context, req *pb.GetWorkflowDataRequest) ([]byte, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	v, ok := d.dataVersion(req.GetWorkflowId(), req.GetVersion())
+	if !ok {
+		return []byte{}, nil
+	}
+	return append([]byte{}, v.data...), nil
+}
+
+// GetWorkflowMetadata returns the metadata of the ephemeral data of a
+// workflow at the given version, the latest one if zero
+func (d *DB) GetWorkflowMetadata(ctx context.Context, req *pb.GetWorkflowDataRequest) ([]byte, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	v, ok := d.dataVersion(req.GetWorkflowId(), req.GetVersion())
+	if !ok {
+		return []byte{}, nil
+	}
+	return append([]byte{}, v.metadata...), nil
+}
+
+// GetWorkflowDataVersion returns the latest version of data for a workflow
+func (d *DB) GetWorkflowDataVersion(ctx context.Context, workflowID string) (int32, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return int32(len(d.data[workflowID])), nil
+}
+
+// GetWorkflowsForWorker returns the workflows a worker has tasks in
+func (d *DB) GetWorkflowsForWorker(id string) ([]string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var wfIDs []string
+	for _, ww := range d.workflowWorker {
+		if ww.workerID == id {
+			wfIDs = append(wfIDs, ww.workflowID)
+		}
+	}
+	return wfIDs, nil
+}
+
+// GetWorkflow returns a workflow, empty if it does not exist
+func (d *DB) GetWorkflow(ctx context.Context, id string) (db.Workflow, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	w, ok := d.workflows[id]
+	if !ok || w.deleted {
+		return db.Workflow{}, nil
+	}
+	return w.workflow(id), nil
+}
+
+// DeleteWorkflow drops the state of a workflow and marks it as deleted
+func (d *DB) DeleteWorkflow(ctx context.Context, id string, state int32) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	kept := d.workflowWorker[:0]
+	for _, ww := range d.workflowWorker {
+		if ww.workflowID != id {
+			kept = append(kept, ww)
+		}
+	}
+	d.workflowWorker = kept
+	delete(d.states, id)
+	if w, ok := d.workflows[id]; ok {
+		w.deleted = true
+	}
+	return nil
+}
+
+// ListWorkflows passes every workflow to fn, oldest first
+func (d *DB) ListWorkflows(fn func(wf db.Workflow) error) error {
+	type entry struct {
+		id string
+		workflowRecord
+	}
+	d.mu.RLock()
+	var all []entry
+	for id, w := range d.workflows {
+		if !w.deleted {
+			all = append(all, entry{id: id, workflowRecord: *w})
+		}
+	}
+	d.mu.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].createdAt.Equal(all[j].createdAt) {
+			return all[i].id < all[j].id
+		}
+		return all[i].createdAt.Before(all[j].createdAt)
+	})
+	for _, w := range all {
+		if err := fn(w.workflow(w.id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateWorkflow updates the template and/or the hardware of a workflow
+func (d *DB) UpdateWorkflow(ctx context.Context, wf db.Workflow, state int32) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	w, ok := d.workflows[wf.ID]
+	if !ok {
+		return nil
+	}
+	w.updatedAt = time.Now()
+	if wf.Template != "" || wf.Hardware == "" {
+		w.template = wf.Template
+	}
+	if wf.Hardware != "" || wf.Template == "" {
+		w.hardware = wf.Hardware
+	}
+	return nil
+}
+
+// UpdateWorkflowState updates the current state of a workflow
+func (d *DB) UpdateWorkflowState(ctx context.Context, wfContext *pb.WorkflowContext) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.updateWorkflowState(wfContext)
+	return nil
+}
+
+func (d *DB) updateWorkflowState(wfContext *pb.WorkflowContext) {
+	s, ok := d.states[wfContext.GetWorkflowId()]
+	if !ok {
+		return
+	}
+	c := s.context
+	c.CurrentTask = wfContext.GetCurrentTask()
+	c.CurrentAction = wfContext.GetCurrentAction()
+	c.CurrentActionState = wfContext.GetCurrentActionState()
+	c.CurrentWorker = wfContext.GetCurrentWorker()
+	c.CurrentActionIndex = wfContext.GetCurrentActionIndex()
+	c.CurrentActionProgress = wfContext.GetCurrentActionProgress()
+	c.CurrentActionMessage = wfContext.GetCurrentActionMessage()
+}
+
+// UpdateWorkflowPaused pauses or resumes a workflow
+func (d *DB) UpdateWorkflowPaused(ctx context.Context, wfID string, paused bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if s, ok := d.states[wfID]; ok {
+		s.context.Paused = paused
+	}
+	return nil
+}
+
+// GetWorkflowContexts returns the current state of a workflow, empty if it
+// does not exist
+func (d *DB) GetWorkflowContexts(ctx context.Context, wfID string) (*pb.WorkflowContext, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	s, ok := d.states[wfID]
+	if !ok {
+		return &pb.WorkflowContext{}, nil
+	}
+	return proto.Clone(s.context).(*pb.WorkflowContext), nil
+}
+
+// GetWorkflowActions returns the action list of a workflow, empty if it does
+// not exist
+func (d *DB) GetWorkflowActions(ctx context.Context, wfID string) (*pb.WorkflowActionList, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	s, ok := d.states[wfID]
+	if !ok {
+		return &pb.WorkflowActionList{}, nil
+	}
+	actions := []*pb.WorkflowAction{}
+	if err := json.Unmarshal(s.actionList, &actions); err != nil {
+		return nil, err
+	}
+	return &pb.WorkflowActionList{ActionList: actions}, nil
+}
+
+// InsertIntoWorkflowEventTable records an event of a workflow
+func (d *DB) InsertIntoWorkflowEventTable(ctx context.Context, wfEvent *pb.WorkflowActionStatus, time time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	event := &pb.WorkflowActionStatus{
+		WorkerId:     wfEvent.GetWorkerId(),
+		TaskName:     wfEvent.GetTaskName(),
+		ActionName:   wfEvent.GetActionName(),
+		Seconds:      wfEvent.GetSeconds(),
+		Message:      wfEvent.GetMessage(),
+		ActionStatus: wfEvent.GetActionStatus(),
+	}
+	wfID := wfEvent.GetWorkflowId()
+	d.events[wfID] = append(d.events[wfID], eventRecord{event: event, createdAt: time})
+	return nil
+}
+
+// ShowWorkflowEvents passes the events of a workflow to fn, oldest first
+func (d *DB) ShowWorkflowEvents(wfID string, fn func(wfs *pb.WorkflowActionStatus) error) error {
+	d.mu.RLock()
+	events := append([]eventRecord{}, d.events[wfID]...)
+	d.mu.RUnlock()
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].createdAt.Before(events[j].createdAt)
+	})
+	for _, e := range events {
+		wfs := proto.Clone(e.event).(*pb.WorkflowActionStatus)
+		wfs.CreatedAt, _ = ptypes.TimestampProto(e.createdAt)
+		if err := fn(wfs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateWorkflowStateWithOutputs updates the current state of a workflow
+// along with the outputs reported by its action
+func (d *DB) UpdateWorkflowStateWithOutputs(ctx context.Context, wfContext *pb.WorkflowContext, wfOutput *pb.WorkflowActionStatus, time time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.updateWorkflowState(wfContext)
+	if len(wfOutput.GetOutputs()) == 0 {
+		return nil
+	}
+	wfID := wfOutput.GetWorkflowId()
+	if d.outputs[wfID] == nil {
+		d.outputs[wfID] = map[string]map[string]string{}
+	}
+	outputs := d.outputs[wfID][wfOutput.GetActionName()]
+	if outputs == nil {
+		outputs = map[string]string{}
+		d.outputs[wfID][wfOutput.GetActionName()] = outputs
+	}
+	for name, value := range wfOutput.GetOutputs() {
+		outputs[name] = value
+	}
+	return nil
+}
+
+// GetWorkflowOutputs returns the outputs reported by the actions of a
+// workflow, keyed by action name
+func (d *DB) GetWorkflowOutputs(ctx context.Context, wfID string) (map[string]map[string]string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	outputs := map[string]map[string]string{}
+	for aName, values := range d.outputs[wfID] {
+		outputs[aName] = map[string]string{}
+		for name, value := range values {
+			outputs[aName][name] = value
+		}
+	}
+	return outputs, nil
+}
+
+func (w *workflowRecord) workflow(id string) db.Workflow {
+	wf := db.Workflow{ID: id, Template: w.template, Hardware: w.hardware, Parameters: map[string]string{}}
+	for k, v := range w.parameters {
+		wf.Parameters[k] = v
+	}
+	wf.Includes = append([]wflow.Include{}, w.includes...)
+	wf.CreatedAt, _ = ptypes.TimestampProto(w.createdAt)
+	wf.UpdatedAt, _ = ptypes.TimestampProto(w.updatedAt)
+	return wf
+}
+
+// dataVersion returns a version of the ephemeral data of a workflow, the
+// latest one if zero
+func (d *DB) dataVersion(wfID string, version int32) (dataRecord, bool) {
+	versions := d.data[wfID]
+	if version == 0 {
+		version = int32(len(versions))
+	}
+	if version < 1 || int(version) > len(versions) {
+		return dataRecord{}, false
+	}
+	return versions[version-1], true
+}
+
+func (d *DB) hasWorkflowWorker(wfID, workerID string) bool {
+	for _, ww := range d.workflowWorker {
+		if ww.workflowID == wfID && ww.workerID == workerID {
+			return true
+		}
+	}
+	return false
+}
+
+// workerID returns the id of the hardware with the given mac or ip address
+func (d *DB) workerID(addr string) (string, error) {
+	var id string
+	if _, err := net.ParseMAC(addr); err == nil {
+		id, _ = d.hardwareByMAC(addr)
+	} else {
+		ip := net.ParseIP(addr)
+		if ip == nil || ip.To4() == nil {
+			return "", fmt.Errorf("invalid worker address: %s", addr)
+		}
+		id, _ = d.hardwareByIP(addr)
+	}
+	if id == "" {
+		return "", fmt.Errorf("hardware mentioned with reference %s not found", addr)
+	}
+	return id, nil
+}
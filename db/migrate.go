@@ -0,0 +1,61 @@
+package db
+
+import (
+	"time"
+
+	migrate "github.com/rubenv/sql-migrate"
+	"github.com/tinkerbell/tink/db/migration"
+)
+
+const dialect = "postgres"
+
+// MigrationStatus is the state of a migration in the database
+type MigrationStatus struct {
+	ID string
+	// AppliedAt is when the migration was applied, nil while it is pending
+	AppliedAt *time.Time
+	// Unknown is set for the migrations applied by a newer tink-server
+	Unknown bool
+}
+
+// MigrationStatus returns the state of all the migrations, in the order
+// they are applied in
+func (t *TinkDB) MigrationStatus() ([]MigrationStatus, error) {
+	records, err := migrate.GetMigrationRecords(t.instance, dialect)
+	if err != nil {
+		return nil, err
+	}
+	applied := map[string]time.Time{}
+	for _, r := range records {
+		applied[r.Id] = r.AppliedAt
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range migration.GetMigrations().Migrations {
+		status := MigrationStatus{ID: m.Id}
+		if at, ok := applied[m.Id]; ok {
+			status.AppliedAt = &at
+			delete(applied, m.Id)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, r := range records {
+		if at, ok := applied[r.Id]; ok {
+			statuses = append(statuses, MigrationStatus{ID: r.Id, AppliedAt: &at, Unknown: true})
+		}
+	}
+	return statuses, nil
+}
+
+// PlanMigrations returns the migrations which would run in the given
+// direction, along with their statements, at most max of them unless 0
+func (t *TinkDB) PlanMigrations(dir migrate.MigrationDirection, max int) ([]*migrate.PlannedMigration, error) {
+	planned, _, err := migrate.PlanMigration(t.instance, dialect, migration.GetMigrations(), dir, max)
+	return planned, err
+}
+
+// ApplyMigrations runs the migrations in the given direction, at most max
+// of them unless 0, and returns the number of migrations run
+func (t *TinkDB) ApplyMigrations(dir migrate.MigrationDirection, max int) (int, error) {
+	return migrate.ExecMax(t.instance, dialect, migration.GetMigrations(), dir, max)
+}
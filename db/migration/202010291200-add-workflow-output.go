@@ -0,0 +1,24 @@
+package migration
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func Get202010291200() *migrate.Migration {
+	return &migrate.Migration{
+		Id: "202010291200-add-workflow-output",
+		Up: []string{`
+CREATE TABLE IF NOT EXISTS workflow_output (
+	workflow_id UUID NOT NULL
+	, task_name VARCHAR(200)
+	, action_name VARCHAR(200)
+	, name VARCHAR(200)
+	, value TEXT
+	, created_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uidx_workflow_output ON workflow_output (workflow_id, action_name, name);
+`},
+		Down: []string{`
+DROP TABLE IF EXISTS workflow_output;
+`},
+	}
+}
@@ -0,0 +1,19 @@
+package migration
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func Get202011021500() *migrate.Migration {
+	return &migrate.Migration{
+		Id: "202011021500-add-worker",
+		Up: []string{`
+CREATE TABLE IF NOT EXISTS worker (
+	id UUID UNIQUE NOT NULL
+	, boot_id VARCHAR(200)
+	, registered_at TIMESTAMPTZ
+);
+`},
+		Down: []string{`
+DROP TABLE IF EXISTS worker;
+`},
+	}
+}
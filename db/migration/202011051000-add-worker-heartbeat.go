@@ -0,0 +1,23 @@
+package migration
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func Get202011051000() *migrate.Migration {
+	return &migrate.Migration{
+		Id: "202011051000-add-worker-heartbeat",
+		Up: []string{`
+ALTER TABLE worker ADD COLUMN IF NOT EXISTS last_seen TIMESTAMPTZ;
+ALTER TABLE worker ADD COLUMN IF NOT EXISTS workflow_id VARCHAR(200);
+ALTER TABLE worker ADD COLUMN IF NOT EXISTS action_name VARCHAR(200);
+
+CREATE INDEX IF NOT EXISTS idx_worker_last_seen ON worker (last_seen);
+`},
+		Down: []string{`
+DROP INDEX IF EXISTS idx_worker_last_seen;
+
+ALTER TABLE worker DROP COLUMN IF EXISTS action_name;
+ALTER TABLE worker DROP COLUMN IF EXISTS workflow_id;
+ALTER TABLE worker DROP COLUMN IF EXISTS last_seen;
+`},
+	}
+}
@@ -0,0 +1,20 @@
+package migration
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func Get202011091000() *migrate.Migration {
+	return &migrate.Migration{
+		Id: "202011091000-add-secret",
+		Up: []string{`
+CREATE TABLE IF NOT EXISTS secret (
+	name VARCHAR(200) UNIQUE NOT NULL
+	, value BYTEA NOT NULL
+	, created_at TIMESTAMPTZ
+	, updated_at TIMESTAMPTZ
+);
+`},
+		Down: []string{`
+DROP TABLE IF EXISTS secret;
+`},
+	}
+}
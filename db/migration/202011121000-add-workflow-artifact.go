@@ -0,0 +1,26 @@
+package migration
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func Get202011121000() *migrate.Migration {
+	return &migrate.Migration{
+		Id: "202011121000-add-workflow-artifact",
+		Up: []string{`
+CREATE TABLE IF NOT EXISTS workflow_artifact (
+	workflow_id UUID NOT NULL
+	, worker_id UUID NOT NULL
+	, task_name VARCHAR(200)
+	, action_name VARCHAR(200)
+	, name VARCHAR(200)
+	, size BIGINT
+	, blob_key VARCHAR(300) NOT NULL
+	, created_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uidx_workflow_artifact ON workflow_artifact (workflow_id, action_name, name);
+`},
+		Down: []string{`
+DROP TABLE IF EXISTS workflow_artifact;
+`},
+	}
+}
@@ -0,0 +1,17 @@
+package migration
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func Get202011161000() *migrate.Migration {
+	return &migrate.Migration{
+		Id: "202011161000-add-action-progress",
+		Up: []string{`
+ALTER TABLE workflow_state ADD COLUMN IF NOT EXISTS current_action_progress SMALLINT;
+ALTER TABLE workflow_state ADD COLUMN IF NOT EXISTS current_action_message VARCHAR(200);
+`},
+		Down: []string{`
+ALTER TABLE workflow_state DROP COLUMN IF EXISTS current_action_message;
+ALTER TABLE workflow_state DROP COLUMN IF EXISTS current_action_progress;
+`},
+	}
+}
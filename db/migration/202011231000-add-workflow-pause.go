@@ -0,0 +1,15 @@
+package migration
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func Get202011231000() *migrate.Migration {
+	return &migrate.Migration{
+		Id: "202011231000-add-workflow-pause",
+		Up: []string{`
+ALTER TABLE workflow_state ADD COLUMN IF NOT EXISTS paused BOOLEAN NOT NULL DEFAULT FALSE;
+`},
+		Down: []string{`
+ALTER TABLE workflow_state DROP COLUMN IF EXISTS paused;
+`},
+	}
+}
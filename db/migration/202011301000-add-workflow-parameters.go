@@ -0,0 +1,15 @@
+package migration
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func Get202011301000() *migrate.Migration {
+	return &migrate.Migration{
+		Id: "202011301000-add-workflow-parameters",
+		Up: []string{`
+ALTER TABLE workflow ADD COLUMN IF NOT EXISTS parameters JSONB NOT NULL DEFAULT '{}';
+`},
+		Down: []string{`
+ALTER TABLE workflow DROP COLUMN IF EXISTS parameters;
+`},
+	}
+}
@@ -0,0 +1,15 @@
+package migration
+
+import migrate "github.com/rubenv/sql-migrate"
+
+func Get202012031000() *migrate.Migration {
+	return &migrate.Migration{
+		Id: "202012031000-add-workflow-includes",
+		Up: []string{`
+ALTER TABLE workflow ADD COLUMN IF NOT EXISTS includes JSONB NOT NULL DEFAULT '[]';
+`},
+		Down: []string{`
+ALTER TABLE workflow DROP COLUMN IF EXISTS includes;
+`},
+	}
+}
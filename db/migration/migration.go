@@ -7,6 +7,15 @@ func GetMigrations() *migrate.MemoryMigrationSource {
 		Migrations: []*migrate.Migration{
 			Get202009171251(),
 			Get202010221010(),
+			Get202010291200(),
+			Get202011021500(),
+			Get202011051000(),
+			Get202011091000(),
+			Get202011121000(),
+			Get202011161000(),
+			Get202011231000(),
+			Get202011301000(),
+			Get202012031000(),
 		},
 	}
 }
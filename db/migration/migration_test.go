@@ -0,0 +1,18 @@
+package migration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrations(t *testing.T) {
+	migrations := GetMigrations().Migrations
+	for i, m := range migrations {
+		assert.NotEmpty(t, m.Up, m.Id)
+		assert.NotEmpty(t, m.Down, m.Id)
+		if i > 0 {
+			assert.True(t, migrations[i-1].Less(m), "%s is applied before %s", migrations[i-1].Id, m.Id)
+		}
+	}
+}
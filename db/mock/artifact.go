@@ -0,0 +1,23 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	pb "github.com/tinkerbell/tink/protos/workflow"
+)
+
+// InsertIntoWorkflowArtifactTable records an artifact uploaded for an action
+func (d DB) InsertIntoWorkflowArtifactTable(ctx context.Context, a *pb.Artifact, key string, time time.Time) (string, error) {
+	return d.InsertIntoWorkflowArtifactTableFunc(ctx, a, key, time)
+}
+
+// GetWorkflowArtifactKey returns the blob key of an artifact
+func (d DB) GetWorkflowArtifactKey(ctx context.Context, wfID, actionName, name string) (string, error) {
+	return d.GetWorkflowArtifactKeyFunc(ctx, wfID, actionName, name)
+}
+
+// ListWorkflowArtifacts returns the artifacts uploaded for a workflow
+func (d DB) ListWorkflowArtifacts(ctx context.Context, wfID string, fn func(a *pb.Artifact) error) error {
+	return nil
+}
@@ -12,17 +12,32 @@ import (
 // DB is the mocked implementation of Database interface
 type DB struct {
 	// workflow
-	CreateWorkflowFunc               func(ctx context.Context, wf db.Workflow, data string, id uuid.UUID) error
-	GetfromWfDataTableFunc           func(ctx context.Context, req *pb.GetWorkflowDataRequest) ([]byte, error)
-	InsertIntoWfDataTableFunc        func(ctx context.Context, req *pb.UpdateWorkflowDataRequest) error
-	GetWorkflowMetadataFunc          func(ctx context.Context, req *pb.GetWorkflowDataRequest) ([]byte, error)
-	GetWorkflowDataVersionFunc       func(ctx context.Context, workflowID string) (int32, error)
-	GetWorkflowsForWorkerFunc        func(id string) ([]string, error)
-	GetWorkflowContextsFunc          func(ctx context.Context, wfID string) (*pb.WorkflowContext, error)
-	GetWorkflowActionsFunc           func(ctx context.Context, wfID string) (*pb.WorkflowActionList, error)
-	UpdateWorkflowStateFunc          func(ctx context.Context, wfContext *pb.WorkflowContext) error
-	InsertIntoWorkflowEventTableFunc func(ctx context.Context, wfEvent *pb.WorkflowActionStatus, time time.Time) error
+	CreateWorkflowFunc                 func(ctx context.Context, wf db.Workflow, data string, id uuid.UUID) error
+	GetfromWfDataTableFunc             func(ctx context.Context, req *pb.GetWorkflowDataRequest) ([]byte, error)
+	InsertIntoWfDataTableFunc          func(ctx context.Context, req *pb.UpdateWorkflowDataRequest) error
+	GetWorkflowMetadataFunc            func(ctx context.Context, req *pb.GetWorkflowDataRequest) ([]byte, error)
+	GetWorkflowDataVersionFunc         func(ctx context.Context, workflowID string) (int32, error)
+	GetWorkflowsForWorkerFunc          func(id string) ([]string, error)
+	GetWorkflowContextsFunc            func(ctx context.Context, wfID string) (*pb.WorkflowContext, error)
+	GetWorkflowActionsFunc             func(ctx context.Context, wfID string) (*pb.WorkflowActionList, error)
+	UpdateWorkflowStateFunc            func(ctx context.Context, wfContext *pb.WorkflowContext) error
+	UpdateWorkflowPausedFunc           func(ctx context.Context, wfID string, paused bool) error
+	InsertIntoWorkflowEventTableFunc   func(ctx context.Context, wfEvent *pb.WorkflowActionStatus, time time.Time) error
+	UpdateWorkflowStateWithOutputsFunc func(ctx context.Context, wfContext *pb.WorkflowContext, wfOutput *pb.WorkflowActionStatus, time time.Time) error
+	GetWorkflowOutputsFunc             func(ctx context.Context, wfID string) (map[string]map[string]string, error)
+	// worker
+	RegisterWorkerFunc        func(ctx context.Context, id, bootID string, time time.Time) (string, error)
+	UpdateWorkerHeartbeatFunc func(ctx context.Context, hb *pb.HeartbeatRequest, time time.Time) error
+	GetOrphanedWorkflowsFunc  func(ctx context.Context, lastSeen time.Time) ([]string, error)
+	TimeoutOrphanedActionFunc func(ctx context.Context, wfID string, actionIndex int64) (bool, error)
+	// artifact
+	InsertIntoWorkflowArtifactTableFunc func(ctx context.Context, a *pb.Artifact, key string, time time.Time) (string, error)
+	GetWorkflowArtifactKeyFunc          func(ctx context.Context, wfID, actionName, name string) (string, error)
+	// secret
+	CreateSecretFunc func(ctx context.Context, name string, value []byte, time time.Time) error
+	GetSecretFunc    func(ctx context.Context, name string) ([]byte, error)
 	// template
-	TemplateDB      map[string]interface{}
-	GetTemplateFunc func(ctx context.Context, id string) (string, string, error)
+	TemplateDB            map[string]interface{}
+	GetTemplateFunc       func(ctx context.Context, id string) (string, string, error)
+	GetTemplateByNameFunc func(ctx context.Context, name string) (string, string, error)
 }
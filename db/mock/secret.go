@@ -0,0 +1,28 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+// CreateSecret creates or updates a secret
+func (d DB) CreateSecret(ctx context.Context, name string, value []byte, time time.Time) error {
+	return d.CreateSecretFunc(ctx, name, value, time)
+}
+
+// GetSecret returns the encrypted value of a secret
+func (d DB) GetSecret(ctx context.Context, name string) ([]byte, error) {
+	return d.GetSecretFunc(ctx, name)
+}
+
+// DeleteSecret deletes a secret
+func (d DB) DeleteSecret(ctx context.Context, name string) error {
+	return nil
+}
+
+// ListSecrets returns the names of all the secrets
+func (d DB) ListSecrets(fn func(name string, in, up *timestamp.Timestamp) error) error {
+	return nil
+}
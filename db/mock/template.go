@@ -36,6 +36,11 @@ func (d DB) GetTemplate(ctx context.Context, id string) (string, string, error)
 	return d.GetTemplateFunc(ctx, id)
 }
 
+// GetTemplateByName returns the id and data of a workflow template
+func (d DB) GetTemplateByName(ctx context.Context, name string) (string, string, error) {
+	return d.GetTemplateByNameFunc(ctx, name)
+}
+
 // DeleteTemplate deletes a workflow template
 func (d DB) DeleteTemplate(ctx context.Context, name string) error {
 	if d.TemplateDB != nil {
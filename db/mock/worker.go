@@ -0,0 +1,33 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	pb "github.com/tinkerbell/tink/protos/workflow"
+)
+
+// RegisterWorker records the boot id a worker is running with
+func (d DB) RegisterWorker(ctx context.Context, id, bootID string, time time.Time) (string, error) {
+	return d.RegisterWorkerFunc(ctx, id, bootID, time)
+}
+
+// UpdateWorkerHeartbeat records the time a worker was last seen
+func (d DB) UpdateWorkerHeartbeat(ctx context.Context, hb *pb.HeartbeatRequest, time time.Time) error {
+	return d.UpdateWorkerHeartbeatFunc(ctx, hb, time)
+}
+
+// ListWorkers returns all the known workers
+func (d DB) ListWorkers(fn func(w *pb.WorkerStatus) error) error {
+	return nil
+}
+
+// GetOrphanedWorkflows returns the workflows with a running action on a silent worker
+func (d DB) GetOrphanedWorkflows(ctx context.Context, lastSeen time.Time) ([]string, error) {
+	return d.GetOrphanedWorkflowsFunc(ctx, lastSeen)
+}
+
+// TimeoutOrphanedAction marks as timed out the running action of a workflow
+func (d DB) TimeoutOrphanedAction(ctx context.Context, wfID string, actionIndex int64) (bool, error) {
+	return d.TimeoutOrphanedActionFunc(ctx, wfID, actionIndex)
+}
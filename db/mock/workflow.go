@@ -64,6 +64,11 @@ func (d DB) UpdateWorkflowState(ctx context.Context, wfContext *pb.WorkflowConte
 	return d.UpdateWorkflowStateFunc(ctx, wfContext)
 }
 
+// UpdateWorkflowPaused pauses or resumes a workflow
+func (d DB) UpdateWorkflowPaused(ctx context.Context, wfID string, paused bool) error {
+	return d.UpdateWorkflowPausedFunc(ctx, wfID, paused)
+}
+
 // GetWorkflowContexts : gives you the current workflow context
 func (d DB) GetWorkflowContexts(ctx context.Context, wfID string) (*pb.WorkflowContext, error) {
 	return d.GetWorkflowContextsFunc(ctx, wfID)
@@ -83,3 +88,13 @@ func (d DB) InsertIntoWorkflowEventTable(ctx context.Context, wfEvent *pb.Workfl
 func (d DB) ShowWorkflowEvents(wfID string, fn func(wfs *pb.WorkflowActionStatus) error) error {
 	return nil
 }
+
+// UpdateWorkflowStateWithOutputs : update the current workflow state along with the outputs reported by its action
+func (d DB) UpdateWorkflowStateWithOutputs(ctx context.Context, wfContext *pb.WorkflowContext, wfOutput *pb.WorkflowActionStatus, time time.Time) error {
+	return d.UpdateWorkflowStateWithOutputsFunc(ctx, wfContext, wfOutput, time)
+}
+
+// GetWorkflowOutputs : returns the outputs reported by the actions of a workflow
+func (d DB) GetWorkflowOutputs(ctx context.Context, wfID string) (map[string]map[string]string, error) {
+	return d.GetWorkflowOutputsFunc(ctx, wfID)
+}
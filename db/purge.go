@@ -0,0 +1,255 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	blob "github.com/tinkerbell/tink/artifact"
+)
+
+// purgeBatchSize is the maximum number of rows deleted by a statement of a
+// purge, so that the purge never holds the locks of a whole table
+const purgeBatchSize = 1000
+
+// RetentionPolicy defines how long the rows which are no longer used are
+// kept in the database. A zero value keeps them forever.
+type RetentionPolicy struct {
+	// Deleted is how long the soft-deleted hardware, templates and
+	// workflows are kept after their deletion
+	Deleted time.Duration
+	// Events is how long the workflow events are kept
+	Events time.Duration
+	// DataVersions is the number of latest versions of the ephemeral data
+	// kept for each workflow
+	DataVersions int
+}
+
+// IsZero reports whether the policy keeps everything forever
+func (p RetentionPolicy) IsZero() bool {
+	return p.Deleted <= 0 && p.Events <= 0 && p.DataVersions <= 0
+}
+
+// PurgeResult holds the number of rows deleted by a purge
+type PurgeResult struct {
+	Hardware     int64
+	Templates    int64
+	Workflows    int64
+	Events       int64
+	DataVersions int64
+	Artifacts    int64
+}
+
+// Purge hard-deletes the rows which expired according to the retention
+// policy, relatively to now. The events, data, outputs and artifacts of the
+// purged workflows are deleted along with them, the content of the artifacts
+// being deleted from blobs. When blobs is nil the artifacts of the purged
+// workflows are kept.
+//
+// The rows are deleted in batches, each in its own READ COMMITTED
+// transaction, so that a purge does not conflict with the requests served
+// meanwhile. An interrupted purge is resumed by the next one.
+func (d TinkDB) Purge(ctx context.Context, policy RetentionPolicy, now time.Time, blobs blob.Store) (PurgeResult, error) {
+	var result PurgeResult
+	var err error
+
+	if policy.Deleted > 0 {
+		deletedBefore := now.Add(-policy.Deleted)
+		result.Hardware, err = d.deleteBatches(ctx, `
+		DELETE FROM hardware
+		WHERE
+			ctid IN (SELECT ctid FROM hardware WHERE deleted_at < $1 LIMIT $2);
+		`, deletedBefore)
+		if err != nil {
+			return result, err
+		}
+		result.Templates, err = d.deleteBatches(ctx, `
+		DELETE FROM template
+		WHERE
+			ctid IN (SELECT ctid FROM template WHERE deleted_at < $1 LIMIT $2);
+		`, deletedBefore)
+		if err != nil {
+			return result, err
+		}
+		for {
+			n, err := d.purgeWorkflows(ctx, deletedBefore, blobs, &result)
+			if err != nil {
+				return result, err
+			}
+			if n < purgeBatchSize {
+				break
+			}
+		}
+	}
+
+	if policy.Events > 0 {
+		result.Events, err = d.deleteBatches(ctx, `
+		DELETE FROM workflow_event
+		WHERE
+			ctid IN (SELECT ctid FROM workflow_event WHERE created_at < $1 LIMIT $2);
+		`, now.Add(-policy.Events))
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if policy.DataVersions > 0 {
+		result.DataVersions, err = d.deleteBatches(ctx, `
+		DELETE FROM workflow_data
+		WHERE
+			ctid IN (
+				SELECT d.ctid
+				FROM workflow_data d
+				JOIN (
+					SELECT workflow_id, MAX(version) AS latest
+					FROM workflow_data
+					GROUP BY workflow_id
+				) l
+				ON d.workflow_id = l.workflow_id
+				WHERE d.version <= l.latest - $1
+				LIMIT $2
+			);
+		`, policy.DataVersions)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// deleteBatches runs a DELETE statement, taking the batch size as its last
+// argument, until it deletes less rows than a batch. It returns the number
+// of rows deleted.
+func (d TinkDB) deleteBatches(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	var total int64
+	args = append(args, purgeBatchSize)
+	for {
+		res, err := d.instance.ExecContext(ctx, query, args...)
+		if err != nil {
+			return total, errors.Wrap(err, "DELETE")
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < purgeBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// purgeWorkflows hard-deletes a batch of the workflows deleted before the
+// given time, along with their rows in the other tables, and then the
+// content of their artifacts. It returns the number of workflows deleted.
+func (d TinkDB) purgeWorkflows(ctx context.Context, deletedBefore time.Time, blobs blob.Store, result *PurgeResult) (int, error) {
+	tx, err := d.instance.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return 0, errors.Wrap(err, "BEGIN transaction")
+	}
+
+	var ids []string
+	rows, err := tx.QueryContext(ctx, `
+	SELECT id
+	FROM workflow
+	WHERE
+		deleted_at < $1
+	LIMIT $2
+	FOR UPDATE;
+	`, deletedBefore, purgeBatchSize)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, errors.Wrap(err, "SELECT")
+	}
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	rows.Close()
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, errors.Wrap(err, "SELECT")
+	}
+	if len(ids) == 0 {
+		return 0, tx.Rollback()
+	}
+
+	for _, table := range []string{"workflow_event", "workflow_data", "workflow_output"} {
+		_, err = tx.ExecContext(ctx, `
+		DELETE FROM `+table+`
+		WHERE
+			workflow_id = ANY($1);
+		`, pq.Array(ids))
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, errors.Wrap(err, "DELETE")
+		}
+	}
+
+	var keys []string
+	if blobs != nil {
+		rows, err := tx.QueryContext(ctx, `
+		DELETE FROM workflow_artifact
+		WHERE
+			workflow_id = ANY($1)
+		RETURNING blob_key;
+		`, pq.Array(ids))
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, errors.Wrap(err, "DELETE")
+		}
+		for rows.Next() {
+			var key string
+			if err = rows.Scan(&key); err != nil {
+				break
+			}
+			keys = append(keys, key)
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+		rows.Close()
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, errors.Wrap(err, "DELETE")
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `
+	DELETE FROM workflow
+	WHERE
+		id = ANY($1);
+	`, pq.Array(ids))
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, errors.Wrap(err, "DELETE")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "COMMIT")
+	}
+	result.Workflows += n
+	result.Artifacts += int64(len(keys))
+
+	// the rows are already deleted, the blobs which cannot be deleted are
+	// left over and reported once the others are deleted
+	var blobErr error
+	for _, key := range keys {
+		if err := blobs.Delete(ctx, key); err != nil && blobErr == nil {
+			blobErr = errors.Wrap(err, "delete artifact "+key)
+		}
+	}
+	return len(ids), blobErr
+}
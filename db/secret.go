@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/pkg/errors"
+)
+
+// CreateSecret creates a secret, or updates its value if it already exists.
+// The value is expected to be encrypted.
+func (d TinkDB) CreateSecret(ctx context.Context, name string, value []byte, time time.Time) error {
+	tx, err := d.instance.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return errors.Wrap(err, "BEGIN transaction")
+	}
+
+	_, err = tx.Exec(`
+	INSERT INTO
+		secret (name, value, created_at, updated_at)
+	VALUES
+		($1, $2, $3, $3)
+	ON CONFLICT (name)
+	DO
+	UPDATE SET
+		(value, updated_at) = ($2, $3);
+	`, name, value, time)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Wrap(err, "INSERT in to secret")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "COMMIT")
+	}
+	return nil
+}
+
+// GetSecret returns the encrypted value of a secret
+func (d TinkDB) GetSecret(ctx context.Context, name string) ([]byte, error) {
+	query := `
+	SELECT value
+	FROM secret
+	WHERE
+		name = $1;
+	`
+	row := d.instance.QueryRowContext(ctx, query, name)
+	value := []byte{}
+	err := row.Scan(&value)
+	if err == nil {
+		return value, nil
+	}
+	if err != sql.ErrNoRows {
+		err = errors.Wrap(err, "SELECT from secret")
+		logger.Error(err)
+	}
+	return nil, err
+}
+
+// DeleteSecret deletes a secret
+func (d TinkDB) DeleteSecret(ctx context.Context, name string) error {
+	tx, err := d.instance.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return errors.Wrap(err, "BEGIN transaction")
+	}
+
+	_, err = tx.Exec(`
+	DELETE FROM secret
+	WHERE
+		name = $1;
+	`, name)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Wrap(err, "DELETE from secret")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "COMMIT")
+	}
+	return nil
+}
+
+// ListSecrets returns the names of all the secrets, without their values
+func (d TinkDB) ListSecrets(fn func(name string, in, up *timestamp.Timestamp) error) error {
+	rows, err := d.instance.Query(`
+	SELECT name, created_at, updated_at
+	FROM secret
+	ORDER BY
+		name ASC;
+	`)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+	var (
+		name      string
+		createdAt time.Time
+		updatedAt time.Time
+	)
+
+	for rows.Next() {
+		err = rows.Scan(&name, &createdAt, &updatedAt)
+		if err != nil {
+			err = errors.Wrap(err, "SELECT from secret")
+			logger.Error(err)
+			return err
+		}
+
+		tCr, _ := ptypes.TimestampProto(createdAt)
+		tUp, _ := ptypes.TimestampProto(updatedAt)
+		err = fn(name, tCr, tUp)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = rows.Err()
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	return err
+}
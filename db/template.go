@@ -69,6 +69,32 @@ func (d TinkDB) GetTemplate(ctx context.Context, id string) (string, string, err
 	return "", "", err
 }
 
+// GetTemplateByName returns the id and data of a workflow template
+func (d TinkDB) GetTemplateByName(ctx context.Context, name string) (string, string, error) {
+	query := `
+	SELECT id, data
+	FROM template
+	WHERE
+		name = $1
+	AND
+		deleted_at IS NULL
+	ORDER BY updated_at DESC
+	LIMIT 1
+	`
+	row := d.instance.QueryRowContext(ctx, query, name)
+	var id string
+	data := []byte{}
+	err := row.Scan(&id, &data)
+	if err == nil {
+		return id, string(data), nil
+	}
+	if err != sql.ErrNoRows {
+		err = errors.Wrap(err, "SELECT")
+		logger.Error(err)
+	}
+	return "", "", err
+}
+
 // DeleteTemplate deletes a workflow template
 func (d TinkDB) DeleteTemplate(ctx context.Context, name string) error {
 	tx, err := d.instance.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
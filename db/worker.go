@@ -0,0 +1,196 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/pkg/errors"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+)
+
+// RegisterWorker records the boot id a worker is running with and returns
+// the boot id it was previously registered with, if any
+func (d TinkDB) RegisterWorker(ctx context.Context, id, bootID string, time time.Time) (string, error) {
+	tx, err := d.instance.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return "", errors.Wrap(err, "BEGIN transaction")
+	}
+
+	var prevBootID string
+	row := tx.QueryRowContext(ctx, `
+	SELECT boot_id
+	FROM worker
+	WHERE
+		id = $1;
+	`, id)
+	err = row.Scan(&prevBootID)
+	if err != nil && err != sql.ErrNoRows {
+		_ = tx.Rollback()
+		return "", errors.Wrap(err, "SELECT from worker")
+	}
+
+	_, err = tx.Exec(`
+	INSERT INTO
+		worker (id, boot_id, registered_at)
+	VALUES
+		($1, $2, $3)
+	ON CONFLICT (id)
+	DO
+	UPDATE SET
+		(boot_id, registered_at) = ($2, $3);
+	`, id, bootID, time)
+	if err != nil {
+		_ = tx.Rollback()
+		return "", errors.Wrap(err, "INSERT in to worker")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", errors.Wrap(err, "COMMIT")
+	}
+	return prevBootID, nil
+}
+
+// UpdateWorkerHeartbeat records the time a worker was last seen, along with
+// the action it is currently executing
+func (d TinkDB) UpdateWorkerHeartbeat(ctx context.Context, hb *pb.HeartbeatRequest, time time.Time) error {
+	tx, err := d.instance.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return errors.Wrap(err, "BEGIN transaction")
+	}
+
+	_, err = tx.Exec(`
+	INSERT INTO
+		worker (id, registered_at, last_seen, workflow_id, action_name)
+	VALUES
+		($1, $2, $2, $3, $4)
+	ON CONFLICT (id)
+	DO
+	UPDATE SET
+		(last_seen, workflow_id, action_name) = ($2, $3, $4);
+	`, hb.WorkerId, time, hb.WorkflowId, hb.ActionName)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Wrap(err, "INSERT in to worker")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "COMMIT")
+	}
+	return nil
+}
+
+// ListWorkers returns all the known workers
+func (d TinkDB) ListWorkers(fn func(w *pb.WorkerStatus) error) error {
+	rows, err := d.instance.Query(`
+	SELECT id, COALESCE(boot_id, ''), registered_at, COALESCE(last_seen, registered_at), COALESCE(workflow_id, ''), COALESCE(action_name, '')
+	FROM worker
+	ORDER BY
+		id ASC;
+	`)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+	var (
+		id, bootID, wfID, aName string
+		regAt, lastSeen         time.Time
+	)
+
+	for rows.Next() {
+		err = rows.Scan(&id, &bootID, &regAt, &lastSeen, &wfID, &aName)
+		if err != nil {
+			err = errors.Wrap(err, "SELECT from worker")
+			logger.Error(err)
+			return err
+		}
+		w := &pb.WorkerStatus{
+			WorkerId:   id,
+			BootId:     bootID,
+			WorkflowId: wfID,
+			ActionName: aName,
+		}
+		w.RegisteredAt, _ = ptypes.TimestampProto(regAt)
+		w.LastSeen, _ = ptypes.TimestampProto(lastSeen)
+		err = fn(w)
+		if err != nil {
+			return err
+		}
+	}
+	err = rows.Err()
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	return err
+}
+
+// GetOrphanedWorkflows returns the workflows with a running action whose
+// worker has not sent a heartbeat since the given time. The workers which
+// never sent a heartbeat have them disabled and are not considered.
+func (d TinkDB) GetOrphanedWorkflows(ctx context.Context, lastSeen time.Time) ([]string, error) {
+	rows, err := d.instance.QueryContext(ctx, `
+	SELECT ws.workflow_id
+	FROM workflow_state ws
+	JOIN worker w
+	ON
+		ws.current_worker = w.id::text
+	WHERE
+		ws.current_action_state = $1
+	AND
+		w.last_seen IS NOT NULL
+	AND
+		w.last_seen < $2;
+	`, pb.State_STATE_RUNNING, lastSeen)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	var (
+		wfIDs []string
+		wfID  string
+	)
+	for rows.Next() {
+		err = rows.Scan(&wfID)
+		if err != nil {
+			err = errors.Wrap(err, "SELECT from workflow_state")
+			logger.Error(err)
+			return nil, err
+		}
+		wfIDs = append(wfIDs, wfID)
+	}
+	err = rows.Err()
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return wfIDs, err
+}
+
+// TimeoutOrphanedAction marks as timed out the running action of a workflow,
+// provided it is still the action at actionIndex and it is still running. It
+// reports whether the action was timed out, a status reported meanwhile by
+// the worker is never overwritten.
+func (d TinkDB) TimeoutOrphanedAction(ctx context.Context, wfID string, actionIndex int64) (bool, error) {
+	res, err := d.instance.ExecContext(ctx, `
+	UPDATE workflow_state
+	SET current_action_state = $3
+	WHERE
+		workflow_id = $1
+	AND
+		current_action_index = $2
+	AND
+		current_action_state = $4;
+	`, wfID, actionIndex, pb.State_STATE_TIMEOUT, pb.State_STATE_RUNNING)
+	if err != nil {
+		return false, errors.Wrap(err, "UPDATE workflow_state")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -8,7 +8,6 @@ import (
 	"net"
 	"os"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/golang/protobuf/ptypes"
@@ -23,7 +22,11 @@ import (
 type Workflow struct {
 	State                  int32
 	ID, Hardware, Template string
-	CreatedAt, UpdatedAt   *timestamp.Timestamp
+	// Parameters are the values given to the parameters of the template
+	Parameters map[string]string
+	// Includes are the templates included by the template
+	Includes             []wflow.Include
+	CreatedAt, UpdatedAt *timestamp.Timestamp
 }
 
 var (
@@ -56,22 +59,50 @@ func (d TinkDB) CreateWorkflow(ctx context.Context, wf Workflow, data string, id
 }
 
 func insertInWorkflow(ctx context.Context, db *sql.DB, wf Workflow, tx *sql.Tx) error {
-	_, err := tx.Exec(`
+	params, err := marshalParameters(wf.Parameters)
+	if err != nil {
+		return err
+	}
+	includes, err := marshalIncludes(wf.Includes)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
 	INSERT INTO
-		workflow (created_at, updated_at, template, devices, id)
+		workflow (created_at, updated_at, template, devices, parameters, includes, id)
 	VALUES
-		($1, $1, $2, $3, $4)
+		($1, $1, $2, $3, $4, $5, $6)
 	ON CONFLICT (id)
 	DO
 	UPDATE SET
-		(updated_at, deleted_at, template, devices) = ($1, NULL, $2, $3);
-	`, time.Now(), wf.Template, wf.Hardware, wf.ID)
+		(updated_at, deleted_at, template, devices, parameters, includes) = ($1, NULL, $2, $3, $4, $5);
+	`, time.Now(), wf.Template, wf.Hardware, params, includes, wf.ID)
 	if err != nil {
 		return errors.Wrap(err, "INSERT in to workflow")
 	}
 	return nil
 }
 
+// marshalParameters encodes the parameters of a workflow as a JSON object,
+// empty when there is none
+func marshalParameters(params map[string]string) ([]byte, error) {
+	if params == nil {
+		params = map[string]string{}
+	}
+	data, err := json.Marshal(params)
+	return data, errors.Wrap(err, "parameters")
+}
+
+// marshalIncludes encodes the includes of a workflow as a JSON array, empty
+// when there is none
+func marshalIncludes(includes []wflow.Include) ([]byte, error) {
+	if includes == nil {
+		includes = []wflow.Include{}
+	}
+	data, err := json.Marshal(includes)
+	return data, errors.Wrap(err, "includes")
+}
+
 func insertIntoWfWorkerTable(ctx context.Context, db *sql.DB, wfID uuid.UUID, workerID uuid.UUID, tx *sql.Tx) error {
 	_, err := tx.Exec(`
 	INSERT INTO
@@ -97,16 +128,6 @@ func insertActionList(ctx context.Context, db *sql.DB, yamlData string, id uuid.
 	var actionList []*pb.WorkflowAction
 	var uniqueWorkerID uuid.UUID
 	for _, task := range wf.Tasks {
-		taskEnvs := map[string]string{}
-		taskVolumes := map[string]string{}
-		for _, vol := range task.Volumes {
-			v := strings.Split(vol, ":")
-			taskVolumes[v[0]] = strings.Join(v[1:], ":")
-		}
-		for key, val := range task.Environment {
-			taskEnvs[key] = val
-		}
-
 		workerID, err := getWorkerID(ctx, db, task.WorkerAddr)
 		if err != nil {
 			return err
@@ -124,49 +145,7 @@ func insertActionList(ctx context.Context, db *sql.DB, yamlData string, id uuid.
 			}
 			uniqueWorkerID = workerUID
 		}
-		for _, ac := range task.Actions {
-			acenvs := map[string]string{}
-			for key, val := range taskEnvs {
-				acenvs[key] = val
-			}
-			for key, val := range ac.Environment {
-				acenvs[key] = val
-			}
-
-			envs := []string{}
-			for key, val := range acenvs {
-				envs = append(envs, key+"="+val)
-			}
-
-			volumes := map[string]string{}
-			for k, v := range taskVolumes {
-				volumes[k] = v
-			}
-
-			for _, vol := range ac.Volumes {
-				v := strings.Split(vol, ":")
-				volumes[v[0]] = strings.Join(v[1:], ":")
-			}
-
-			ac.Volumes = []string{}
-			for k, v := range volumes {
-				ac.Volumes = append(ac.Volumes, k+":"+v)
-			}
-
-			action := pb.WorkflowAction{
-				TaskName:    task.Name,
-				WorkerId:    workerUID.String(),
-				Name:        ac.Name,
-				Image:       ac.Image,
-				Timeout:     ac.Timeout,
-				Command:     ac.Command,
-				OnTimeout:   ac.OnTimeout,
-				OnFailure:   ac.OnFailure,
-				Environment: envs,
-				Volumes:     ac.Volumes,
-			}
-			actionList = append(actionList, &action)
-		}
+		actionList = append(actionList, wflow.TaskActions(task, workerUID.String())...)
 	}
 	totalActions := int64(len(actionList))
 	actionData, err := json.Marshal(actionList)
@@ -336,7 +315,7 @@ func (d TinkDB) GetWorkflowsForWorker(id string) ([]string, error) {
 // GetWorkflow returns a workflow
 func (d TinkDB) GetWorkflow(ctx context.Context, id string) (Workflow, error) {
 	query := `
-	SELECT template, devices
+	SELECT template, devices, parameters, includes, created_at, updated_at
 	FROM workflow
 	WHERE
 		id = $1
@@ -345,9 +324,20 @@ func (d TinkDB) GetWorkflow(ctx context.Context, id string) (Workflow, error) {
 	`
 	row := d.instance.QueryRowContext(ctx, query, id)
 	var tmp, tar string
-	err := row.Scan(&tmp, &tar)
+	var params, includes []byte
+	var crAt, upAt time.Time
+	err := row.Scan(&tmp, &tar, &params, &includes, &crAt, &upAt)
 	if err == nil {
-		return Workflow{ID: id, Template: tmp, Hardware: tar}, nil
+		wf := Workflow{ID: id, Template: tmp, Hardware: tar}
+		if err := json.Unmarshal(params, &wf.Parameters); err != nil {
+			return Workflow{}, errors.Wrap(err, "parameters")
+		}
+		if err := json.Unmarshal(includes, &wf.Includes); err != nil {
+			return Workflow{}, errors.Wrap(err, "includes")
+		}
+		wf.CreatedAt, _ = ptypes.TimestampProto(crAt)
+		wf.UpdatedAt, _ = ptypes.TimestampProto(upAt)
+		return wf, nil
 	}
 
 	if err != sql.ErrNoRows {
@@ -404,7 +394,7 @@ func (d TinkDB) DeleteWorkflow(ctx context.Context, id string, state int32) erro
 // ListWorkflows returns all workflows
 func (d TinkDB) ListWorkflows(fn func(wf Workflow) error) error {
 	rows, err := d.instance.Query(`
-	SELECT id, template, devices, created_at, updated_at
+	SELECT id, template, devices, parameters, includes, created_at, updated_at
 	FROM workflow
 	WHERE
 		deleted_at IS NULL;
@@ -416,12 +406,13 @@ func (d TinkDB) ListWorkflows(fn func(wf Workflow) error) error {
 
 	defer rows.Close()
 	var (
-		id, tmp, tar string
-		crAt, upAt   time.Time
+		id, tmp, tar     string
+		params, includes []byte
+		crAt, upAt       time.Time
 	)
 
 	for rows.Next() {
-		err = rows.Scan(&id, &tmp, &tar, &crAt, &upAt)
+		err = rows.Scan(&id, &tmp, &tar, &params, &includes, &crAt, &upAt)
 		if err != nil {
 			err = errors.Wrap(err, "SELECT")
 			logger.Error(err)
@@ -433,6 +424,12 @@ func (d TinkDB) ListWorkflows(fn func(wf Workflow) error) error {
 			Template: tmp,
 			Hardware: tar,
 		}
+		if err := json.Unmarshal(params, &wf.Parameters); err != nil {
+			return errors.Wrap(err, "parameters")
+		}
+		if err := json.Unmarshal(includes, &wf.Includes); err != nil {
+			return errors.Wrap(err, "includes")
+		}
 		wf.CreatedAt, _ = ptypes.TimestampProto(crAt)
 		wf.UpdatedAt, _ = ptypes.TimestampProto(upAt)
 		err = fn(wf)
@@ -498,19 +495,56 @@ func (d TinkDB) UpdateWorkflowState(ctx context.Context, wfContext *pb.WorkflowC
 		return errors.Wrap(err, "BEGIN transaction")
 	}
 
-	_, err = tx.Exec(`
+	err = updateWorkflowState(tx, wfContext)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "COMMIT")
+	}
+	return nil
+}
+
+func updateWorkflowState(tx *sql.Tx, wfContext *pb.WorkflowContext) error {
+	_, err := tx.Exec(`
 	UPDATE workflow_state
 	SET current_task_name = $2,
 		current_action_name = $3,
 		current_action_state = $4,
 		current_worker = $5,
-		current_action_index = $6
+		current_action_index = $6,
+		current_action_progress = $7,
+		current_action_message = $8
 	WHERE
 		workflow_id = $1;
-	`, wfContext.WorkflowId, wfContext.CurrentTask, wfContext.CurrentAction, wfContext.CurrentActionState, wfContext.CurrentWorker, wfContext.CurrentActionIndex)
+	`, wfContext.WorkflowId, wfContext.CurrentTask, wfContext.CurrentAction, wfContext.CurrentActionState, wfContext.CurrentWorker, wfContext.CurrentActionIndex,
+		wfContext.CurrentActionProgress, wfContext.CurrentActionMessage)
 	if err != nil {
 		return errors.Wrap(err, "INSERT in to workflow_state")
 	}
+	return nil
+}
+
+// UpdateWorkflowPaused pauses or resumes a workflow. The flag is updated on
+// its own so that the concurrent updates of the state of the workflow do not
+// override it.
+func (d TinkDB) UpdateWorkflowPaused(ctx context.Context, wfID string, paused bool) error {
+	tx, err := d.instance.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return errors.Wrap(err, "BEGIN transaction")
+	}
+
+	_, err = tx.Exec(`
+	UPDATE workflow_state
+	SET paused = $2
+	WHERE
+		workflow_id = $1;
+	`, wfID, paused)
+	if err != nil {
+		return errors.Wrap(err, "UPDATE workflow_state")
+	}
 	err = tx.Commit()
 	if err != nil {
 		return errors.Wrap(err, "COMMIT")
@@ -521,25 +555,31 @@ func (d TinkDB) UpdateWorkflowState(ctx context.Context, wfContext *pb.WorkflowC
 // GetWorkflowContexts : gives you the current workflow context
 func (d TinkDB) GetWorkflowContexts(ctx context.Context, wfID string) (*pb.WorkflowContext, error) {
 	query := `
-	SELECT current_worker, current_task_name, current_action_name, current_action_index, current_action_state, total_number_of_actions
+	SELECT current_worker, current_task_name, current_action_name, current_action_index, current_action_state, total_number_of_actions,
+		COALESCE(current_action_progress, 0), COALESCE(current_action_message, ''), paused
 	FROM workflow_state
 	WHERE
 		workflow_id = $1;
 	`
 	row := d.instance.QueryRowContext(ctx, query, wfID)
-	var cw, ct, ca string
+	var cw, ct, ca, cam string
 	var cai, tact int64
+	var cp int32
 	var cas pb.State
-	err := row.Scan(&cw, &ct, &ca, &cai, &cas, &tact)
+	var paused bool
+	err := row.Scan(&cw, &ct, &ca, &cai, &cas, &tact, &cp, &cam, &paused)
 	if err == nil {
 		return &pb.WorkflowContext{
-			WorkflowId:           wfID,
-			CurrentWorker:        cw,
-			CurrentTask:          ct,
-			CurrentAction:        ca,
-			CurrentActionIndex:   cai,
-			CurrentActionState:   cas,
-			TotalNumberOfActions: tact}, nil
+			WorkflowId:            wfID,
+			CurrentWorker:         cw,
+			CurrentTask:           ct,
+			CurrentAction:         ca,
+			CurrentActionIndex:    cai,
+			CurrentActionState:    cas,
+			TotalNumberOfActions:  tact,
+			CurrentActionProgress: cp,
+			CurrentActionMessage:  cam,
+			Paused:                paused}, nil
 	}
 	if err != sql.ErrNoRows {
 		err = errors.Wrap(err, "SELECT from worflow_state")
@@ -650,9 +690,81 @@ func (d TinkDB) ShowWorkflowEvents(wfID string, fn func(wfs *pb.WorkflowActionSt
 	return err
 }
 
+// UpdateWorkflowStateWithOutputs updates the current state of a workflow
+// along with the outputs reported by its action, in a single transaction so
+// that the next action never starts without them
+func (d TinkDB) UpdateWorkflowStateWithOutputs(ctx context.Context, wfContext *pb.WorkflowContext, wfOutput *pb.WorkflowActionStatus, time time.Time) error {
+	tx, err := d.instance.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return errors.Wrap(err, "BEGIN transaction")
+	}
+
+	err = updateWorkflowState(tx, wfContext)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	for name, value := range wfOutput.GetOutputs() {
+		_, err = tx.Exec(`
+		INSERT INTO
+			workflow_output (workflow_id, task_name, action_name, name, value, created_at)
+		VALUES
+			($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (workflow_id, action_name, name)
+		DO
+		UPDATE SET
+			(task_name, value, created_at) = ($2, $5, $6);
+		`, wfOutput.WorkflowId, wfOutput.TaskName, wfOutput.ActionName, name, value, time)
+		if err != nil {
+			_ = tx.Rollback()
+			return errors.Wrap(err, "INSERT in to workflow_output")
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "COMMIT")
+	}
+	return nil
+}
+
+// GetWorkflowOutputs : returns the outputs reported by the actions of a workflow, keyed by action name
+func (d TinkDB) GetWorkflowOutputs(ctx context.Context, wfID string) (map[string]map[string]string, error) {
+	rows, err := d.instance.QueryContext(ctx, `
+	SELECT action_name, name, value
+	FROM workflow_output
+	WHERE
+		workflow_id = $1;
+	`, wfID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	var aName, name, value string
+	outputs := map[string]map[string]string{}
+	for rows.Next() {
+		err = rows.Scan(&aName, &name, &value)
+		if err != nil {
+			err = errors.Wrap(err, "SELECT from workflow_output")
+			logger.Error(err)
+			return nil, err
+		}
+		if _, ok := outputs[aName]; !ok {
+			outputs[aName] = map[string]string{}
+		}
+		outputs[aName][name] = value
+	}
+	err = rows.Err()
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	return outputs, err
+}
+
 func getLatestVersionWfData(ctx context.Context, db *sql.DB, wfID string) (int32, error) {
+	// the versions are not contiguous once the oldest ones are purged
 	query := `
-	SELECT COUNT(*)
+	SELECT COALESCE(MAX(version), 0)
 	FROM workflow_data
 	WHERE
 		workflow_id = $1;
@@ -749,6 +861,12 @@ func getWorkerID(ctx context.Context, db *sql.DB, addr string) (string, error) {
 	return getWorkerIDbyMac(ctx, db, addr)
 }
 
+// MaxDataVersions returns the number of versions of the ephemeral data of a
+// workflow which are kept, older versions only keep their metadata
+func MaxDataVersions() int {
+	return maxVersions
+}
+
 func init() {
 	val := os.Getenv("MAX_WORKFLOW_DATA_VERSIONS")
 	if v, err := strconv.Atoi(val); err == nil {
@@ -29,6 +29,8 @@ CREATE TABLE IF NOT EXISTS workflow (
 	id UUID UNIQUE NOT NULL
 	, template UUID NOT NULL
 	, devices JSONB NOT NULL
+	, parameters JSONB NOT NULL DEFAULT '{}'
+	, includes JSONB NOT NULL DEFAULT '[]'
 	, created_at TIMESTAMPTZ
 	, updated_at TIMESTAMPTZ
 	, deleted_at TIMESTAMPTZ
@@ -46,6 +48,9 @@ CREATE TABLE IF NOT EXISTS workflow_state (
         , action_list JSONB
         , current_action_index int
         , total_number_of_actions INT
+        , current_action_progress SMALLINT
+        , current_action_message VARCHAR(200)
+        , paused BOOLEAN NOT NULL DEFAULT FALSE
 );
 
 CREATE INDEX IF NOT EXISTS idx_wfid ON workflow_state (workflow_id);
@@ -76,3 +81,45 @@ CREATE TABLE IF NOT EXISTS workflow_data (
         , metadata JSONB
         , data JSONB
 );
+
+CREATE TABLE IF NOT EXISTS workflow_output (
+	workflow_id UUID NOT NULL
+	, task_name VARCHAR(200)
+	, action_name VARCHAR(200)
+	, name VARCHAR(200)
+	, value TEXT
+	, created_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uidx_workflow_output ON workflow_output (workflow_id, action_name, name);
+
+CREATE TABLE IF NOT EXISTS worker (
+	id UUID UNIQUE NOT NULL
+	, boot_id VARCHAR(200)
+	, registered_at TIMESTAMPTZ
+	, last_seen TIMESTAMPTZ
+	, workflow_id VARCHAR(200)
+	, action_name VARCHAR(200)
+);
+
+CREATE INDEX IF NOT EXISTS idx_worker_last_seen ON worker (last_seen);
+
+CREATE TABLE IF NOT EXISTS secret (
+	name VARCHAR(200) UNIQUE NOT NULL
+	, value BYTEA NOT NULL
+	, created_at TIMESTAMPTZ
+	, updated_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS workflow_artifact (
+	workflow_id UUID NOT NULL
+	, worker_id UUID NOT NULL
+	, task_name VARCHAR(200)
+	, action_name VARCHAR(200)
+	, name VARCHAR(200)
+	, size BIGINT
+	, blob_key VARCHAR(300) NOT NULL
+	, created_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uidx_workflow_artifact ON workflow_artifact (workflow_id, action_name, name);
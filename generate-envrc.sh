@@ -51,6 +51,8 @@ generate_envrc() (
 	tink_password=$(generate_password)
 	local registry_password
 	registry_password=$(generate_password)
+	local secrets_key
+	secrets_key=$(head -c 32 /dev/urandom | base64)
 	cat <<EOF
 # Network interface for Tinkerbell's network
 export TINKERBELL_NETWORK_INTERFACE="$tink_interface"
@@ -78,6 +80,9 @@ export TINKERBELL_TINK_PASSWORD="$tink_password"
 export TINKERBELL_REGISTRY_USERNAME=admin
 export TINKERBELL_REGISTRY_PASSWORD="$registry_password"
 
+# Key the secrets referenced by the templates are encrypted with
+export TINKERBELL_SECRETS_KEY="$secrets_key"
+
 # Legacy options, to be deleted:
 export FACILITY=onprem
 EOF
@@ -0,0 +1,199 @@
+package grpcserver
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tinkerbell/tink/db"
+	"github.com/tinkerbell/tink/metrics"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+	wflow "github.com/tinkerbell/tink/workflow"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	errWorkflowPaused     = "workflow is paused"
+	errWorkflowNotPaused  = "workflow is not paused"
+	errWorkflowWaiting    = "workflow is waiting for an approval"
+	errWorkflowNotWaiting = "workflow is not waiting for an approval, it is %s"
+	errPauseFinished      = "workflow is already %s"
+	msgWaitingForApproval = "waiting for approval"
+	msgApproved           = "approved"
+	msgWorkflowPaused     = "workflow paused"
+	msgWorkflowResumed    = "workflow resumed"
+	msgWorkflowApproved   = "workflow approved"
+	msgWorkflowWaiting    = "workflow waiting for approval"
+)
+
+// PauseWorkflow implements workflow.PauseWorkflow
+func (s *server) PauseWorkflow(ctx context.Context, in *pb.GetRequest) (*pb.Empty, error) {
+	return s.workflowCommand(ctx, "PauseWorkflow", "pause", in.GetId(), msgWorkflowPaused, func(wfContext *pb.WorkflowContext) error {
+		if state := workflowState(wfContext); isFinalState(state) {
+			return status.Errorf(codes.FailedPrecondition, errPauseFinished, stateLabel(state))
+		}
+		if wfContext.GetPaused() {
+			return status.Errorf(codes.FailedPrecondition, errWorkflowPaused)
+		}
+		return s.db.UpdateWorkflowPaused(ctx, wfContext.GetWorkflowId(), true)
+	})
+}
+
+// ResumeWorkflow implements workflow.ResumeWorkflow
+func (s *server) ResumeWorkflow(ctx context.Context, in *pb.GetRequest) (*pb.Empty, error) {
+	return s.workflowCommand(ctx, "ResumeWorkflow", "resume", in.GetId(), msgWorkflowResumed, func(wfContext *pb.WorkflowContext) error {
+		if !wfContext.GetPaused() {
+			return status.Errorf(codes.FailedPrecondition, errWorkflowNotPaused)
+		}
+		return s.db.UpdateWorkflowPaused(ctx, wfContext.GetWorkflowId(), false)
+	})
+}
+
+// ApproveWorkflow implements workflow.ApproveWorkflow
+func (s *server) ApproveWorkflow(ctx context.Context, in *pb.GetRequest) (*pb.Empty, error) {
+	return s.workflowCommand(ctx, "ApproveWorkflow", "approve", in.GetId(), msgWorkflowApproved, func(wfContext *pb.WorkflowContext) error {
+		return approveWorkflow(ctx, s.db, wfContext)
+	})
+}
+
+// workflowCommand runs a command changing the state of an existing
+// workflow, with the metrics and logs of the other workflow RPCs
+func (s *server) workflowCommand(ctx context.Context, method, op, wfID, msg string, command func(*pb.WorkflowContext) error) (*pb.Empty, error) {
+	labels := prometheus.Labels{"method": method, "op": op}
+	metrics.CacheInFlight.With(labels).Inc()
+	defer metrics.CacheInFlight.With(labels).Dec()
+	metrics.CacheTotals.With(labels).Inc()
+	timer := prometheus.NewTimer(metrics.CacheDuration.With(labels))
+	defer timer.ObserveDuration()
+
+	l := logger.With("workflowID", wfID)
+	err := func() error {
+		if wfID == "" {
+			return status.Errorf(codes.InvalidArgument, errInvalidWorkflowId)
+		}
+		wfContext, err := s.db.GetWorkflowContexts(ctx, wfID)
+		if err != nil {
+			return status.Errorf(codes.Aborted, err.Error())
+		}
+		if wfContext.GetWorkflowId() == "" {
+			return status.Errorf(codes.NotFound, errWorkflowNotFound, wfID)
+		}
+		return command(wfContext)
+	}()
+	if err != nil {
+		metrics.CacheErrors.With(labels).Inc()
+		l.Error(err)
+		return &pb.Empty{}, err
+	}
+	l.Info(msg)
+	return &pb.Empty{}, nil
+}
+
+// approveWorkflow completes the approval action a workflow is waiting on,
+// the workflow then goes on with its next action
+func approveWorkflow(ctx context.Context, d db.Database, wfContext *pb.WorkflowContext) error {
+	if wfContext.GetCurrentActionState() != pb.State_STATE_WAITING {
+		return status.Errorf(codes.FailedPrecondition, errWorkflowNotWaiting, stateLabel(workflowState(wfContext)))
+	}
+	wfID := wfContext.GetWorkflowId()
+	wfContext.CurrentActionState = pb.State_STATE_SUCCESS
+	wfContext.CurrentActionProgress = 100
+	wfContext.CurrentActionMessage = msgApproved
+	if err := d.UpdateWorkflowState(ctx, wfContext); err != nil {
+		return status.Errorf(codes.Aborted, err.Error())
+	}
+	event := &pb.WorkflowActionStatus{
+		WorkflowId:   wfID,
+		WorkerId:     wfContext.GetCurrentWorker(),
+		TaskName:     wfContext.GetCurrentTask(),
+		ActionName:   wfContext.GetCurrentAction(),
+		ActionStatus: pb.State_STATE_SUCCESS,
+		Message:      msgApproved,
+	}
+	if err := d.InsertIntoWorkflowEventTable(ctx, event, time.Now()); err != nil {
+		return status.Errorf(codes.Aborted, err.Error())
+	}
+	observeWorkflow(ctx, d, wfID, workflowTransition{from: pb.State_STATE_WAITING, to: workflowState(wfContext), action: event})
+	if err := waitForApproval(ctx, d, wfID); err != nil {
+		return status.Errorf(codes.Aborted, err.Error())
+	}
+	return nil
+}
+
+// waitForApproval moves a workflow to its next action if it is an approval
+// action, so that the workflow waits for an approval instead of being sent
+// to a worker
+func waitForApproval(ctx context.Context, d db.Database, wfID string) error {
+	wfContext, err := d.GetWorkflowContexts(ctx, wfID)
+	if err != nil {
+		return err
+	}
+	actions, err := d.GetWorkflowActions(ctx, wfID)
+	if err != nil {
+		return err
+	}
+	index := nextActionIndex(wfContext)
+	if index < 0 || index >= len(actions.GetActionList()) {
+		return nil
+	}
+	action := actions.GetActionList()[index]
+	if action.GetType() != wflow.ActionTypeApproval {
+		return nil
+	}
+
+	prevState := workflowState(wfContext)
+	wfContext.CurrentWorker = action.GetWorkerId()
+	wfContext.CurrentTask = action.GetTaskName()
+	wfContext.CurrentAction = action.GetName()
+	wfContext.CurrentActionIndex = int64(index)
+	wfContext.CurrentActionState = pb.State_STATE_WAITING
+	wfContext.CurrentActionProgress = 0
+	wfContext.CurrentActionMessage = msgWaitingForApproval
+	if err := d.UpdateWorkflowState(ctx, wfContext); err != nil {
+		return err
+	}
+	event := &pb.WorkflowActionStatus{
+		WorkflowId:   wfID,
+		WorkerId:     action.GetWorkerId(),
+		TaskName:     action.GetTaskName(),
+		ActionName:   action.GetName(),
+		ActionStatus: pb.State_STATE_WAITING,
+		Message:      msgWaitingForApproval,
+	}
+	if err := d.InsertIntoWorkflowEventTable(ctx, event, time.Now()); err != nil {
+		return err
+	}
+	observeWorkflow(ctx, d, wfID, workflowTransition{from: prevState, to: pb.State_STATE_WAITING})
+	logger.With("workflowID", wfID, "actionName", action.GetName()).Info(msgWorkflowWaiting)
+	return nil
+}
+
+// nextActionIndex returns the index of the action a workflow starts next,
+// or -1 if an action is in progress or the workflow is done
+func nextActionIndex(wfContext *pb.WorkflowContext) int {
+	switch {
+	case wfContext.GetCurrentAction() == "":
+		return 0
+	case wfContext.GetCurrentActionState() == pb.State_STATE_SUCCESS:
+		return int(wfContext.GetCurrentActionIndex()) + 1
+	case wfContext.GetCurrentActionState() == pb.State_STATE_PENDING:
+		return int(wfContext.GetCurrentActionIndex())
+	}
+	return -1
+}
+
+// startsWithApproval checks if the first action of a rendered workflow is an
+// approval action
+func startsWithApproval(data string) bool {
+	wf, err := wflow.Parse([]byte(data))
+	if err != nil {
+		return false
+	}
+	for _, task := range wf.Tasks {
+		if len(task.Actions) > 0 {
+			return task.Actions[0].Type == wflow.ActionTypeApproval
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package grpcserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/tinkerbell/tink/db/memory"
+	"github.com/tinkerbell/tink/protos/template"
+	"github.com/tinkerbell/tink/protos/workflow"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const approvalTemplate = `version: "0.1"
+name: disk_wipe
+global_timeout: 600
+tasks:
+  - name: "wipe"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "inventory"
+      image: inventory
+      timeout: 60
+    - name: "review"
+      type: approval
+    - name: "wipe_disk"
+      image: wipe
+      timeout: 60`
+
+func TestApproveWorkflow(t *testing.T) {
+	const workerID = "ce2e62ed-826f-4485-a39f-a82bb74338e2"
+	ctx := context.Background()
+	d := memory.New()
+	s := testServer(d)
+	err := d.InsertIntoDB(ctx, `{"id": "`+workerID+`", "network": {"interfaces": [{"dhcp": {"mac": "08:00:27:00:00:01"}}]}}`)
+	assert.NoError(t, err)
+	tmp, err := s.CreateTemplate(ctx, &template.WorkflowTemplate{Name: "disk_wipe", Data: approvalTemplate})
+	assert.NoError(t, err)
+	res, err := s.CreateWorkflow(ctx, &workflow.CreateRequest{Template: tmp.Id, Hardware: hw})
+	assert.NoError(t, err)
+
+	report := func(action string, state workflow.State) error {
+		_, err := s.ReportActionStatus(ctx, &workflow.WorkflowActionStatus{
+			WorkflowId:   res.Id,
+			TaskName:     "wipe",
+			ActionName:   action,
+			ActionStatus: state,
+			WorkerId:     workerID,
+		})
+		return err
+	}
+	currentState := func() *workflow.WorkflowContext {
+		wfContext, err := d.GetWorkflowContexts(ctx, res.Id)
+		assert.NoError(t, err)
+		return wfContext
+	}
+	approve := func() codes.Code {
+		_, err := s.ApproveWorkflow(ctx, &workflow.GetRequest{Id: res.Id})
+		return status.Code(err)
+	}
+
+	assert.Equal(t, codes.FailedPrecondition, approve())
+	assert.NoError(t, report("inventory", workflow.State_STATE_RUNNING))
+	assert.NoError(t, report("inventory", workflow.State_STATE_SUCCESS))
+
+	// the workflow stops at the approval action
+	wfContext := currentState()
+	assert.Equal(t, "review", wfContext.GetCurrentAction())
+	assert.Equal(t, workflow.State_STATE_WAITING, wfContext.GetCurrentActionState())
+	assert.Equal(t, workflow.State_STATE_WAITING, workflowState(wfContext))
+	assert.False(t, isApplicableToSend(ctx, wfContext, workerID, d))
+	err = report("wipe_disk", workflow.State_STATE_RUNNING)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	assert.Equal(t, codes.OK, approve())
+	wfContext = currentState()
+	assert.Equal(t, workflow.State_STATE_SUCCESS, wfContext.GetCurrentActionState())
+	assert.True(t, isApplicableToSend(ctx, wfContext, workerID, d))
+	assert.NoError(t, report("wipe_disk", workflow.State_STATE_RUNNING))
+	assert.NoError(t, report("wipe_disk", workflow.State_STATE_SUCCESS))
+	assert.Equal(t, workflow.State_STATE_SUCCESS, workflowState(currentState()))
+
+	_, err = s.ApproveWorkflow(ctx, &workflow.GetRequest{Id: uuid.New().String()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestPauseWorkflow(t *testing.T) {
+	const workerID = "ce2e62ed-826f-4485-a39f-a82bb74338e2"
+	ctx := context.Background()
+	d := memory.New()
+	s := testServer(d)
+	err := d.InsertIntoDB(ctx, `{"id": "`+workerID+`", "network": {"interfaces": [{"dhcp": {"mac": "08:00:27:00:00:01"}}]}}`)
+	assert.NoError(t, err)
+	tmp, err := s.CreateTemplate(ctx, &template.WorkflowTemplate{Name: "hello", Data: templateData})
+	assert.NoError(t, err)
+	res, err := s.CreateWorkflow(ctx, &workflow.CreateRequest{Template: tmp.Id, Hardware: hw})
+	assert.NoError(t, err)
+	req := &workflow.GetRequest{Id: res.Id}
+	start := &workflow.WorkflowActionStatus{
+		WorkflowId:   res.Id,
+		TaskName:     "hello world",
+		ActionName:   "hello_world",
+		ActionStatus: workflow.State_STATE_RUNNING,
+		WorkerId:     workerID,
+	}
+
+	_, err = s.ResumeWorkflow(ctx, req)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = s.PauseWorkflow(ctx, req)
+	assert.NoError(t, err)
+	_, err = s.PauseWorkflow(ctx, req)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	wfContext, err := s.GetWorkflowContext(ctx, req)
+	assert.NoError(t, err)
+	assert.True(t, wfContext.GetPaused())
+	assert.False(t, isApplicableToSend(ctx, wfContext, workerID, d))
+	_, err = s.ReportActionStatus(ctx, start)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = s.ResumeWorkflow(ctx, req)
+	assert.NoError(t, err)
+	wfContext, err = s.GetWorkflowContext(ctx, req)
+	assert.NoError(t, err)
+	assert.False(t, wfContext.GetPaused())
+	assert.True(t, isApplicableToSend(ctx, wfContext, workerID, d))
+	_, err = s.ReportActionStatus(ctx, start)
+	assert.NoError(t, err)
+}
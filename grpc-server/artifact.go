@@ -0,0 +1,209 @@
+package grpcserver
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/tink/artifact"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultArtifactsDir    = "/artifacts"
+	defaultMaxArtifactSize = 100 * 1024 * 1024 // 100MB
+	artifactChunkSize      = 64 * 1024
+
+	errArtifactsDisabled   = "artifacts are disabled"
+	errInvalidArtifact     = "the first message of an upload must describe the artifact"
+	errInvalidArtifactName = "invalid artifact name"
+	errArtifactTooLarge    = "artifact exceeds the maximum size of %d bytes"
+	errArtifactNotFound    = "artifact not found"
+	errArtifactNotOwned    = "worker %s does not execute action %s of the workflow"
+)
+
+// ArtifactStore returns the store of the artifacts, in the directory set by
+// TINKERBELL_ARTIFACTS_DIR
+func ArtifactStore() (artifact.Store, error) {
+	dir := os.Getenv("TINKERBELL_ARTIFACTS_DIR")
+	if dir == "" {
+		dir = defaultArtifactsDir
+	}
+	return artifact.NewFileStore(dir)
+}
+
+// getMaxArtifactSize returns the maximum size in bytes of an artifact
+func getMaxArtifactSize() int64 {
+	size := os.Getenv("TINKERBELL_MAX_ARTIFACT_SIZE")
+	if size == "" {
+		return defaultMaxArtifactSize
+	}
+	n, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || n <= 0 {
+		logger.With("maxArtifactSize", size).Info("invalid maximum artifact size, using the default")
+		return defaultMaxArtifactSize
+	}
+	return n
+}
+
+// UploadArtifact implements tinkerbell.UploadArtifact
+func (s *server) UploadArtifact(stream pb.WorkflowService_UploadArtifactServer) error {
+	if s.artifacts == nil {
+		return status.Errorf(codes.FailedPrecondition, errArtifactsDisabled)
+	}
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	a := req.GetArtifact()
+	if a == nil {
+		return status.Errorf(codes.InvalidArgument, errInvalidArtifact)
+	}
+	if _, err := uuid.Parse(a.GetWorkflowId()); err != nil {
+		return status.Errorf(codes.InvalidArgument, errInvalidWorkflowId)
+	}
+	if len(a.GetWorkerId()) == 0 {
+		return status.Errorf(codes.InvalidArgument, errInvalidWorkerID)
+	}
+	if len(a.GetActionName()) == 0 {
+		return status.Errorf(codes.InvalidArgument, errInvalidActionName)
+	}
+	if !artifact.IsValidName(a.GetName()) {
+		return status.Errorf(codes.InvalidArgument, errInvalidArtifactName)
+	}
+
+	ctx := stream.Context()
+	if err := s.checkArtifactAction(ctx, a); err != nil {
+		return err
+	}
+	key := a.GetWorkflowId() + "/" + uuid.New().String()
+	r := &io.LimitedReader{R: &chunkReader{stream: stream}, N: s.maxArtifactSize + 1}
+	size, err := s.artifacts.Put(ctx, key, r)
+	if err != nil {
+		_ = s.artifacts.Delete(ctx, key)
+		return status.Errorf(codes.Aborted, err.Error())
+	}
+	if size > s.maxArtifactSize {
+		_ = s.artifacts.Delete(ctx, key)
+		return status.Errorf(codes.InvalidArgument, errArtifactTooLarge, s.maxArtifactSize)
+	}
+
+	a.Size = size
+	prevKey, err := s.db.InsertIntoWorkflowArtifactTable(ctx, a, key, time.Now())
+	if err != nil {
+		_ = s.artifacts.Delete(ctx, key)
+		return status.Errorf(codes.Aborted, err.Error())
+	}
+	l := logger.With("workflowID", a.GetWorkflowId(), "actionName", a.GetActionName(), "artifact", a.GetName())
+	if prevKey != "" {
+		if err := s.artifacts.Delete(ctx, prevKey); err != nil {
+			l.Error(errors.Wrap(err, "delete replaced artifact"))
+		}
+	}
+	l.With("size", size).Info("artifact uploaded")
+	return stream.SendAndClose(&pb.Empty{})
+}
+
+// checkArtifactAction checks that the workflow of an uploaded artifact exists
+// and that the action the artifact is stored for is executed by the worker
+// uploading it
+func (s *server) checkArtifactAction(ctx context.Context, a *pb.Artifact) error {
+	wfContext, err := s.db.GetWorkflowContexts(ctx, a.GetWorkflowId())
+	if err != nil {
+		return status.Errorf(codes.Aborted, err.Error())
+	}
+	if wfContext.GetWorkflowId() == "" {
+		return status.Errorf(codes.NotFound, errWorkflowNotFound, a.GetWorkflowId())
+	}
+	actions, err := s.db.GetWorkflowActions(ctx, a.GetWorkflowId())
+	if err != nil {
+		return status.Errorf(codes.Aborted, err.Error())
+	}
+	for _, action := range actions.GetActionList() {
+		if action.GetName() != a.GetActionName() || action.GetWorkerId() != a.GetWorkerId() {
+			continue
+		}
+		if a.GetTaskName() == "" || action.GetTaskName() == a.GetTaskName() {
+			return nil
+		}
+	}
+	return status.Errorf(codes.PermissionDenied, errArtifactNotOwned, a.GetWorkerId(), a.GetActionName())
+}
+
+// ListArtifacts implements tinkerbell.ListArtifacts
+func (s *server) ListArtifacts(req *pb.GetRequest, stream pb.WorkflowService_ListArtifactsServer) error {
+	if _, err := uuid.Parse(req.GetId()); err != nil {
+		return status.Errorf(codes.InvalidArgument, errInvalidWorkflowId)
+	}
+	return s.db.ListWorkflowArtifacts(stream.Context(), req.GetId(), func(a *pb.Artifact) error {
+		return stream.Send(a)
+	})
+}
+
+// GetArtifact implements tinkerbell.GetArtifact
+func (s *server) GetArtifact(req *pb.GetArtifactRequest, stream pb.WorkflowService_GetArtifactServer) error {
+	if s.artifacts == nil {
+		return status.Errorf(codes.FailedPrecondition, errArtifactsDisabled)
+	}
+	if _, err := uuid.Parse(req.GetWorkflowId()); err != nil {
+		return status.Errorf(codes.InvalidArgument, errInvalidWorkflowId)
+	}
+	ctx := stream.Context()
+	key, err := s.db.GetWorkflowArtifactKey(ctx, req.GetWorkflowId(), req.GetActionName(), req.GetName())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return status.Errorf(codes.NotFound, errArtifactNotFound)
+		}
+		return status.Errorf(codes.Aborted, err.Error())
+	}
+	r, err := s.artifacts.Get(ctx, key)
+	if err != nil {
+		if err == artifact.ErrNotFound {
+			return status.Errorf(codes.NotFound, errArtifactNotFound)
+		}
+		return status.Errorf(codes.Aborted, err.Error())
+	}
+	defer r.Close()
+
+	buf := make([]byte, artifactChunkSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if sendErr := stream.Send(&pb.ArtifactChunk{Chunk: buf[:n]}); sendErr != nil {
+				return sendErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return status.Errorf(codes.Aborted, err.Error())
+		}
+	}
+}
+
+// chunkReader reads the content of an artifact from an upload stream
+type chunkReader struct {
+	stream pb.WorkflowService_UploadArtifactServer
+	buf    []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = req.GetChunk()
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,278 @@
+package grpcserver
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tinkerbell/tink/artifact"
+	"github.com/tinkerbell/tink/db/mock"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const artifactName = "inventory.json"
+
+// uploadStream is a fake upload stream sending the given requests
+type uploadStream struct {
+	grpc.ServerStream
+	reqs   []*pb.UploadArtifactRequest
+	closed bool
+}
+
+func (s *uploadStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *uploadStream) Recv() (*pb.UploadArtifactRequest, error) {
+	if len(s.reqs) == 0 {
+		return nil, io.EOF
+	}
+	req := s.reqs[0]
+	s.reqs = s.reqs[1:]
+	return req, nil
+}
+
+func (s *uploadStream) SendAndClose(*pb.Empty) error {
+	s.closed = true
+	return nil
+}
+
+// getStream is a fake download stream
+type getStream struct {
+	grpc.ServerStream
+}
+
+func (s *getStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *getStream) Send(*pb.ArtifactChunk) error {
+	return nil
+}
+
+func upload(a *pb.Artifact, chunks ...string) *uploadStream {
+	s := &uploadStream{}
+	if a != nil {
+		s.reqs = append(s.reqs, &pb.UploadArtifactRequest{Data: &pb.UploadArtifactRequest_Artifact{Artifact: a}})
+	}
+	for _, c := range chunks {
+		s.reqs = append(s.reqs, &pb.UploadArtifactRequest{Data: &pb.UploadArtifactRequest_Chunk{Chunk: []byte(c)}})
+	}
+	return s
+}
+
+func testArtifact() *pb.Artifact {
+	return &pb.Artifact{
+		WorkflowId: workflowID,
+		WorkerId:   workerID,
+		TaskName:   taskName,
+		ActionName: actionName,
+		Name:       artifactName,
+	}
+}
+
+func TestUploadArtifact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := artifact.NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type (
+		args struct {
+			store  artifact.Store
+			stream *uploadStream
+		}
+		want struct {
+			code codes.Code
+			size int64
+		}
+	)
+	var stored *pb.Artifact
+	var storedKey string
+	db := mock.DB{
+		InsertIntoWorkflowArtifactTableFunc: func(ctx context.Context, a *pb.Artifact, key string, time time.Time) (string, error) {
+			prev := storedKey
+			stored, storedKey = a, key
+			return prev, nil
+		},
+		GetWorkflowContextsFunc: func(ctx context.Context, wfID string) (*pb.WorkflowContext, error) {
+			if wfID != workflowID {
+				return &pb.WorkflowContext{}, nil
+			}
+			return &pb.WorkflowContext{WorkflowId: workflowID, TotalNumberOfActions: 1}, nil
+		},
+		GetWorkflowActionsFunc: func(ctx context.Context, wfID string) (*pb.WorkflowActionList, error) {
+			return &pb.WorkflowActionList{
+				ActionList: []*pb.WorkflowAction{{WorkerId: workerID, TaskName: taskName, Name: actionName}},
+			}, nil
+		},
+	}
+	testCases := map[string]struct {
+		args args
+		want want
+	}{
+		"artifacts disabled": {
+			args: args{
+				stream: upload(testArtifact(), "data"),
+			},
+			want: want{
+				code: codes.FailedPrecondition,
+			},
+		},
+		"missing artifact": {
+			args: args{
+				store:  store,
+				stream: upload(nil, "data"),
+			},
+			want: want{
+				code: codes.InvalidArgument,
+			},
+		},
+		"invalid name": {
+			args: args{
+				store: store,
+				stream: upload(&pb.Artifact{
+					WorkflowId: workflowID,
+					WorkerId:   workerID,
+					ActionName: actionName,
+					Name:       "../inventory.json",
+				}, "data"),
+			},
+			want: want{
+				code: codes.InvalidArgument,
+			},
+		},
+		"unknown workflow": {
+			args: args{
+				store: store,
+				stream: upload(&pb.Artifact{
+					WorkflowId: "4a0ae4ae-4dbc-4a55-9d1c-0e0e1b0b5b3e",
+					WorkerId:   workerID,
+					ActionName: actionName,
+					Name:       artifactName,
+				}, "data"),
+			},
+			want: want{
+				code: codes.NotFound,
+			},
+		},
+		"other worker": {
+			args: args{
+				store: store,
+				stream: upload(&pb.Artifact{
+					WorkflowId: workflowID,
+					WorkerId:   "b2d5e6bc-0bbd-4a8c-9a5f-0bd1d6f0b0a1",
+					TaskName:   taskName,
+					ActionName: actionName,
+					Name:       artifactName,
+				}, "data"),
+			},
+			want: want{
+				code: codes.PermissionDenied,
+			},
+		},
+		"unknown action": {
+			args: args{
+				store: store,
+				stream: upload(&pb.Artifact{
+					WorkflowId: workflowID,
+					WorkerId:   workerID,
+					TaskName:   taskName,
+					ActionName: "disk-wipe",
+					Name:       artifactName,
+				}, "data"),
+			},
+			want: want{
+				code: codes.PermissionDenied,
+			},
+		},
+		"too large": {
+			args: args{
+				store:  store,
+				stream: upload(testArtifact(), "0123456789", "0123456789"),
+			},
+			want: want{
+				code: codes.InvalidArgument,
+			},
+		},
+		"uploaded": {
+			args: args{
+				store:  store,
+				stream: upload(testArtifact(), `{"cpus":`, ` 2}`),
+			},
+			want: want{
+				code: codes.OK,
+				size: 11,
+			},
+		},
+		"replaced": {
+			args: args{
+				store:  store,
+				stream: upload(testArtifact(), `{"cpus": 4}`),
+			},
+			want: want{
+				code: codes.OK,
+				size: 11,
+			},
+		},
+	}
+	for _, name := range []string{"artifacts disabled", "missing artifact", "invalid name", "unknown workflow", "other worker", "unknown action", "too large", "uploaded", "replaced"} {
+		tc := testCases[name]
+		t.Run(name, func(t *testing.T) {
+			prevKey := storedKey
+			s := testServer(db)
+			s.artifacts = tc.args.store
+			s.maxArtifactSize = 16
+			err := s.UploadArtifact(tc.args.stream)
+			assert.Equal(t, tc.want.code, status.Code(err))
+			if tc.want.code != codes.OK {
+				assert.False(t, tc.args.stream.closed)
+				return
+			}
+			assert.True(t, tc.args.stream.closed)
+			assert.Equal(t, tc.want.size, stored.Size)
+
+			r, err := store.Get(context.Background(), storedKey)
+			assert.NoError(t, err)
+			r.Close()
+			if prevKey != "" {
+				_, err = store.Get(context.Background(), prevKey)
+				assert.Equal(t, artifact.ErrNotFound, err)
+			}
+		})
+	}
+}
+
+func TestGetArtifactNotFound(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := artifact.NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := testServer(mock.DB{
+		GetWorkflowArtifactKeyFunc: func(ctx context.Context, wfID, actionName, name string) (string, error) {
+			return "", sql.ErrNoRows
+		},
+	})
+	s.artifacts = store
+	err = s.GetArtifact(&pb.GetArtifactRequest{WorkflowId: workflowID, ActionName: actionName, Name: artifactName}, &getStream{})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
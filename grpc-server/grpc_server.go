@@ -13,13 +13,19 @@ import (
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/packethost/pkg/log"
 	"github.com/pkg/errors"
+	"github.com/tinkerbell/tink/artifact"
 	"github.com/tinkerbell/tink/db"
 	"github.com/tinkerbell/tink/metrics"
 	"github.com/tinkerbell/tink/protos/hardware"
+	"github.com/tinkerbell/tink/protos/secret"
 	"github.com/tinkerbell/tink/protos/template"
 	"github.com/tinkerbell/tink/protos/workflow"
+	wflow "github.com/tinkerbell/tink/workflow"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 var (
@@ -40,20 +46,62 @@ type server struct {
 
 	watchLock sync.RWMutex
 	watch     map[string]chan string
+
+	workerGracePeriod time.Duration
+	workers           workerSet
+
+	secretKey []byte
+
+	artifacts       artifact.Store
+	maxArtifactSize int64
+
+	volumePolicy *wflow.VolumePolicy
 }
 
 // SetupGRPC setup and return a gRPC server
-func SetupGRPC(ctx context.Context, log log.Logger, facility string, db *db.TinkDB, errCh chan<- error) ([]byte, time.Time) {
+func SetupGRPC(ctx context.Context, log log.Logger, facility string, db db.Database, errCh chan<- error) ([]byte, time.Time) {
 	params := []grpc.ServerOption{
-		grpc.UnaryInterceptor(grpc_prometheus.UnaryServerInterceptor),
-		grpc.StreamInterceptor(grpc_prometheus.StreamServerInterceptor),
+		grpc.ChainUnaryInterceptor(otelgrpc.UnaryServerInterceptor(), grpc_prometheus.UnaryServerInterceptor),
+		grpc.ChainStreamInterceptor(otelgrpc.StreamServerInterceptor(), grpc_prometheus.StreamServerInterceptor),
 	}
 	logger = log
 	metrics.SetupMetrics(facility, logger)
 	server := &server{
-		db:      db,
-		dbReady: true,
+		db:                db,
+		dbReady:           true,
+		workerGracePeriod: getWorkerGracePeriod(),
+	}
+	secretKey, err := getSecretKey()
+	if err != nil {
+		logger.Error(err)
+		panic(err)
+	}
+	server.secretKey = secretKey
+	if secretKey == nil {
+		logger.Info(errSecretsDisabled)
+	}
+
+	volumePolicy, err := getVolumePolicy()
+	if err != nil {
+		logger.Error(err)
+		panic(err)
+	}
+	server.volumePolicy = volumePolicy
+	if volumePolicy == nil {
+		logger.Info(msgVolumePolicyDisabled)
+	}
+
+	if err := seedWorkflowMetrics(ctx, db); err != nil {
+		logger.With("error", err).Info(errSeedWorkflowMetrics)
+	}
+
+	server.maxArtifactSize = getMaxArtifactSize()
+	if store, err := ArtifactStore(); err != nil {
+		logger.With("error", err).Info(errArtifactsDisabled)
+	} else {
+		server.artifacts = store
 	}
+
 	if cert := os.Getenv("TINKERBELL_TLS_CERT"); cert != "" {
 		server.cert = []byte(cert)
 		server.modT = time.Now()
@@ -69,6 +117,18 @@ func SetupGRPC(ctx context.Context, log log.Logger, facility string, db *db.Tink
 	template.RegisterTemplateServiceServer(s, server)
 	workflow.RegisterWorkflowServiceServer(s, server)
 	hardware.RegisterHardwareServiceServer(s, server)
+	secret.RegisterSecretServiceServer(s, server)
+
+	// the readiness of the server is reported by the standard health
+	// service, for the server as a whole and for each of its services
+	var services []string
+	for name := range s.GetServiceInfo() {
+		services = append(services, name)
+	}
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(s, healthServer)
+	server.updateReadiness(ctx, healthServer, services)
+	go server.watchReadiness(ctx, healthServer, services)
 
 	grpc_prometheus.Register(s)
 
@@ -87,6 +147,8 @@ func SetupGRPC(ctx context.Context, log log.Logger, facility string, db *db.Tink
 		errCh <- s.Serve(lis)
 	}()
 
+	go server.reapOrphanedWorkflows(ctx)
+
 	go func() {
 		<-ctx.Done()
 		s.GracefulStop()
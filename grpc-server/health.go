@@ -0,0 +1,96 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/tink/db"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	readinessInterval = 10 * time.Second
+	readinessTimeout  = 5 * time.Second
+
+	msgReady    = "server ready"
+	msgNotReady = "server not ready"
+)
+
+// pinger is implemented by the databases which can be unreachable
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// migrator is implemented by the databases whose schema can be outdated
+type migrator interface {
+	CheckRequiredMigrations() (int, error)
+}
+
+// checkReadiness checks that the database is reachable and its schema up to
+// date. The checks which do not apply to the database are skipped.
+func checkReadiness(ctx context.Context, d db.Database) error {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	if p, ok := d.(pinger); ok {
+		if err := p.Ping(ctx); err != nil {
+			return errors.Wrap(err, "database not reachable")
+		}
+	}
+	if m, ok := d.(migrator); ok {
+		pending, err := m.CheckRequiredMigrations()
+		if err != nil {
+			return errors.Wrap(err, "checking the database migrations")
+		}
+		if pending > 0 {
+			return fmt.Errorf("%d database migrations pending", pending)
+		}
+	}
+	return nil
+}
+
+// updateReadiness checks the readiness of the server and reports it through
+// the gRPC health service, for the server as a whole and for each service
+func (s *server) updateReadiness(ctx context.Context, hs *health.Server, services []string) {
+	err := checkReadiness(ctx, s.db)
+
+	s.dbLock.Lock()
+	wasReady := s.dbReady
+	s.dbReady = err == nil
+	s.dbLock.Unlock()
+
+	status := healthpb.HealthCheckResponse_SERVING
+	if err != nil {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	hs.SetServingStatus("", status)
+	for _, service := range services {
+		hs.SetServingStatus(service, status)
+	}
+
+	switch {
+	case err != nil && wasReady:
+		logger.With("error", err).Info(msgNotReady)
+	case err == nil && !wasReady:
+		logger.Info(msgReady)
+	}
+}
+
+// watchReadiness periodically updates the readiness of the server, until
+// the context is done
+func (s *server) watchReadiness(ctx context.Context, hs *health.Server, services []string) {
+	ticker := time.NewTicker(readinessInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			hs.Shutdown()
+			return
+		case <-ticker.C:
+			s.updateReadiness(ctx, hs, services)
+		}
+	}
+}
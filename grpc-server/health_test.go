@@ -0,0 +1,83 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tinkerbell/tink/db"
+	"github.com/tinkerbell/tink/db/mock"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// postgresDB is a database with the readiness checks of postgres
+type postgresDB struct {
+	mock.DB
+	pingErr error
+	pending int
+}
+
+func (d postgresDB) Ping(context.Context) error {
+	return d.pingErr
+}
+
+func (d postgresDB) CheckRequiredMigrations() (int, error) {
+	return d.pending, nil
+}
+
+func TestCheckReadiness(t *testing.T) {
+	testCases := map[string]struct {
+		db            db.Database
+		expectedError string
+	}{
+		"ready": {
+			db: postgresDB{},
+		},
+		"without checks": {
+			db: mock.DB{},
+		},
+		"database not reachable": {
+			db:            postgresDB{pingErr: errors.New("connection refused")},
+			expectedError: "database not reachable: connection refused",
+		},
+		"migrations pending": {
+			db:            postgresDB{pending: 2},
+			expectedError: "2 database migrations pending",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := checkReadiness(context.Background(), tc.db)
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestUpdateReadiness(t *testing.T) {
+	ctx := context.Background()
+	hs := health.NewServer()
+	services := []string{"workflow.WorkflowService"}
+	d := &postgresDB{pingErr: errors.New("connection refused")}
+	s := testServer(d)
+
+	s.updateReadiness(ctx, hs, services)
+	assert.False(t, s.dbReady)
+	for _, service := range append(services, "") {
+		res, err := hs.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		assert.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, res.GetStatus())
+	}
+
+	d.pingErr = nil
+	s.updateReadiness(ctx, hs, services)
+	assert.True(t, s.dbReady)
+	res, err := hs.Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.GetStatus())
+}
@@ -0,0 +1,167 @@
+package grpcserver
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/tinkerbell/tink/db"
+	"github.com/tinkerbell/tink/metrics"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+)
+
+const (
+	unknownTemplate = "unknown"
+
+	errSeedWorkflowMetrics = "failed to count the existing workflows"
+)
+
+// workflowState returns the state of a workflow as a whole, derived from
+// the state of its current action
+func workflowState(wfContext *pb.WorkflowContext) pb.State {
+	switch {
+	case wfContext.GetCurrentAction() == "":
+		return pb.State_STATE_PENDING
+	case wfContext.GetCurrentActionState() == pb.State_STATE_SUCCESS:
+		if wfContext.GetCurrentActionIndex() == wfContext.GetTotalNumberOfActions()-1 {
+			return pb.State_STATE_SUCCESS
+		}
+		return pb.State_STATE_RUNNING
+	case wfContext.GetCurrentActionState() == pb.State_STATE_PENDING:
+		return pb.State_STATE_RUNNING
+	}
+	return wfContext.GetCurrentActionState()
+}
+
+// stateLabel returns the value of the state label of a metric
+func stateLabel(state pb.State) string {
+	return strings.ToLower(strings.TrimPrefix(state.String(), "STATE_"))
+}
+
+// isFinalState checks if a workflow in the given state is done
+func isFinalState(state pb.State) bool {
+	return state == pb.State_STATE_SUCCESS ||
+		state == pb.State_STATE_FAILED ||
+		state == pb.State_STATE_TIMEOUT
+}
+
+// workflowTransition is the change of state of a workflow, along with the
+// action which caused it
+type workflowTransition struct {
+	from, to pb.State
+	action   *pb.WorkflowActionStatus
+}
+
+// observeWorkflow updates the workflow metrics after a workflow changed
+// state. The template and creation time of the workflow are only looked up
+// when needed.
+func observeWorkflow(ctx context.Context, d db.Database, wfID string, t workflowTransition) {
+	if t.from != t.to {
+		metrics.WorkflowsCurrent.WithLabelValues(stateLabel(t.from)).Dec()
+		metrics.WorkflowsCurrent.WithLabelValues(stateLabel(t.to)).Inc()
+	}
+	starting := t.from == pb.State_STATE_PENDING && t.to != pb.State_STATE_PENDING
+	finished := t.from != t.to && isFinalState(t.to)
+	actionDone := t.action != nil && isFinalState(t.action.GetActionStatus())
+	if !starting && !finished && !actionDone {
+		return
+	}
+
+	template, createdAt := workflowTemplate(ctx, d, wfID)
+	if actionDone {
+		actionState := stateLabel(t.action.GetActionStatus())
+		metrics.ActionDuration.WithLabelValues(template, t.action.GetActionName(), actionState).Observe(float64(t.action.GetSeconds()))
+		if t.action.GetActionStatus() != pb.State_STATE_SUCCESS {
+			metrics.ActionFailures.WithLabelValues(template, t.action.GetActionName(), actionState).Inc()
+		}
+	}
+	if createdAt.IsZero() {
+		return
+	}
+	if starting {
+		metrics.WorkflowStartDelay.WithLabelValues(template).Observe(time.Since(createdAt).Seconds())
+	}
+	if finished {
+		metrics.WorkflowDuration.WithLabelValues(template, stateLabel(t.to)).Observe(time.Since(createdAt).Seconds())
+	}
+}
+
+// workflowTemplate returns the name of the template of a workflow and the
+// time it was created at, zero if unknown
+func workflowTemplate(ctx context.Context, d db.Database, wfID string) (string, time.Time) {
+	wf, err := d.GetWorkflow(ctx, wfID)
+	if err != nil {
+		logger.With("workflowID", wfID).Error(err)
+		return unknownTemplate, time.Time{}
+	}
+	var createdAt time.Time
+	if wf.CreatedAt != nil {
+		createdAt, _ = ptypes.Timestamp(wf.CreatedAt)
+	}
+	if wf.Template == "" {
+		return unknownTemplate, createdAt
+	}
+	name, _, err := d.GetTemplate(ctx, wf.Template)
+	if err != nil || name == "" {
+		return unknownTemplate, createdAt
+	}
+	return name, createdAt
+}
+
+// seedWorkflowMetrics counts the existing workflows by state, so that the
+// gauges survive the restarts of the server
+func seedWorkflowMetrics(ctx context.Context, d db.Database) error {
+	var ids []string
+	err := d.ListWorkflows(func(wf db.Workflow) error {
+		ids = append(ids, wf.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	counts := map[pb.State]int{}
+	for _, id := range ids {
+		wfContext, err := d.GetWorkflowContexts(ctx, id)
+		if err != nil {
+			return err
+		}
+		counts[workflowState(wfContext)]++
+	}
+	for state, n := range counts {
+		metrics.WorkflowsCurrent.WithLabelValues(stateLabel(state)).Set(float64(n))
+	}
+	return nil
+}
+
+// workerSet keeps track of the last time the workers were seen, to count
+// the active ones. Its zero value is ready to use.
+type workerSet struct {
+	mu       sync.Mutex
+	lastSeen map[string]time.Time
+}
+
+// seen records a worker as active and updates the number of active workers
+func (ws *workerSet) seen(id string, now time.Time, gracePeriod time.Duration) {
+	ws.mu.Lock()
+	if ws.lastSeen == nil {
+		ws.lastSeen = map[string]time.Time{}
+	}
+	ws.lastSeen[id] = now
+	ws.mu.Unlock()
+	ws.refresh(now, gracePeriod)
+}
+
+// refresh forgets the workers which have not been seen during the grace
+// period and updates the number of active workers
+func (ws *workerSet) refresh(now time.Time, gracePeriod time.Duration) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	for id, seen := range ws.lastSeen {
+		if now.Sub(seen) > gracePeriod {
+			delete(ws.lastSeen, id)
+		}
+	}
+	metrics.WorkersActive.Set(float64(len(ws.lastSeen)))
+}
@@ -0,0 +1,141 @@
+package grpcserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tinkerbell/tink/db/memory"
+	"github.com/tinkerbell/tink/db/mock"
+	"github.com/tinkerbell/tink/metrics"
+	"github.com/tinkerbell/tink/protos/template"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+)
+
+const timingTemplate = `version: "0.1"
+name: timing
+global_timeout: 600
+tasks:
+  - name: "wipe"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "wipe_disk"
+      image: wipe
+      timeout: 60`
+
+func TestWorkflowState(t *testing.T) {
+	testCases := map[string]struct {
+		wfContext *pb.WorkflowContext
+		expected  pb.State
+	}{
+		"not started": {
+			wfContext: &pb.WorkflowContext{TotalNumberOfActions: 2},
+			expected:  pb.State_STATE_PENDING,
+		},
+		"action running": {
+			wfContext: &pb.WorkflowContext{CurrentAction: actionName, CurrentActionState: pb.State_STATE_RUNNING, TotalNumberOfActions: 2},
+			expected:  pb.State_STATE_RUNNING,
+		},
+		"action succeeded": {
+			wfContext: &pb.WorkflowContext{CurrentAction: actionName, CurrentActionState: pb.State_STATE_SUCCESS, TotalNumberOfActions: 2},
+			expected:  pb.State_STATE_RUNNING,
+		},
+		"last action succeeded": {
+			wfContext: &pb.WorkflowContext{CurrentAction: actionName, CurrentActionState: pb.State_STATE_SUCCESS, CurrentActionIndex: 1, TotalNumberOfActions: 2},
+			expected:  pb.State_STATE_SUCCESS,
+		},
+		"action timed out": {
+			wfContext: &pb.WorkflowContext{CurrentAction: actionName, CurrentActionState: pb.State_STATE_TIMEOUT, TotalNumberOfActions: 2},
+			expected:  pb.State_STATE_TIMEOUT,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, workflowState(tc.wfContext))
+		})
+	}
+}
+
+func TestObserveWorkflow(t *testing.T) {
+	d := mock.DB{}
+	gauge := func(state pb.State) float64 {
+		return testutil.ToFloat64(metrics.WorkflowsCurrent.WithLabelValues(stateLabel(state)))
+	}
+	running, failed := gauge(pb.State_STATE_RUNNING), gauge(pb.State_STATE_FAILED)
+	// the mock does not know the template of the workflow
+	failures := testutil.ToFloat64(metrics.ActionFailures.WithLabelValues(unknownTemplate, actionName, "failed"))
+
+	observeWorkflow(context.Background(), d, workflowID, workflowTransition{
+		from: pb.State_STATE_RUNNING,
+		to:   pb.State_STATE_FAILED,
+		action: &pb.WorkflowActionStatus{
+			ActionName:   actionName,
+			ActionStatus: pb.State_STATE_FAILED,
+			Seconds:      30,
+		},
+	})
+	assert.Equal(t, running-1, gauge(pb.State_STATE_RUNNING))
+	assert.Equal(t, failed+1, gauge(pb.State_STATE_FAILED))
+	assert.Equal(t, failures+1, testutil.ToFloat64(metrics.ActionFailures.WithLabelValues(unknownTemplate, actionName, "failed")))
+
+	// progress updates leave the metrics unchanged
+	observeWorkflow(context.Background(), d, workflowID, workflowTransition{
+		from:   pb.State_STATE_RUNNING,
+		to:     pb.State_STATE_RUNNING,
+		action: &pb.WorkflowActionStatus{ActionName: actionName, ActionStatus: pb.State_STATE_RUNNING},
+	})
+	assert.Equal(t, running-1, gauge(pb.State_STATE_RUNNING))
+}
+
+// TestWorkflowTimingMetrics goes through the database to get the creation
+// time of the workflow, without it the timing histograms are never observed
+func TestWorkflowTimingMetrics(t *testing.T) {
+	const workerID = "ce2e62ed-826f-4485-a39f-a82bb74338e2"
+	ctx := context.Background()
+	d := memory.New()
+	s := testServer(d)
+	require.NoError(t, d.InsertIntoDB(ctx, `{"id": "`+workerID+`", "network": {"interfaces": [{"dhcp": {"mac": "08:00:27:00:00:01"}}]}}`))
+	tmp, err := s.CreateTemplate(ctx, &template.WorkflowTemplate{Name: "timing", Data: timingTemplate})
+	require.NoError(t, err)
+	res, err := s.CreateWorkflow(ctx, &pb.CreateRequest{Template: tmp.Id, Hardware: hw})
+	require.NoError(t, err)
+
+	startDelay := func() uint64 { return sampleCount(t, metrics.WorkflowStartDelay.WithLabelValues("timing")) }
+	duration := func() uint64 { return sampleCount(t, metrics.WorkflowDuration.WithLabelValues("timing", "success")) }
+	delays, durations := startDelay(), duration()
+
+	for _, state := range []pb.State{pb.State_STATE_RUNNING, pb.State_STATE_SUCCESS} {
+		_, err := s.ReportActionStatus(ctx, &pb.WorkflowActionStatus{
+			WorkflowId:   res.Id,
+			TaskName:     "wipe",
+			ActionName:   "wipe_disk",
+			ActionStatus: state,
+			WorkerId:     workerID,
+		})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, delays+1, startDelay())
+	assert.Equal(t, durations+1, duration())
+}
+
+func sampleCount(t *testing.T, o prometheus.Observer) uint64 {
+	var m dto.Metric
+	require.NoError(t, o.(prometheus.Metric).Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestWorkerSet(t *testing.T) {
+	var ws workerSet
+	now := time.Now()
+	ws.seen("worker-1", now.Add(-time.Hour), time.Hour)
+	ws.seen("worker-2", now, time.Minute)
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.WorkersActive))
+
+	ws.refresh(now.Add(2*time.Minute), time.Minute)
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.WorkersActive))
+}
@@ -0,0 +1,104 @@
+package grpcserver
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/tinkerbell/tink/db"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+)
+
+const (
+	defaultWorkerGracePeriod = 10 * time.Minute
+	reaperInterval           = 30 * time.Second
+
+	msgHeartbeatLost = "worker heartbeat lost"
+)
+
+// getWorkerGracePeriod returns how long a worker can stay silent before the
+// action it is executing is considered orphaned
+func getWorkerGracePeriod() time.Duration {
+	period := os.Getenv("TINKERBELL_WORKER_GRACE_PERIOD")
+	if period == "" {
+		return defaultWorkerGracePeriod
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		logger.With("gracePeriod", period).Info("invalid worker grace period, using the default")
+		return defaultWorkerGracePeriod
+	}
+	return d
+}
+
+// reapOrphanedWorkflows periodically times out the running actions of the
+// workers which stopped sending heartbeats
+func (s *server) reapOrphanedWorkflows(ctx context.Context) {
+	ticker := time.NewTicker(reaperInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.workers.refresh(time.Now(), s.workerGracePeriod)
+			if err := reapOrphanedWorkflows(ctx, s.db, time.Now().Add(-s.workerGracePeriod)); err != nil {
+				logger.Error(err)
+			}
+		}
+	}
+}
+
+// reapOrphanedWorkflows marks as timed out the running actions of the
+// workers which have not been seen since lastSeen. The reboot actions are
+// left running: their workers send no heartbeat until they boot again, and
+// the action completes when they register.
+func reapOrphanedWorkflows(ctx context.Context, db db.Database, lastSeen time.Time) error {
+	wfs, err := db.GetOrphanedWorkflows(ctx, lastSeen)
+	if err != nil {
+		return err
+	}
+	for _, wf := range wfs {
+		wfContext, err := db.GetWorkflowContexts(ctx, wf)
+		if err != nil {
+			return err
+		}
+		if wfContext.GetCurrentActionState() != pb.State_STATE_RUNNING {
+			continue
+		}
+		actions, err := db.GetWorkflowActions(ctx, wf)
+		if err != nil {
+			return err
+		}
+		index := wfContext.GetCurrentActionIndex()
+		if index < int64(len(actions.GetActionList())) && actions.GetActionList()[index].GetReboot() {
+			continue
+		}
+		// the action is only timed out if the worker did not report it
+		// since its state was read
+		timedOut, err := db.TimeoutOrphanedAction(ctx, wf, index)
+		if err != nil {
+			return err
+		}
+		if !timedOut {
+			continue
+		}
+		prevState := workflowState(wfContext)
+		wfContext.CurrentActionState = pb.State_STATE_TIMEOUT
+		event := &pb.WorkflowActionStatus{
+			WorkflowId:   wf,
+			WorkerId:     wfContext.GetCurrentWorker(),
+			TaskName:     wfContext.GetCurrentTask(),
+			ActionName:   wfContext.GetCurrentAction(),
+			ActionStatus: pb.State_STATE_TIMEOUT,
+			Message:      msgHeartbeatLost,
+		}
+		err = db.InsertIntoWorkflowEventTable(ctx, event, time.Now())
+		if err != nil {
+			return err
+		}
+		observeWorkflow(ctx, db, wf, workflowTransition{from: prevState, to: pb.State_STATE_TIMEOUT, action: event})
+		logger.With("workflowID", wf, "workerID", wfContext.GetCurrentWorker()).Info(msgHeartbeatLost)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package grpcserver
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/tinkerbell/tink/db/mock"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+)
+
+func TestReapOrphanedWorkflows(t *testing.T) {
+	orphaned := func(ctx context.Context, lastSeen time.Time) ([]string, error) {
+		return []string{workflowID}, nil
+	}
+	contextWithState := func(state pb.State) func(ctx context.Context, wfID string) (*pb.WorkflowContext, error) {
+		return func(ctx context.Context, wfID string) (*pb.WorkflowContext, error) {
+			return &pb.WorkflowContext{
+				WorkflowId:           workflowID,
+				CurrentWorker:        workerID,
+				CurrentTask:          taskName,
+				CurrentAction:        actionName,
+				CurrentActionState:   state,
+				TotalNumberOfActions: 1,
+			}, nil
+		}
+	}
+	actionsWithReboot := func(reboot bool) func(ctx context.Context, wfID string) (*pb.WorkflowActionList, error) {
+		return func(ctx context.Context, wfID string) (*pb.WorkflowActionList, error) {
+			return &pb.WorkflowActionList{ActionList: []*pb.WorkflowAction{
+				{WorkerId: workerID, TaskName: taskName, Name: actionName, Reboot: reboot},
+			}}, nil
+		}
+	}
+	type (
+		args struct {
+			db mock.DB
+		}
+		want struct {
+			expectedError bool
+			state         pb.State
+			event         string
+		}
+	)
+	testCases := map[string]struct {
+		args args
+		want want
+	}{
+		"no orphaned workflows": {
+			args: args{
+				db: mock.DB{
+					GetOrphanedWorkflowsFunc: func(ctx context.Context, lastSeen time.Time) ([]string, error) {
+						return nil, nil
+					},
+				},
+			},
+		},
+		"failed to get orphaned workflows": {
+			args: args{
+				db: mock.DB{
+					GetOrphanedWorkflowsFunc: func(ctx context.Context, lastSeen time.Time) ([]string, error) {
+						return nil, errors.New("SELECT from workflow_state")
+					},
+				},
+			},
+			want: want{
+				expectedError: true,
+			},
+		},
+		"running action timed out": {
+			args: args{
+				db: mock.DB{
+					GetOrphanedWorkflowsFunc: orphaned,
+					GetWorkflowContextsFunc:  contextWithState(pb.State_STATE_RUNNING),
+					GetWorkflowActionsFunc:   actionsWithReboot(false),
+				},
+			},
+			want: want{
+				state: pb.State_STATE_TIMEOUT,
+				event: msgHeartbeatLost,
+			},
+		},
+		"reboot action left running": {
+			args: args{
+				db: mock.DB{
+					GetOrphanedWorkflowsFunc: orphaned,
+					GetWorkflowContextsFunc:  contextWithState(pb.State_STATE_RUNNING),
+					GetWorkflowActionsFunc:   actionsWithReboot(true),
+				},
+			},
+		},
+		"action reported in the meantime": {
+			args: args{
+				db: mock.DB{
+					GetOrphanedWorkflowsFunc: orphaned,
+					GetWorkflowContextsFunc:  contextWithState(pb.State_STATE_RUNNING),
+					GetWorkflowActionsFunc:   actionsWithReboot(false),
+					TimeoutOrphanedActionFunc: func(ctx context.Context, wfID string, actionIndex int64) (bool, error) {
+						return false, nil
+					},
+				},
+			},
+		},
+		"action finished in the meantime": {
+			args: args{
+				db: mock.DB{
+					GetOrphanedWorkflowsFunc: orphaned,
+					GetWorkflowContextsFunc:  contextWithState(pb.State_STATE_SUCCESS),
+				},
+			},
+		},
+		"failed to time out the action": {
+			args: args{
+				db: mock.DB{
+					GetOrphanedWorkflowsFunc: orphaned,
+					GetWorkflowContextsFunc:  contextWithState(pb.State_STATE_RUNNING),
+					GetWorkflowActionsFunc:   actionsWithReboot(false),
+					TimeoutOrphanedActionFunc: func(ctx context.Context, wfID string, actionIndex int64) (bool, error) {
+						return false, errors.New("UPDATE workflow_state")
+					},
+				},
+			},
+			want: want{
+				expectedError: true,
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var (
+				state pb.State
+				event string
+			)
+			if tc.args.db.TimeoutOrphanedActionFunc == nil {
+				tc.args.db.TimeoutOrphanedActionFunc = func(ctx context.Context, wfID string, actionIndex int64) (bool, error) {
+					state = pb.State_STATE_TIMEOUT
+					return true, nil
+				}
+			}
+			tc.args.db.InsertIntoWorkflowEventTableFunc = func(ctx context.Context, wfEvent *pb.WorkflowActionStatus, time time.Time) error {
+				event = wfEvent.GetMessage()
+				return nil
+			}
+			err := reapOrphanedWorkflows(context.TODO(), tc.args.db, time.Now().Add(-defaultWorkerGracePeriod))
+			if tc.want.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.state, state)
+			assert.Equal(t, tc.want.event, event)
+		})
+	}
+}
+
+func TestGetWorkerGracePeriod(t *testing.T) {
+	defer os.Unsetenv("TINKERBELL_WORKER_GRACE_PERIOD")
+
+	assert.Equal(t, defaultWorkerGracePeriod, getWorkerGracePeriod())
+
+	os.Setenv("TINKERBELL_WORKER_GRACE_PERIOD", "5m")
+	assert.Equal(t, 5*time.Minute, getWorkerGracePeriod())
+
+	os.Setenv("TINKERBELL_WORKER_GRACE_PERIOD", "five minutes")
+	assert.Equal(t, defaultWorkerGracePeriod, getWorkerGracePeriod())
+}
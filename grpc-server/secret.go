@@ -0,0 +1,268 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"io"
+	"os"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/tink/db"
+	"github.com/tinkerbell/tink/protos/secret"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+	wflow "github.com/tinkerbell/tink/workflow"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	errInvalidSecretName  = "invalid secret name"
+	errInvalidSecretValue = "invalid secret value"
+	errSecretsDisabled    = "secrets are disabled, TINKERBELL_SECRETS_KEY is not set"
+	errSecretNotFound     = "secret not found: %s"
+	errInvalidSecretKey   = "TINKERBELL_SECRETS_KEY must be a base64 encoded 32 bytes key"
+)
+
+// getSecretKey returns the AES-256 key the secrets are encrypted with, nil if
+// secrets are disabled
+func getSecretKey() ([]byte, error) {
+	encoded := os.Getenv("TINKERBELL_SECRETS_KEY")
+	if encoded == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New(errInvalidSecretKey)
+	}
+	return key, nil
+}
+
+func encryptSecret(key, value []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, value, nil), nil
+}
+
+func decryptSecret(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("malformed secret")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// CreateSecret implements secret.CreateSecret
+func (s *server) CreateSecret(ctx context.Context, in *secret.Secret) (*secret.Empty, error) {
+	if s.secretKey == nil {
+		return &secret.Empty{}, status.Errorf(codes.FailedPrecondition, errSecretsDisabled)
+	}
+	if !wflow.IsValidSecretName(in.GetName()) {
+		return &secret.Empty{}, status.Errorf(codes.InvalidArgument, errInvalidSecretName)
+	}
+	if len(in.GetValue()) == 0 {
+		return &secret.Empty{}, status.Errorf(codes.InvalidArgument, errInvalidSecretValue)
+	}
+	value, err := encryptSecret(s.secretKey, []byte(in.GetValue()))
+	if err != nil {
+		return &secret.Empty{}, status.Errorf(codes.Internal, err.Error())
+	}
+	err = s.db.CreateSecret(ctx, in.GetName(), value, time.Now())
+	if err != nil {
+		l := logger
+		if pqErr := db.Error(err); pqErr != nil {
+			l = l.With("detail", pqErr.Detail, "where", pqErr.Where)
+		}
+		l.Error(err)
+		return &secret.Empty{}, status.Errorf(codes.Aborted, err.Error())
+	}
+	logger.With("secret", in.GetName()).Info("secret created")
+	return &secret.Empty{}, nil
+}
+
+// DeleteSecret implements secret.DeleteSecret
+func (s *server) DeleteSecret(ctx context.Context, in *secret.GetRequest) (*secret.Empty, error) {
+	if !wflow.IsValidSecretName(in.GetName()) {
+		return &secret.Empty{}, status.Errorf(codes.InvalidArgument, errInvalidSecretName)
+	}
+	err := s.db.DeleteSecret(ctx, in.GetName())
+	if err != nil {
+		logger.Error(err)
+		return &secret.Empty{}, status.Errorf(codes.Aborted, err.Error())
+	}
+	logger.With("secret", in.GetName()).Info("secret deleted")
+	return &secret.Empty{}, nil
+}
+
+// ListSecrets implements secret.ListSecrets, the values of the secrets are
+// never returned
+func (s *server) ListSecrets(_ *secret.Empty, stream secret.SecretService_ListSecretsServer) error {
+	return s.db.ListSecrets(func(name string, crTime, upTime *timestamp.Timestamp) error {
+		return stream.Send(&secret.Secret{Name: name, CreatedAt: crTime, UpdatedAt: upTime})
+	})
+}
+
+// getSecrets returns the decrypted values of the named secrets
+func (s *server) getSecrets(ctx context.Context, names []string) (map[string]string, error) {
+	values := map[string]string{}
+	if len(names) == 0 {
+		return values, nil
+	}
+	if s.secretKey == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, errSecretsDisabled)
+	}
+	for _, name := range names {
+		if _, ok := values[name]; ok {
+			continue
+		}
+		data, err := s.db.GetSecret(ctx, name)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, status.Errorf(codes.FailedPrecondition, errSecretNotFound, name)
+			}
+			return nil, status.Errorf(codes.Aborted, err.Error())
+		}
+		value, err := decryptSecret(s.secretKey, data)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, errors.Wrap(err, name).Error())
+		}
+		values[name] = string(value)
+	}
+	return values, nil
+}
+
+// actionSecretRefs returns the names of the secrets referenced by an action
+func actionSecretRefs(action *pb.WorkflowAction) []string {
+	var names []string
+	for _, env := range action.GetEnvironment() {
+		names = append(names, wflow.SecretRefs(env)...)
+	}
+	for _, arg := range action.GetCommand() {
+		names = append(names, wflow.SecretRefs(arg)...)
+	}
+	for _, arg := range action.GetOnTimeout() {
+		names = append(names, wflow.SecretRefs(arg)...)
+	}
+	for _, arg := range action.GetOnFailure() {
+		names = append(names, wflow.SecretRefs(arg)...)
+	}
+	return names
+}
+
+// resolveActionSecrets substitutes the secret references in the environment,
+// command and timeout and failure commands of the action the given worker
+// executes next. The other actions
+// keep their references.
+//
+// tink-server does not authenticate the workers: the secrets are handed to
+// any client sending the id of the worker of the next action. The access to
+// the gRPC API must be restricted to the workers, and only the secrets of the
+// action about to run are decrypted, never the ones of the whole workflow.
+func (s *server) resolveActionSecrets(ctx context.Context, actions *pb.WorkflowActionList, wfContext *pb.WorkflowContext, workerID string) error {
+	if workerID == "" {
+		return nil
+	}
+	index := dispatchedActionIndex(wfContext, len(actions.GetActionList()))
+	if index < 0 {
+		return nil
+	}
+	action := actions.GetActionList()[index]
+	if action.GetWorkerId() != workerID {
+		return nil
+	}
+	names := actionSecretRefs(action)
+	if len(names) == 0 {
+		return nil
+	}
+	values, err := s.getSecrets(ctx, names)
+	if err != nil {
+		return err
+	}
+	for i, env := range action.Environment {
+		action.Environment[i] = wflow.ResolveSecretRefs(env, values)
+	}
+	for i, arg := range action.Command {
+		action.Command[i] = wflow.ResolveSecretRefs(arg, values)
+	}
+	for i, arg := range action.OnTimeout {
+		action.OnTimeout[i] = wflow.ResolveSecretRefs(arg, values)
+	}
+	for i, arg := range action.OnFailure {
+		action.OnFailure[i] = wflow.ResolveSecretRefs(arg, values)
+	}
+	for _, v := range values {
+		action.Secrets = append(action.Secrets, v)
+	}
+	return nil
+}
+
+// dispatchedActionIndex returns the index of the action of a workflow its
+// worker executes next, the running action being executed again when its
+// worker restarts. It is -1 when no action can be started.
+func dispatchedActionIndex(wfContext *pb.WorkflowContext, total int) int {
+	index := nextActionIndex(wfContext)
+	if wfContext.GetCurrentAction() != "" && wfContext.GetCurrentActionState() == pb.State_STATE_RUNNING {
+		index = int(wfContext.GetCurrentActionIndex())
+	}
+	if wfContext.GetPaused() || index >= total {
+		return -1
+	}
+	return index
+}
+
+// actionSecrets returns the values of the secrets referenced by an action, to
+// be redacted from what its worker reports. The secrets which cannot be loaded
+// anymore, e.g. deleted since the action started, are skipped so that the
+// reports of the worker are never refused.
+func (s *server) actionSecrets(ctx context.Context, action *pb.WorkflowAction) []string {
+	var secrets []string
+	seen := map[string]struct{}{}
+	for _, name := range actionSecretRefs(action) {
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		values, err := s.getSecrets(ctx, []string{name})
+		if err != nil {
+			logger.With("secret", name, "actionName", action.GetName()).Error(err)
+			continue
+		}
+		secrets = append(secrets, values[name])
+	}
+	return secrets
+}
+
+// redactActionStatus removes the values of the secrets from the message and
+// the outputs reported for an action
+func redactActionStatus(req *pb.WorkflowActionStatus, secrets []string) {
+	if len(secrets) == 0 {
+		return
+	}
+	req.Message = wflow.RedactSecrets(req.Message, secrets)
+	for name, value := range req.Outputs {
+		req.Outputs[name] = wflow.RedactSecrets(value, secrets)
+	}
+}
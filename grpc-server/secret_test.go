@@ -0,0 +1,379 @@
+package grpcserver
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/tinkerbell/tink/db/mock"
+	"github.com/tinkerbell/tink/protos/secret"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+)
+
+const (
+	secretName  = "ipmi-password"
+	secretValue = "s3cr3t"
+)
+
+var testSecretKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestGetSecretKey(t *testing.T) {
+	defer os.Unsetenv("TINKERBELL_SECRETS_KEY")
+
+	key, err := getSecretKey()
+	assert.NoError(t, err)
+	assert.Nil(t, key)
+
+	os.Setenv("TINKERBELL_SECRETS_KEY", "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	key, err = getSecretKey()
+	assert.NoError(t, err)
+	assert.Equal(t, testSecretKey, key)
+
+	os.Setenv("TINKERBELL_SECRETS_KEY", "c2hvcnQ=")
+	_, err = getSecretKey()
+	assert.Error(t, err)
+}
+
+func TestEncryptSecret(t *testing.T) {
+	data, err := encryptSecret(testSecretKey, []byte(secretValue))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), secretValue)
+
+	value, err := decryptSecret(testSecretKey, data)
+	assert.NoError(t, err)
+	assert.Equal(t, secretValue, string(value))
+
+	_, err = decryptSecret([]byte("abcdef0123456789abcdef0123456789"), data)
+	assert.Error(t, err)
+}
+
+func TestCreateSecret(t *testing.T) {
+	type (
+		args struct {
+			db        mock.DB
+			secretKey []byte
+			name      string
+			value     string
+		}
+		want struct {
+			expectedError bool
+		}
+	)
+	testCases := map[string]struct {
+		args args
+		want want
+	}{
+		"secrets disabled": {
+			args: args{
+				db:    mock.DB{},
+				name:  secretName,
+				value: secretValue,
+			},
+			want: want{
+				expectedError: true,
+			},
+		},
+		"invalid name": {
+			args: args{
+				db:        mock.DB{},
+				secretKey: testSecretKey,
+				name:      "ipmi password",
+				value:     secretValue,
+			},
+			want: want{
+				expectedError: true,
+			},
+		},
+		"empty value": {
+			args: args{
+				db:        mock.DB{},
+				secretKey: testSecretKey,
+				name:      secretName,
+			},
+			want: want{
+				expectedError: true,
+			},
+		},
+		"database failure": {
+			args: args{
+				db: mock.DB{
+					CreateSecretFunc: func(ctx context.Context, name string, value []byte, time time.Time) error {
+						return errors.New("INSERT in to secret")
+					},
+				},
+				secretKey: testSecretKey,
+				name:      secretName,
+				value:     secretValue,
+			},
+			want: want{
+				expectedError: true,
+			},
+		},
+		"secret stored encrypted": {
+			args: args{
+				db: mock.DB{
+					CreateSecretFunc: func(ctx context.Context, name string, value []byte, time time.Time) error {
+						if string(value) == secretValue {
+							return errors.New("secret stored in plaintext")
+						}
+						return nil
+					},
+				},
+				secretKey: testSecretKey,
+				name:      secretName,
+				value:     secretValue,
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			s := testServer(tc.args.db)
+			s.secretKey = tc.args.secretKey
+			_, err := s.CreateSecret(context.TODO(), &secret.Secret{Name: tc.args.name, Value: tc.args.value})
+			if tc.want.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestResolveActionSecrets(t *testing.T) {
+	encrypted, err := encryptSecret(testSecretKey, []byte(secretValue))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const otherWorkerID = "f1d5f4e0-4b71-4c2d-9ad1-3a6b4f0b7d0e"
+	actions := func() *pb.WorkflowActionList {
+		return &pb.WorkflowActionList{
+			ActionList: []*pb.WorkflowAction{
+				{
+					WorkerId:    workerID,
+					Name:        actionName,
+					TaskName:    taskName,
+					Environment: []string{`IPMI_PASSWORD={{ secret "ipmi-password" }}`},
+					Command:     []string{"ipmitool", "-P", `{{ secret "ipmi-password" }}`},
+					OnTimeout:   []string{"ipmitool", "-P", `{{ secret "ipmi-password" }}`, "power", "reset"},
+					OnFailure:   []string{"ipmitool", "-P", `{{ secret "ipmi-password" }}`, "power", "off"},
+				},
+				{
+					WorkerId:    otherWorkerID,
+					Name:        actionName,
+					TaskName:    taskName,
+					Environment: []string{`IPMI_PASSWORD={{ secret "ipmi-password" }}`},
+				},
+				{
+					WorkerId:    workerID,
+					Name:        "power-off",
+					TaskName:    taskName,
+					Environment: []string{`IPMI_PASSWORD={{ secret "ipmi-password" }}`},
+					Command:     []string{"ipmitool", "-P", `{{ secret "ipmi-password" }}`},
+					OnTimeout:   []string{"ipmitool", "-P", `{{ secret "ipmi-password" }}`, "power", "reset"},
+					OnFailure:   []string{"ipmitool", "-P", `{{ secret "ipmi-password" }}`, "power", "off"},
+				},
+			},
+		}
+	}
+	found := mock.DB{
+		GetSecretFunc: func(ctx context.Context, name string) ([]byte, error) {
+			return encrypted, nil
+		},
+	}
+	type (
+		args struct {
+			db        mock.DB
+			secretKey []byte
+			wfContext *pb.WorkflowContext
+			workerID  string
+		}
+		want struct {
+			expectedError bool
+			// resolved is the index of the action whose secrets are
+			// resolved, -1 for none
+			resolved int
+		}
+	)
+	testCases := map[string]struct {
+		args args
+		want want
+	}{
+		"resolved for the owning worker": {
+			args: args{
+				db:        found,
+				secretKey: testSecretKey,
+				wfContext: &pb.WorkflowContext{},
+				workerID:  workerID,
+			},
+			want: want{resolved: 0},
+		},
+		"resolved for the next action only": {
+			args: args{
+				db:        found,
+				secretKey: testSecretKey,
+				wfContext: &pb.WorkflowContext{CurrentAction: actionName, CurrentActionIndex: 1, CurrentActionState: pb.State_STATE_SUCCESS},
+				workerID:  workerID,
+			},
+			want: want{resolved: 2},
+		},
+		"not resolved for the action of another worker": {
+			args: args{
+				db:        found,
+				secretKey: testSecretKey,
+				wfContext: &pb.WorkflowContext{CurrentAction: actionName, CurrentActionIndex: 0, CurrentActionState: pb.State_STATE_SUCCESS},
+				workerID:  workerID,
+			},
+			want: want{resolved: -1},
+		},
+		"not resolved after a failure": {
+			args: args{
+				db:        found,
+				secretKey: testSecretKey,
+				wfContext: &pb.WorkflowContext{CurrentAction: actionName, CurrentActionIndex: 0, CurrentActionState: pb.State_STATE_FAILED},
+				workerID:  workerID,
+			},
+			want: want{resolved: -1},
+		},
+		"not resolved without worker id": {
+			args: args{
+				db:        mock.DB{},
+				secretKey: testSecretKey,
+				wfContext: &pb.WorkflowContext{},
+			},
+			want: want{resolved: -1},
+		},
+		"secret not found": {
+			args: args{
+				db: mock.DB{
+					GetSecretFunc: func(ctx context.Context, name string) ([]byte, error) {
+						return nil, sql.ErrNoRows
+					},
+				},
+				secretKey: testSecretKey,
+				wfContext: &pb.WorkflowContext{},
+				workerID:  workerID,
+			},
+			want: want{expectedError: true},
+		},
+		"secrets disabled": {
+			args: args{
+				db:        mock.DB{},
+				wfContext: &pb.WorkflowContext{},
+				workerID:  workerID,
+			},
+			want: want{expectedError: true},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			s := testServer(tc.args.db)
+			s.secretKey = tc.args.secretKey
+			list := actions()
+			err := s.resolveActionSecrets(context.TODO(), list, tc.args.wfContext, tc.args.workerID)
+			if tc.want.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			for i, action := range list.ActionList {
+				if i != tc.want.resolved {
+					// the other actions never see the secrets
+					assert.Equal(t, actions().ActionList[i], action)
+					continue
+				}
+				assert.Equal(t, []string{"IPMI_PASSWORD=" + secretValue}, action.Environment)
+				assert.Equal(t, []string{"ipmitool", "-P", secretValue}, action.Command)
+				assert.Equal(t, []string{"ipmitool", "-P", secretValue, "power", "reset"}, action.OnTimeout)
+				assert.Equal(t, []string{"ipmitool", "-P", secretValue, "power", "off"}, action.OnFailure)
+			}
+		})
+	}
+}
+
+func TestGetWorkflowActionsSecretInOutput(t *testing.T) {
+	encrypted, err := encryptSecret(testSecretKey, []byte(secretValue))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := testServer(mock.DB{
+		GetWorkflowActionsFunc: func(ctx context.Context, wfID string) (*pb.WorkflowActionList, error) {
+			return &pb.WorkflowActionList{
+				ActionList: []*pb.WorkflowAction{
+					{
+						WorkerId:    workerID,
+						Name:        actionName,
+						TaskName:    taskName,
+						Environment: []string{"PASSWORD={{ outputs.leak.password }}"},
+					},
+				},
+			}, nil
+		},
+		GetWorkflowContextsFunc: func(ctx context.Context, wfID string) (*pb.WorkflowContext, error) {
+			return &pb.WorkflowContext{WorkflowId: wfID}, nil
+		},
+		GetWorkflowOutputsFunc: func(ctx context.Context, wfID string) (map[string]map[string]string, error) {
+			// stored before the outputs referencing secrets were refused
+			return map[string]map[string]string{
+				"leak": {"password": `{{ secret "ipmi-password" }}`},
+			}, nil
+		},
+		GetSecretFunc: func(ctx context.Context, name string) ([]byte, error) {
+			return encrypted, nil
+		},
+	})
+	s.secretKey = testSecretKey
+
+	res, err := s.GetWorkflowActions(context.TODO(), &pb.WorkflowActionsRequest{WorkflowId: workflowID, WorkerId: workerID})
+	assert.NoError(t, err)
+	// the output is substituted after the secrets, its reference is not resolved
+	assert.Equal(t, []string{`PASSWORD={{ secret "ipmi-password" }}`}, res.ActionList[0].Environment)
+	assert.Empty(t, res.ActionList[0].Secrets)
+}
+
+func TestActionSecrets(t *testing.T) {
+	encrypted, err := encryptSecret(testSecretKey, []byte(secretValue))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := testServer(mock.DB{
+		GetSecretFunc: func(ctx context.Context, name string) ([]byte, error) {
+			if name == secretName {
+				return encrypted, nil
+			}
+			return nil, sql.ErrNoRows
+		},
+	})
+	s.secretKey = testSecretKey
+	action := &pb.WorkflowAction{
+		Name:        actionName,
+		Environment: []string{`IPMI_PASSWORD={{ secret "ipmi-password" }}`, `TOKEN={{ secret "deleted" }}`},
+		Command:     []string{"ipmitool", "-P", `{{ secret "ipmi-password" }}`},
+	}
+	// the deleted secret does not prevent the others from being redacted
+	assert.Equal(t, []string{secretValue}, s.actionSecrets(context.TODO(), action))
+
+	// the secrets of the timeout and failure commands are redacted too
+	onFailure := &pb.WorkflowAction{
+		Name:      actionName,
+		OnFailure: []string{"ipmitool", "-P", `{{ secret "ipmi-password" }}`, "power", "off"},
+	}
+	assert.Equal(t, []string{secretValue}, s.actionSecrets(context.TODO(), onFailure))
+
+	// nothing is redacted when the secrets are disabled, without failing
+	s.secretKey = nil
+	assert.Empty(t, s.actionSecrets(context.TODO(), action))
+}
+
+func TestRedactActionStatus(t *testing.T) {
+	req := &pb.WorkflowActionStatus{
+		Message: "failed to login with " + secretValue,
+		Outputs: map[string]string{"password": secretValue, "user": "admin"},
+	}
+	redactActionStatus(req, []string{secretValue})
+	assert.Equal(t, "failed to login with ******", req.Message)
+	assert.Equal(t, map[string]string{"password": "******", "user": "admin"}, req.Outputs)
+}
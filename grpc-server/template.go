@@ -2,6 +2,7 @@ package grpcserver
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/google/uuid"
@@ -10,8 +11,13 @@ import (
 	"github.com/tinkerbell/tink/db"
 	"github.com/tinkerbell/tink/metrics"
 	"github.com/tinkerbell/tink/protos/template"
+	wflow "github.com/tinkerbell/tink/workflow"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+const errTemplateNotFound = "template not found"
+
 // CreateTemplate implements template.CreateTemplate
 func (s *server) CreateTemplate(ctx context.Context, in *template.WorkflowTemplate) (*template.CreateResponse, error) {
 	logger.Info("createtemplate")
@@ -28,6 +34,16 @@ func (s *server) CreateTemplate(ctx context.Context, in *template.WorkflowTempla
 	defer timer.ObserveDuration()
 
 	logger.Info(msg)
+	if err := s.checkTemplate(in.Data); err != nil {
+		metrics.CacheErrors.With(labels).Inc()
+		logger.Error(err)
+		return &template.CreateResponse{}, err
+	}
+	if err := s.checkIncludes(ctx, id.String(), in.Data); err != nil {
+		metrics.CacheErrors.With(labels).Inc()
+		logger.Error(err)
+		return &template.CreateResponse{}, err
+	}
 	err := s.db.CreateTemplate(ctx, in.Name, in.Data, id)
 	if err != nil {
 		metrics.CacheErrors.With(labels).Inc()
@@ -144,6 +160,18 @@ func (s *server) UpdateTemplate(ctx context.Context, in *template.WorkflowTempla
 	defer timer.ObserveDuration()
 
 	logger.Info(msg)
+	if in.Data != "" {
+		if err := s.checkTemplate(in.Data); err != nil {
+			metrics.CacheErrors.With(labels).Inc()
+			logger.Error(err)
+			return &template.Empty{}, err
+		}
+		if err := s.checkIncludes(ctx, in.Id, in.Data); err != nil {
+			metrics.CacheErrors.With(labels).Inc()
+			logger.Error(err)
+			return &template.Empty{}, err
+		}
+	}
 	err := s.db.UpdateTemplate(ctx, in.Name, in.Data, uuid.MustParse(in.Id))
 	logger.Info("done " + msg)
 	if err != nil {
@@ -156,3 +184,69 @@ func (s *server) UpdateTemplate(ctx context.Context, in *template.WorkflowTempla
 	}
 	return &template.Empty{}, err
 }
+
+// checkIncludes checks that the templates included by a template exist and
+// that none of them includes the template in turn
+func (s *server) checkIncludes(ctx context.Context, id, data string) error {
+	if _, _, err := wflow.ExpandIncludes(id, data, templateLookup(ctx, s.db)); err != nil {
+		return status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
+
+// templateLookup resolves the includes of the templates against the stored
+// templates, an include refers to a template by its id or its name
+func templateLookup(ctx context.Context, d db.Database) wflow.TemplateLookup {
+	return func(ref string) (string, string, error) {
+		var (
+			id, data string
+			err      error
+		)
+		if _, uuidErr := uuid.Parse(ref); uuidErr == nil {
+			id = ref
+			_, data, err = d.GetTemplate(ctx, ref)
+		} else {
+			id, data, err = d.GetTemplateByName(ctx, ref)
+		}
+		if err == sql.ErrNoRows {
+			return "", "", errors.New(errTemplateNotFound)
+		}
+		return id, data, err
+	}
+}
+
+// ValidateTemplate implements template.ValidateTemplate
+func (s *server) ValidateTemplate(ctx context.Context, in *template.WorkflowTemplate) (*template.ValidateTemplateResponse, error) {
+	logger.Info("validatetemplate")
+	labels := prometheus.Labels{"method": "ValidateTemplate", "op": "validate"}
+	metrics.CacheInFlight.With(labels).Inc()
+	defer metrics.CacheInFlight.With(labels).Dec()
+
+	metrics.CacheTotals.With(labels).Inc()
+	timer := prometheus.NewTimer(metrics.CacheDuration.With(labels))
+	defer timer.ObserveDuration()
+
+	issues := wflow.Lint(in.GetData())
+	// the includes and the volume policy depend on the server, they are only
+	// checked once the template itself is valid
+	if !wflow.HasErrors(issues) {
+		if err := s.checkIncludes(ctx, in.GetId(), in.GetData()); err != nil {
+			issues = append(issues, wflow.Issue{Severity: wflow.SeverityError, Path: "tasks", Message: status.Convert(err).Message()})
+		} else if err := s.checkTemplate(in.GetData()); err != nil {
+			issues = append(issues, wflow.Issue{Severity: wflow.SeverityError, Message: status.Convert(err).Message()})
+		}
+	}
+
+	res := &template.ValidateTemplateResponse{}
+	for _, i := range issues {
+		res.Issues = append(res.Issues, &template.TemplateIssue{
+			Severity: i.Severity,
+			Line:     int32(i.Line),
+			Column:   int32(i.Column),
+			Path:     i.Path,
+			Message:  i.Message,
+		})
+	}
+	logger.With("issues", len(res.Issues)).Info("done validating a template")
+	return res, nil
+}
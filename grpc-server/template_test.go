@@ -5,8 +5,12 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/tinkerbell/tink/db/memory"
 	"github.com/tinkerbell/tink/db/mock"
 	pb "github.com/tinkerbell/tink/protos/template"
+	"github.com/tinkerbell/tink/protos/workflow"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -107,3 +111,105 @@ func TestCreateTemplate(t *testing.T) {
 		})
 	}
 }
+
+func TestTemplateIncludes(t *testing.T) {
+	const (
+		snippet = `version: "0.1"
+name: common-hello
+global_timeout: 600
+tasks:
+  - name: "hello world"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "hello_world"
+      image: hello-world
+      timeout: 60`
+		including = `version: "0.1"
+name: hello_twice
+global_timeout: 600
+tasks:
+  - include: common-hello
+  - name: "hello again"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "hello_again"
+      image: hello-world
+      timeout: 60`
+		loop = `version: "0.1"
+name: common-hello
+global_timeout: 600
+tasks:
+  - include: hello_twice`
+	)
+
+	ctx := context.Background()
+	d := memory.New()
+	s := testServer(d)
+	err := d.InsertIntoDB(ctx, `{"id": "ce2e62ed-826f-4485-a39f-a82bb74338e2", "network": {"interfaces": [{"dhcp": {"mac": "08:00:27:00:00:01"}}]}}`)
+	assert.NoError(t, err)
+
+	_, err = s.CreateTemplate(ctx, &pb.WorkflowTemplate{Name: "hello_twice", Data: including})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	snippetTmp, err := s.CreateTemplate(ctx, &pb.WorkflowTemplate{Name: "common-hello", Data: snippet})
+	assert.NoError(t, err)
+	tmp, err := s.CreateTemplate(ctx, &pb.WorkflowTemplate{Name: "hello_twice", Data: including})
+	assert.NoError(t, err)
+
+	res, err := s.CreateWorkflow(ctx, &workflow.CreateRequest{Template: tmp.Id, Hardware: hw})
+	assert.NoError(t, err)
+	actions, err := d.GetWorkflowActions(ctx, res.Id)
+	assert.NoError(t, err)
+	var names []string
+	for _, action := range actions.GetActionList() {
+		names = append(names, action.GetName())
+	}
+	assert.Equal(t, []string{"hello_world", "hello_again"}, names)
+	wf, err := s.GetWorkflow(ctx, &workflow.GetRequest{Id: res.Id})
+	assert.NoError(t, err)
+	if assert.Len(t, wf.GetIncludes(), 1) {
+		assert.Equal(t, "common-hello", wf.GetIncludes()[0].GetName())
+		assert.Equal(t, snippetTmp.Id, wf.GetIncludes()[0].GetTemplateId())
+		assert.Equal(t, tmp.Id, wf.GetIncludes()[0].GetIncludedBy())
+	}
+
+	_, err = s.UpdateTemplate(ctx, &pb.WorkflowTemplate{Id: snippetTmp.Id, Data: loop})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestValidateTemplate(t *testing.T) {
+	ctx := context.Background()
+	s := testServer(memory.New())
+
+	res, err := s.ValidateTemplate(ctx, &pb.WorkflowTemplate{Data: template1})
+	assert.NoError(t, err)
+	assert.Empty(t, res.GetIssues())
+
+	res, err = s.ValidateTemplate(ctx, &pb.WorkflowTemplate{Data: `version: "0.1"
+name: broken
+global_timeout: 600
+tasks:
+  - name: "broken"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "broken"
+      image: broken
+      timeout: sixty
+      reboot: maybe`})
+	assert.NoError(t, err)
+	if assert.Len(t, res.GetIssues(), 2) {
+		assert.Equal(t, &pb.TemplateIssue{Severity: "error", Line: 10, Column: 16, Path: "tasks[0].actions[0].timeout", Message: "expected an integer"}, res.GetIssues()[0])
+		assert.Equal(t, int32(11), res.GetIssues()[1].GetLine())
+	}
+
+	// the includes are resolved against the stored templates
+	res, err = s.ValidateTemplate(ctx, &pb.WorkflowTemplate{Data: `version: "0.1"
+name: including
+global_timeout: 600
+tasks:
+  - include: missing`})
+	assert.NoError(t, err)
+	if assert.Len(t, res.GetIssues(), 1) {
+		assert.Equal(t, "error", res.GetIssues()[0].GetSeverity())
+		assert.Contains(t, res.GetIssues()[0].GetMessage(), "missing")
+	}
+}
@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"strconv"
 	"time"
+	"unicode/utf8"
 
+	"github.com/golang/protobuf/ptypes"
 	"github.com/tinkerbell/tink/db"
 	pb "github.com/tinkerbell/tink/protos/workflow"
+	wflow "github.com/tinkerbell/tink/workflow"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -21,10 +24,19 @@ const (
 	errInvalidActionName     = "invalid action name"
 	errInvalidTaskReported   = "reported task name does not match the current action details"
 	errInvalidActionReported = "reported action name does not match the current action details"
+	errInvalidOutputName     = "invalid output name: %s"
+	errSecretInOutput        = "output %s cannot reference a secret"
+	errInvalidBootID         = "invalid boot id"
+
+	// maxActionMessageLength is the size of the message column of the
+	// workflow state
+	maxActionMessageLength = 200
 
 	msgReceivedStatus   = "received action status: %s"
 	msgCurrentWfContext = "current workflow context"
 	msgSendWfContext    = "send workflow context: %s"
+	msgRebootCompleted  = "action completed by worker reboot"
+	msgProgressUpdate   = "action progress updated"
 )
 
 // GetWorkflowContexts implements tinkerbell.GetWorkflowContexts
@@ -76,7 +88,27 @@ func (s *server) GetWorkflowActions(context context.Context, req *pb.WorkflowAct
 	if len(wfID) == 0 {
 		return nil, status.Errorf(codes.InvalidArgument, errInvalidWorkflowId)
 	}
-	return getWorkflowActions(context, s.db, wfID)
+	actions, err := getWorkflowActions(context, s.db, wfID)
+	if err != nil {
+		return nil, err
+	}
+	// the secrets are resolved before the outputs, so that an output never
+	// gets a secret to another action
+	if req.GetWorkerId() != "" {
+		wfContext, err := s.db.GetWorkflowContexts(context, wfID)
+		if err != nil {
+			return nil, status.Errorf(codes.Aborted, err.Error())
+		}
+		if err := s.resolveActionSecrets(context, actions, wfContext, req.GetWorkerId()); err != nil {
+			return nil, err
+		}
+	}
+	outputs, err := s.db.GetWorkflowOutputs(context, wfID)
+	if err != nil {
+		return nil, status.Errorf(codes.Aborted, err.Error())
+	}
+	resolveActionOutputs(actions, outputs)
+	return actions, nil
 }
 
 // ReportActionStatus implements tinkerbell.ReportActionStatus
@@ -91,6 +123,14 @@ func (s *server) ReportActionStatus(context context.Context, req *pb.WorkflowAct
 	if len(req.GetActionName()) == 0 {
 		return nil, status.Errorf(codes.InvalidArgument, errInvalidActionName)
 	}
+	for name, value := range req.GetOutputs() {
+		if !wflow.IsValidOutputName(name) {
+			return nil, status.Errorf(codes.InvalidArgument, errInvalidOutputName, name)
+		}
+		if len(wflow.SecretRefs(value)) > 0 {
+			return nil, status.Errorf(codes.InvalidArgument, errSecretInOutput, name)
+		}
+	}
 
 	l := logger.With("actionName", req.GetActionName(), "workflowID", req.GetWorkflowId())
 	l.Info(fmt.Sprintf(msgReceivedStatus, req.GetActionStatus()))
@@ -104,9 +144,21 @@ func (s *server) ReportActionStatus(context context.Context, req *pb.WorkflowAct
 		return nil, status.Errorf(codes.Aborted, err.Error())
 	}
 
+	// a running action reported again by its worker is a progress update
+	progressUpdate := isProgressUpdate(wfContext, req)
+	prevState := workflowState(wfContext)
 	actionIndex := wfContext.GetCurrentActionIndex()
-	if req.GetActionStatus() == pb.State_STATE_RUNNING {
-		if wfContext.GetCurrentAction() != "" {
+	if req.GetActionStatus() == pb.State_STATE_RUNNING && !progressUpdate {
+		// the actions already running finish while the workflow is paused,
+		// but the next ones do not start
+		if wfContext.GetPaused() {
+			return nil, status.Errorf(codes.FailedPrecondition, errWorkflowPaused)
+		}
+		if wfContext.GetCurrentActionState() == pb.State_STATE_WAITING {
+			return nil, status.Errorf(codes.FailedPrecondition, errWorkflowWaiting)
+		}
+		// a pending action is the one to start, once the workflow is retried
+		if wfContext.GetCurrentAction() != "" && wfContext.GetCurrentActionState() != pb.State_STATE_PENDING {
 			actionIndex = actionIndex + 1
 		}
 	}
@@ -117,23 +169,47 @@ func (s *server) ReportActionStatus(context context.Context, req *pb.WorkflowAct
 	if action.GetName() != req.GetActionName() {
 		return nil, status.Errorf(codes.InvalidArgument, errInvalidActionReported)
 	}
+	redactActionStatus(req, s.actionSecrets(context, action))
+	s.workers.seen(action.GetWorkerId(), time.Now(), s.workerGracePeriod)
+
 	wfContext.CurrentWorker = action.GetWorkerId()
 	wfContext.CurrentTask = req.GetTaskName()
 	wfContext.CurrentAction = req.GetActionName()
 	wfContext.CurrentActionState = req.GetActionStatus()
 	wfContext.CurrentActionIndex = actionIndex
-	err = s.db.UpdateWorkflowState(context, wfContext)
+	wfContext.CurrentActionProgress = actionProgress(req)
+	wfContext.CurrentActionMessage = truncate(req.GetMessage(), maxActionMessageLength)
+	// TODO the below "now" would be a part of the request which is coming form worker.
+	now := time.Now()
+	// the outputs are stored along with the state, so that the next action
+	// never starts without them
+	if req.GetActionStatus() == pb.State_STATE_SUCCESS && len(req.GetOutputs()) > 0 {
+		err = s.db.UpdateWorkflowStateWithOutputs(context, wfContext, req, now)
+	} else {
+		err = s.db.UpdateWorkflowState(context, wfContext)
+	}
 	if err != nil {
 		return &pb.Empty{}, status.Errorf(codes.Aborted, err.Error())
 	}
+	// progress updates are only recorded in the workflow state, so that
+	// they do not flood the events of the workflow
+	if progressUpdate {
+		l.With("progress", wfContext.GetCurrentActionProgress()).Debug(msgProgressUpdate)
+		return &pb.Empty{}, nil
+	}
 
-	// TODO the below "time" would be a part of the request which is coming form worker.
-	time := time.Now()
-	err = s.db.InsertIntoWorkflowEventTable(context, req, time)
+	err = s.db.InsertIntoWorkflowEventTable(context, req, now)
 	if err != nil {
 		return &pb.Empty{}, status.Error(codes.Aborted, err.Error())
 	}
 
+	observeWorkflow(context, s.db, wfID, workflowTransition{from: prevState, to: workflowState(wfContext), action: req})
+	if req.GetActionStatus() == pb.State_STATE_SUCCESS {
+		if err := waitForApproval(context, s.db, wfID); err != nil {
+			return &pb.Empty{}, status.Error(codes.Aborted, err.Error())
+		}
+	}
+
 	l = logger.With(
 		"workflowID", wfContext.GetWorkflowId(),
 		"currentWorker", wfContext.GetCurrentWorker(),
@@ -147,6 +223,39 @@ func (s *server) ReportActionStatus(context context.Context, req *pb.WorkflowAct
 	return &pb.Empty{}, nil
 }
 
+// isProgressUpdate checks if the status reports the progress of the action
+// currently running, rather than the start of the next one
+func isProgressUpdate(wfContext *pb.WorkflowContext, req *pb.WorkflowActionStatus) bool {
+	return req.GetActionStatus() == pb.State_STATE_RUNNING &&
+		wfContext.GetCurrentActionState() == pb.State_STATE_RUNNING &&
+		wfContext.GetCurrentTask() == req.GetTaskName() &&
+		wfContext.GetCurrentAction() == req.GetActionName()
+}
+
+// actionProgress returns the percentage of completion of the reported action
+func actionProgress(req *pb.WorkflowActionStatus) int32 {
+	switch {
+	case req.GetActionStatus() == pb.State_STATE_SUCCESS:
+		return 100
+	case req.GetProgress() < 0:
+		return 0
+	case req.GetProgress() > 100:
+		return 100
+	}
+	return req.GetProgress()
+}
+
+// truncate shortens s to at most n bytes, without splitting a character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
 // UpdateWorkflowData updates workflow ephemeral data
 func (s *server) UpdateWorkflowData(context context.Context, req *pb.UpdateWorkflowDataRequest) (*pb.Empty, error) {
 	wfID := req.GetWorkflowId()
@@ -195,6 +304,110 @@ func (s *server) GetWorkflowDataVersion(context context.Context, req *pb.GetWork
 	return &pb.GetWorkflowDataResponse{Version: version}, nil
 }
 
+// RegisterWorker implements tinkerbell.RegisterWorker
+func (s *server) RegisterWorker(context context.Context, req *pb.RegisterWorkerRequest) (*pb.Empty, error) {
+	if len(req.GetWorkerId()) == 0 {
+		return &pb.Empty{}, status.Errorf(codes.InvalidArgument, errInvalidWorkerID)
+	}
+	if len(req.GetBootId()) == 0 {
+		return &pb.Empty{}, status.Errorf(codes.InvalidArgument, errInvalidBootID)
+	}
+
+	l := logger.With("workerID", req.GetWorkerId(), "bootID", req.GetBootId())
+	prevBootID, err := s.db.RegisterWorker(context, req.GetWorkerId(), req.GetBootId(), time.Now())
+	if err != nil {
+		return &pb.Empty{}, status.Errorf(codes.Aborted, err.Error())
+	}
+	s.workers.seen(req.GetWorkerId(), time.Now(), s.workerGracePeriod)
+	l.With("previousBootID", prevBootID).Info("worker registered")
+	if prevBootID == "" || prevBootID == req.GetBootId() {
+		return &pb.Empty{}, nil
+	}
+
+	// the worker came back after a reboot, which confirms the success of
+	// the actions that were waiting for it
+	wfs, err := getWorkflowsForWorker(s.db, req.GetWorkerId())
+	if err != nil {
+		return &pb.Empty{}, err
+	}
+	for _, wf := range wfs {
+		if err := completeRebootAction(context, s.db, wf, req.GetWorkerId()); err != nil {
+			return &pb.Empty{}, status.Errorf(codes.Aborted, err.Error())
+		}
+	}
+	return &pb.Empty{}, nil
+}
+
+// Heartbeat implements tinkerbell.Heartbeat
+func (s *server) Heartbeat(context context.Context, req *pb.HeartbeatRequest) (*pb.Empty, error) {
+	if len(req.GetWorkerId()) == 0 {
+		return &pb.Empty{}, status.Errorf(codes.InvalidArgument, errInvalidWorkerID)
+	}
+	err := s.db.UpdateWorkerHeartbeat(context, req, time.Now())
+	if err != nil {
+		return &pb.Empty{}, status.Errorf(codes.Aborted, err.Error())
+	}
+	s.workers.seen(req.GetWorkerId(), time.Now(), s.workerGracePeriod)
+	return &pb.Empty{}, nil
+}
+
+// ListWorkers implements tinkerbell.ListWorkers
+func (s *server) ListWorkers(_ *pb.Empty, stream pb.WorkflowService_ListWorkersServer) error {
+	lastSeen := time.Now().Add(-s.workerGracePeriod)
+	return s.db.ListWorkers(func(w *pb.WorkerStatus) error {
+		seen, err := ptypes.Timestamp(w.GetLastSeen())
+		w.Alive = err == nil && seen.After(lastSeen)
+		return stream.Send(w)
+	})
+}
+
+// completeRebootAction marks the current action of a workflow as successful
+// if it is a reboot action executed by the given worker
+func completeRebootAction(context context.Context, db db.Database, wfID, workerID string) error {
+	wfContext, err := db.GetWorkflowContexts(context, wfID)
+	if err != nil {
+		return err
+	}
+	if wfContext.GetCurrentActionState() != pb.State_STATE_RUNNING || wfContext.GetCurrentWorker() != workerID {
+		return nil
+	}
+	actions, err := db.GetWorkflowActions(context, wfID)
+	if err != nil {
+		return err
+	}
+	if int(wfContext.GetCurrentActionIndex()) >= len(actions.GetActionList()) {
+		return nil
+	}
+	action := actions.GetActionList()[wfContext.GetCurrentActionIndex()]
+	if !action.GetReboot() {
+		return nil
+	}
+
+	prevState := workflowState(wfContext)
+	wfContext.CurrentActionState = pb.State_STATE_SUCCESS
+	wfContext.CurrentActionProgress = 100
+	wfContext.CurrentActionMessage = msgRebootCompleted
+	err = db.UpdateWorkflowState(context, wfContext)
+	if err != nil {
+		return err
+	}
+	event := &pb.WorkflowActionStatus{
+		WorkflowId:   wfID,
+		WorkerId:     workerID,
+		TaskName:     action.GetTaskName(),
+		ActionName:   action.GetName(),
+		ActionStatus: pb.State_STATE_SUCCESS,
+		Message:      msgRebootCompleted,
+	}
+	err = db.InsertIntoWorkflowEventTable(context, event, time.Now())
+	if err != nil {
+		return err
+	}
+	observeWorkflow(context, db, wfID, workflowTransition{from: prevState, to: workflowState(wfContext), action: event})
+	logger.With("workflowID", wfID, "workerID", workerID, "actionName", action.GetName()).Info(msgRebootCompleted)
+	return waitForApproval(context, db, wfID)
+}
+
 func getWorkflowsForWorker(db db.Database, id string) ([]string, error) {
 	if len(id) == 0 {
 		return nil, status.Errorf(codes.InvalidArgument, errInvalidWorkerID)
@@ -214,11 +427,26 @@ func getWorkflowActions(context context.Context, db db.Database, wfID string) (*
 	return actions, nil
 }
 
+// resolveActionOutputs substitutes the references to the outputs of previous
+// actions in the environment of every action
+func resolveActionOutputs(actions *pb.WorkflowActionList, outputs map[string]map[string]string) {
+	if len(outputs) == 0 {
+		return
+	}
+	for _, action := range actions.GetActionList() {
+		for i, env := range action.Environment {
+			action.Environment[i] = wflow.ResolveOutputRefs(env, outputs)
+		}
+	}
+}
+
 // isApplicableToSend checks if a particular workflow context is applicable or if it is needed to
 // be sent to a worker based on the state of the current action and the targeted workerID
 func isApplicableToSend(context context.Context, wfContext *pb.WorkflowContext, workerID string, db db.Database) bool {
 	if wfContext.GetCurrentActionState() == pb.State_STATE_FAILED ||
-		wfContext.GetCurrentActionState() == pb.State_STATE_TIMEOUT {
+		wfContext.GetCurrentActionState() == pb.State_STATE_TIMEOUT ||
+		wfContext.GetCurrentActionState() == pb.State_STATE_WAITING ||
+		wfContext.GetPaused() {
 		return false
 	}
 	actions, err := getWorkflowActions(context, db, wfContext.GetWorkflowId())
@@ -229,11 +457,11 @@ func isApplicableToSend(context context.Context, wfContext *pb.WorkflowContext,
 		if isLastAction(wfContext, actions) {
 			return false
 		}
-		if wfContext.GetCurrentActionIndex() == 0 {
-			if actions.ActionList[wfContext.GetCurrentActionIndex()+1].GetWorkerId() == workerID {
-				logger.Info(fmt.Sprintf(msgSendWfContext, wfContext.GetWorkflowId()))
-				return true
-			}
+		// the next action can follow an approval, a reboot or an action
+		// of another worker
+		if actions.ActionList[wfContext.GetCurrentActionIndex()+1].GetWorkerId() == workerID {
+			logger.Info(fmt.Sprintf(msgSendWfContext, wfContext.GetWorkflowId()))
+			return true
 		}
 	} else if actions.ActionList[wfContext.GetCurrentActionIndex()].GetWorkerId() == workerID {
 		logger.Info(fmt.Sprintf(msgSendWfContext, wfContext.GetWorkflowId()))
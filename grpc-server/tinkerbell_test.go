@@ -146,6 +146,7 @@ func TestGetWorkflowActions(t *testing.T) {
 		}
 		want struct {
 			expectedError bool
+			environment   []string
 		}
 	)
 	testCases := map[string]struct {
@@ -189,11 +190,70 @@ func TestGetWorkflowActions(t *testing.T) {
 							},
 						}, nil
 					},
+					GetWorkflowOutputsFunc: func(ctx context.Context, wfID string) (map[string]map[string]string, error) {
+						return nil, nil
+					},
+				},
+				workflowID: workflowID,
+			},
+			want: want{
+				expectedError: false,
+			},
+		},
+		"failed getting outputs": {
+			args: args{
+				db: mock.DB{
+					GetWorkflowActionsFunc: func(ctx context.Context, wfID string) (*pb.WorkflowActionList, error) {
+						return &pb.WorkflowActionList{
+							ActionList: []*pb.WorkflowAction{
+								{
+									WorkerId: workerID,
+									Image:    actionName,
+									Name:     actionName,
+									Timeout:  int64(90),
+									TaskName: taskName,
+								},
+							},
+						}, nil
+					},
+					GetWorkflowOutputsFunc: func(ctx context.Context, wfID string) (map[string]map[string]string, error) {
+						return nil, errors.New("SELECT from workflow_output")
+					},
+				},
+				workflowID: workflowID,
+			},
+			want: want{
+				expectedError: true,
+			},
+		},
+		"resolving outputs of previous actions": {
+			args: args{
+				db: mock.DB{
+					GetWorkflowActionsFunc: func(ctx context.Context, wfID string) (*pb.WorkflowActionList, error) {
+						return &pb.WorkflowActionList{
+							ActionList: []*pb.WorkflowAction{
+								{
+									WorkerId:    workerID,
+									Image:       actionName,
+									Name:        actionName,
+									Timeout:     int64(90),
+									TaskName:    taskName,
+									Environment: []string{"ROOT_UUID={{ outputs.disk-partition.root_uuid }}"},
+								},
+							},
+						}, nil
+					},
+					GetWorkflowOutputsFunc: func(ctx context.Context, wfID string) (map[string]map[string]string, error) {
+						return map[string]map[string]string{
+							"disk-partition": {"root_uuid": "0f5d8a5c-55f5-4ab0-8d48-6b2b1e1a5ef6"},
+						}, nil
+					},
 				},
 				workflowID: workflowID,
 			},
 			want: want{
 				expectedError: false,
+				environment:   []string{"ROOT_UUID=0f5d8a5c-55f5-4ab0-8d48-6b2b1e1a5ef6"},
 			},
 		},
 	}
@@ -212,6 +272,9 @@ func TestGetWorkflowActions(t *testing.T) {
 			assert.NoError(t, err)
 			assert.NotNil(t, res)
 			assert.Len(t, res.ActionList, 1)
+			if tc.want.environment != nil {
+				assert.Equal(t, tc.want.environment, res.ActionList[0].Environment)
+			}
 		})
 	}
 }
@@ -222,6 +285,7 @@ func TestReportActionStatus(t *testing.T) {
 			db                                         mock.DB
 			workflowID, taskName, actionName, workerID string
 			actionState                                pb.State
+			outputs                                    map[string]string
 		}
 		want struct {
 			expectedError bool
@@ -536,6 +600,231 @@ func TestReportActionStatus(t *testing.T) {
 				expectedError: true,
 			},
 		},
+		"success reporting outputs": {
+			args: args{
+				db: mock.DB{
+					GetWorkflowContextsFunc: func(ctx context.Context, wfID string) (*pb.WorkflowContext, error) {
+						return &pb.WorkflowContext{
+							WorkflowId:           workflowID,
+							TotalNumberOfActions: 1,
+							CurrentAction:        actionName,
+							CurrentActionState:   pb.State_STATE_RUNNING,
+						}, nil
+					},
+					GetWorkflowActionsFunc: func(ctx context.Context, wfID string) (*pb.WorkflowActionList, error) {
+						return &pb.WorkflowActionList{
+							ActionList: []*pb.WorkflowAction{
+								{
+									WorkerId: workerID,
+									Image:    actionName,
+									Name:     actionName,
+									Timeout:  int64(90),
+									TaskName: taskName,
+								},
+							},
+						}, nil
+					},
+					UpdateWorkflowStateFunc: func(ctx context.Context, wfContext *pb.WorkflowContext) error {
+						return nil
+					},
+					InsertIntoWorkflowEventTableFunc: func(ctx context.Context, wfEvent *pb.WorkflowActionStatus, time time.Time) error {
+						return nil
+					},
+					UpdateWorkflowStateWithOutputsFunc: func(ctx context.Context, wfContext *pb.WorkflowContext, wfOutput *pb.WorkflowActionStatus, time time.Time) error {
+						return nil
+					},
+				},
+				workflowID:  workflowID,
+				workerID:    workerID,
+				taskName:    taskName,
+				actionName:  actionName,
+				actionState: pb.State_STATE_SUCCESS,
+				outputs:     map[string]string{"root_uuid": "0f5d8a5c-55f5-4ab0-8d48-6b2b1e1a5ef6"},
+			},
+			want: want{
+				expectedError: false,
+			},
+		},
+		"invalid output name": {
+			args: args{
+				db: mock.DB{
+					GetWorkflowContextsFunc: func(ctx context.Context, wfID string) (*pb.WorkflowContext, error) {
+						return &pb.WorkflowContext{
+							WorkflowId:           workflowID,
+							TotalNumberOfActions: 1,
+							CurrentAction:        actionName,
+							CurrentActionState:   pb.State_STATE_RUNNING,
+						}, nil
+					},
+					GetWorkflowActionsFunc: func(ctx context.Context, wfID string) (*pb.WorkflowActionList, error) {
+						return &pb.WorkflowActionList{
+							ActionList: []*pb.WorkflowAction{
+								{
+									WorkerId: workerID,
+									Image:    actionName,
+									Name:     actionName,
+									Timeout:  int64(90),
+									TaskName: taskName,
+								},
+							},
+						}, nil
+					},
+					UpdateWorkflowStateFunc: func(ctx context.Context, wfContext *pb.WorkflowContext) error {
+						return nil
+					},
+					InsertIntoWorkflowEventTableFunc: func(ctx context.Context, wfEvent *pb.WorkflowActionStatus, time time.Time) error {
+						return nil
+					},
+					UpdateWorkflowStateWithOutputsFunc: func(ctx context.Context, wfContext *pb.WorkflowContext, wfOutput *pb.WorkflowActionStatus, time time.Time) error {
+						return nil
+					},
+				},
+				workflowID:  workflowID,
+				workerID:    workerID,
+				taskName:    taskName,
+				actionName:  actionName,
+				actionState: pb.State_STATE_SUCCESS,
+				outputs:     map[string]string{"../root_uuid": "0f5d8a5c-55f5-4ab0-8d48-6b2b1e1a5ef6"},
+			},
+			want: want{
+				expectedError: true,
+			},
+		},
+		"output referencing a secret": {
+			args: args{
+				db: mock.DB{
+					GetWorkflowContextsFunc: func(ctx context.Context, wfID string) (*pb.WorkflowContext, error) {
+						return &pb.WorkflowContext{
+							WorkflowId:           workflowID,
+							TotalNumberOfActions: 1,
+							CurrentAction:        actionName,
+							CurrentActionState:   pb.State_STATE_RUNNING,
+						}, nil
+					},
+					GetWorkflowActionsFunc: func(ctx context.Context, wfID string) (*pb.WorkflowActionList, error) {
+						return &pb.WorkflowActionList{
+							ActionList: []*pb.WorkflowAction{
+								{
+									WorkerId: workerID,
+									Image:    actionName,
+									Name:     actionName,
+									Timeout:  int64(90),
+									TaskName: taskName,
+								},
+							},
+						}, nil
+					},
+					UpdateWorkflowStateFunc: func(ctx context.Context, wfContext *pb.WorkflowContext) error {
+						return nil
+					},
+					InsertIntoWorkflowEventTableFunc: func(ctx context.Context, wfEvent *pb.WorkflowActionStatus, time time.Time) error {
+						return nil
+					},
+					UpdateWorkflowStateWithOutputsFunc: func(ctx context.Context, wfContext *pb.WorkflowContext, wfOutput *pb.WorkflowActionStatus, time time.Time) error {
+						return nil
+					},
+				},
+				workflowID:  workflowID,
+				workerID:    workerID,
+				taskName:    taskName,
+				actionName:  actionName,
+				actionState: pb.State_STATE_SUCCESS,
+				outputs:     map[string]string{"password": `{{ secret "ipmi-password" }}`},
+			},
+			want: want{
+				expectedError: true,
+			},
+		},
+		"failed to store outputs": {
+			args: args{
+				db: mock.DB{
+					GetWorkflowContextsFunc: func(ctx context.Context, wfID string) (*pb.WorkflowContext, error) {
+						return &pb.WorkflowContext{
+							WorkflowId:           workflowID,
+							TotalNumberOfActions: 1,
+							CurrentAction:        actionName,
+							CurrentActionState:   pb.State_STATE_RUNNING,
+						}, nil
+					},
+					GetWorkflowActionsFunc: func(ctx context.Context, wfID string) (*pb.WorkflowActionList, error) {
+						return &pb.WorkflowActionList{
+							ActionList: []*pb.WorkflowAction{
+								{
+									WorkerId: workerID,
+									Image:    actionName,
+									Name:     actionName,
+									Timeout:  int64(90),
+									TaskName: taskName,
+								},
+							},
+						}, nil
+					},
+					UpdateWorkflowStateFunc: func(ctx context.Context, wfContext *pb.WorkflowContext) error {
+						return nil
+					},
+					InsertIntoWorkflowEventTableFunc: func(ctx context.Context, wfEvent *pb.WorkflowActionStatus, time time.Time) error {
+						return nil
+					},
+					UpdateWorkflowStateWithOutputsFunc: func(ctx context.Context, wfContext *pb.WorkflowContext, wfOutput *pb.WorkflowActionStatus, time time.Time) error {
+						return errors.New("INSERT in to workflow_output")
+					},
+				},
+				workflowID:  workflowID,
+				workerID:    workerID,
+				taskName:    taskName,
+				actionName:  actionName,
+				actionState: pb.State_STATE_SUCCESS,
+				outputs:     map[string]string{"root_uuid": "0f5d8a5c-55f5-4ab0-8d48-6b2b1e1a5ef6"},
+			},
+			want: want{
+				expectedError: true,
+			},
+		},
+		"progress update of the running action": {
+			args: args{
+				db: mock.DB{
+					GetWorkflowContextsFunc: func(ctx context.Context, wfID string) (*pb.WorkflowContext, error) {
+						return &pb.WorkflowContext{
+							WorkflowId:           workflowID,
+							TotalNumberOfActions: 1,
+							CurrentTask:          taskName,
+							CurrentAction:        actionName,
+							CurrentActionState:   pb.State_STATE_RUNNING,
+						}, nil
+					},
+					GetWorkflowActionsFunc: func(ctx context.Context, wfID string) (*pb.WorkflowActionList, error) {
+						return &pb.WorkflowActionList{
+							ActionList: []*pb.WorkflowAction{
+								{
+									WorkerId: workerID,
+									Image:    actionName,
+									Name:     actionName,
+									Timeout:  int64(90),
+									TaskName: taskName,
+								},
+							},
+						}, nil
+					},
+					UpdateWorkflowStateFunc: func(ctx context.Context, wfContext *pb.WorkflowContext) error {
+						if wfContext.GetCurrentActionIndex() != 0 || wfContext.GetCurrentActionState() != pb.State_STATE_RUNNING {
+							return errors.New("progress update moved to the next action")
+						}
+						return nil
+					},
+					InsertIntoWorkflowEventTableFunc: func(ctx context.Context, wfEvent *pb.WorkflowActionStatus, time time.Time) error {
+						return errors.New("progress update recorded as an event")
+					},
+				},
+				workflowID:  workflowID,
+				workerID:    workerID,
+				taskName:    taskName,
+				actionName:  actionName,
+				actionState: pb.State_STATE_RUNNING,
+			},
+			want: want{
+				expectedError: false,
+			},
+		},
 	}
 	for name, tc := range testCases {
 		t.Run(name, func(t *testing.T) {
@@ -548,6 +837,7 @@ func TestReportActionStatus(t *testing.T) {
 					WorkerId:     tc.args.workerID,
 					ActionStatus: tc.args.actionState,
 					Seconds:      0,
+					Outputs:      tc.args.outputs,
 				},
 			)
 			if err != nil {
@@ -1253,3 +1543,261 @@ func TestIsLastAction(t *testing.T) {
 		})
 	}
 }
+
+func TestRegisterWorker(t *testing.T) {
+	const (
+		bootID     = "6d3cf4f3-8c02-4d2a-a5b3-6a4b0a0b8b1d"
+		prevBootID = "c0b4e2a5-1f5e-4a57-9f8e-5c0de1e3b9a2"
+	)
+	rebootActions := func(ctx context.Context, wfID string) (*pb.WorkflowActionList, error) {
+		return &pb.WorkflowActionList{
+			ActionList: []*pb.WorkflowAction{
+				{
+					WorkerId: workerID,
+					Image:    actionName,
+					Name:     actionName,
+					Timeout:  int64(90),
+					TaskName: taskName,
+					Reboot:   true,
+				},
+			},
+		}, nil
+	}
+	runningContext := func(ctx context.Context, wfID string) (*pb.WorkflowContext, error) {
+		return &pb.WorkflowContext{
+			WorkflowId:           workflowID,
+			CurrentWorker:        workerID,
+			CurrentTask:          taskName,
+			CurrentAction:        actionName,
+			CurrentActionState:   pb.State_STATE_RUNNING,
+			TotalNumberOfActions: 1,
+		}, nil
+	}
+	type (
+		args struct {
+			db               mock.DB
+			workerID, bootID string
+		}
+		want struct {
+			expectedError bool
+			state         pb.State
+		}
+	)
+	testCases := map[string]struct {
+		args args
+		want want
+	}{
+		"empty worker id": {
+			args: args{
+				db:     mock.DB{},
+				bootID: bootID,
+			},
+			want: want{
+				expectedError: true,
+			},
+		},
+		"empty boot id": {
+			args: args{
+				db:       mock.DB{},
+				workerID: workerID,
+			},
+			want: want{
+				expectedError: true,
+			},
+		},
+		"database failure": {
+			args: args{
+				db: mock.DB{
+					RegisterWorkerFunc: func(ctx context.Context, id, bootID string, time time.Time) (string, error) {
+						return "", errors.New("INSERT in to worker")
+					},
+				},
+				workerID: workerID,
+				bootID:   bootID,
+			},
+			want: want{
+				expectedError: true,
+			},
+		},
+		"first registration": {
+			args: args{
+				db: mock.DB{
+					RegisterWorkerFunc: func(ctx context.Context, id, bootID string, time time.Time) (string, error) {
+						return "", nil
+					},
+				},
+				workerID: workerID,
+				bootID:   bootID,
+			},
+		},
+		"restart without reboot": {
+			args: args{
+				db: mock.DB{
+					RegisterWorkerFunc: func(ctx context.Context, id, bootID string, time time.Time) (string, error) {
+						return bootID, nil
+					},
+				},
+				workerID: workerID,
+				bootID:   bootID,
+			},
+		},
+		"reboot completes reboot action": {
+			args: args{
+				db: mock.DB{
+					RegisterWorkerFunc: func(ctx context.Context, id, bootID string, time time.Time) (string, error) {
+						return prevBootID, nil
+					},
+					GetWorkflowsForWorkerFunc: func(id string) ([]string, error) {
+						return []string{workflowID}, nil
+					},
+					GetWorkflowContextsFunc: runningContext,
+					GetWorkflowActionsFunc:  rebootActions,
+					InsertIntoWorkflowEventTableFunc: func(ctx context.Context, wfEvent *pb.WorkflowActionStatus, time time.Time) error {
+						return nil
+					},
+				},
+				workerID: workerID,
+				bootID:   bootID,
+			},
+			want: want{
+				state: pb.State_STATE_SUCCESS,
+			},
+		},
+		"reboot does not complete other actions": {
+			args: args{
+				db: mock.DB{
+					RegisterWorkerFunc: func(ctx context.Context, id, bootID string, time time.Time) (string, error) {
+						return prevBootID, nil
+					},
+					GetWorkflowsForWorkerFunc: func(id string) ([]string, error) {
+						return []string{workflowID}, nil
+					},
+					GetWorkflowContextsFunc: runningContext,
+					GetWorkflowActionsFunc: func(ctx context.Context, wfID string) (*pb.WorkflowActionList, error) {
+						actions, _ := rebootActions(ctx, wfID)
+						actions.ActionList[0].Reboot = false
+						return actions, nil
+					},
+				},
+				workerID: workerID,
+				bootID:   bootID,
+			},
+		},
+		"failed to complete reboot action": {
+			args: args{
+				db: mock.DB{
+					RegisterWorkerFunc: func(ctx context.Context, id, bootID string, time time.Time) (string, error) {
+						return prevBootID, nil
+					},
+					GetWorkflowsForWorkerFunc: func(id string) ([]string, error) {
+						return []string{workflowID}, nil
+					},
+					GetWorkflowContextsFunc: runningContext,
+					GetWorkflowActionsFunc:  rebootActions,
+					UpdateWorkflowStateFunc: func(ctx context.Context, wfContext *pb.WorkflowContext) error {
+						return errors.New("INSERT in to workflow_state")
+					},
+				},
+				workerID: workerID,
+				bootID:   bootID,
+			},
+			want: want{
+				expectedError: true,
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var state pb.State
+			if tc.args.db.UpdateWorkflowStateFunc == nil {
+				tc.args.db.UpdateWorkflowStateFunc = func(ctx context.Context, wfContext *pb.WorkflowContext) error {
+					state = wfContext.GetCurrentActionState()
+					return nil
+				}
+			}
+			s := testServer(tc.args.db)
+			_, err := s.RegisterWorker(context.TODO(), &pb.RegisterWorkerRequest{
+				WorkerId: tc.args.workerID,
+				BootId:   tc.args.bootID,
+			})
+			if tc.want.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.state, state)
+		})
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	type (
+		args struct {
+			db       mock.DB
+			workerID string
+		}
+		want struct {
+			expectedError bool
+		}
+	)
+	testCases := map[string]struct {
+		args args
+		want want
+	}{
+		"empty worker id": {
+			args: args{
+				db: mock.DB{},
+			},
+			want: want{
+				expectedError: true,
+			},
+		},
+		"database failure": {
+			args: args{
+				db: mock.DB{
+					UpdateWorkerHeartbeatFunc: func(ctx context.Context, hb *pb.HeartbeatRequest, time time.Time) error {
+						return errors.New("INSERT in to worker")
+					},
+				},
+				workerID: workerID,
+			},
+			want: want{
+				expectedError: true,
+			},
+		},
+		"heartbeat recorded": {
+			args: args{
+				db: mock.DB{
+					UpdateWorkerHeartbeatFunc: func(ctx context.Context, hb *pb.HeartbeatRequest, time time.Time) error {
+						return nil
+					},
+				},
+				workerID: workerID,
+			},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			s := testServer(tc.args.db)
+			_, err := s.Heartbeat(context.TODO(), &pb.HeartbeatRequest{
+				WorkerId:   tc.args.workerID,
+				WorkflowId: workflowID,
+				ActionName: actionName,
+			})
+			if tc.want.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestActionProgress(t *testing.T) {
+	assert.Equal(t, int32(42), actionProgress(&pb.WorkflowActionStatus{ActionStatus: pb.State_STATE_RUNNING, Progress: 42}))
+	assert.Equal(t, int32(100), actionProgress(&pb.WorkflowActionStatus{ActionStatus: pb.State_STATE_RUNNING, Progress: 250}))
+	assert.Equal(t, int32(0), actionProgress(&pb.WorkflowActionStatus{ActionStatus: pb.State_STATE_FAILED, Progress: -1}))
+	assert.Equal(t, int32(100), actionProgress(&pb.WorkflowActionStatus{ActionStatus: pb.State_STATE_SUCCESS}))
+	assert.Equal(t, "abc", truncate("abcdef", 3))
+	assert.Equal(t, "ab", truncate("abé", 3))
+}
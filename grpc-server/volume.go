@@ -0,0 +1,33 @@
+package grpcserver
+
+import (
+	"os"
+
+	wflow "github.com/tinkerbell/tink/workflow"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const msgVolumePolicyDisabled = "volume policy disabled, templates can mount any volume"
+
+// getVolumePolicy returns the policy restricting the volumes of the
+// templates, from TINKERBELL_VOLUME_POLICY, nil if it is not set
+func getVolumePolicy() (*wflow.VolumePolicy, error) {
+	policy := os.Getenv("TINKERBELL_VOLUME_POLICY")
+	if policy == "" {
+		return nil, nil
+	}
+	return wflow.ParseVolumePolicy(policy)
+}
+
+// checkTemplate parses a template and checks its volumes against the policy
+func (s *server) checkTemplate(data string) error {
+	wf, err := wflow.Parse([]byte(data))
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	if err := s.volumePolicy.Check(wf); err != nil {
+		return status.Errorf(codes.PermissionDenied, err.Error())
+	}
+	return nil
+}
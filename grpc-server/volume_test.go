@@ -0,0 +1,87 @@
+package grpcserver
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tinkerbell/tink/db/mock"
+	pb "github.com/tinkerbell/tink/protos/template"
+	wflow "github.com/tinkerbell/tink/workflow"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const templateWithVolumes = `version: "0.1"
+name: hello_world_workflow
+global_timeout: 600
+tasks:
+  - name: "hello world"
+    worker: "{{.device_1}}"
+    volumes:
+      - /dev:/dev
+    actions:
+    - name: "hello_world"
+      image: hello-world
+      timeout: 60
+      volumes:
+        - /etc:/host/etc`
+
+func TestGetVolumePolicy(t *testing.T) {
+	defer os.Unsetenv("TINKERBELL_VOLUME_POLICY")
+
+	p, err := getVolumePolicy()
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+
+	os.Setenv("TINKERBELL_VOLUME_POLICY", "/dev,/lib/firmware:ro")
+	p, err = getVolumePolicy()
+	assert.NoError(t, err)
+	assert.NotNil(t, p)
+
+	os.Setenv("TINKERBELL_VOLUME_POLICY", "/dev:rx")
+	_, err = getVolumePolicy()
+	assert.Error(t, err)
+}
+
+func TestCreateTemplateVolumePolicy(t *testing.T) {
+	testCases := map[string]struct {
+		policy string
+		data   string
+		code   codes.Code
+	}{
+		"no policy": {
+			data: templateWithVolumes,
+			code: codes.OK,
+		},
+		"allowed volumes": {
+			policy: "/dev,/etc:rw",
+			data:   templateWithVolumes,
+			code:   codes.OK,
+		},
+		"volume not allowed": {
+			policy: "/dev",
+			data:   templateWithVolumes,
+			code:   codes.PermissionDenied,
+		},
+		"malformed volume": {
+			data: template1 + `
+      volumes:
+        - /etc`,
+			code: codes.InvalidArgument,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			s := testServer(mock.DB{TemplateDB: map[string]interface{}{}})
+			if tc.policy != "" {
+				p, err := wflow.ParseVolumePolicy(tc.policy)
+				assert.NoError(t, err)
+				s.volumePolicy = p
+			}
+			_, err := s.CreateTemplate(context.TODO(), &pb.WorkflowTemplate{Name: "template_1", Data: tc.data})
+			assert.Equal(t, tc.code, status.Code(err))
+		})
+	}
+}
@@ -1,11 +1,9 @@
 package grpcserver
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"strconv"
-	"text/template"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
@@ -14,6 +12,10 @@ import (
 	"github.com/tinkerbell/tink/metrics"
 	"github.com/tinkerbell/tink/protos/workflow"
 	workflowpb "github.com/tinkerbell/tink/protos/workflow"
+	"github.com/tinkerbell/tink/tracing"
+	wflow "github.com/tinkerbell/tink/workflow"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var state = map[int32]workflow.State{
@@ -22,11 +24,18 @@ var state = map[int32]workflow.State{
 	2: workflow.State_STATE_FAILED,
 	3: workflow.State_STATE_TIMEOUT,
 	4: workflow.State_STATE_SUCCESS,
+	5: workflow.State_STATE_WAITING,
 }
 
 const (
 	errFailedToGetTemplate = "failed to get template with ID: %s"
-	errTemplateParsing     = "failed to parse template with ID: %s"
+	errWorkflowNotFound    = "workflow not found: %s"
+	errRetryNotFailed      = "only failed or timed out workflows can be retried, the workflow is %s"
+	errRetryInvalidAction  = "no action %s before the failed one"
+	errRetryAmbiguousName  = "several actions are named %s, name it as task/action"
+	errRetryInvalidIndex   = "action index %d is out of range, the workflow has %d actions"
+
+	msgWorkflowRetried = "workflow retried"
 )
 
 // CreateWorkflow implements workflow.CreateWorkflow
@@ -47,20 +56,33 @@ func (s *server) CreateWorkflow(ctx context.Context, in *workflow.CreateRequest)
 	timer := prometheus.NewTimer(metrics.CacheDuration.With(labels))
 	defer timer.ObserveDuration()
 
+	// the creation starts the trace of the workflow
+	ctx, span := tracing.StartWorkflowSpan(ctx, id.String(), "CreateWorkflow")
+	defer span.End()
+
 	logger.Info(msg)
 
-	data, err := createYaml(ctx, s.db, in.Template, in.Hardware)
+	data, includes, err := createYaml(ctx, s.db, in.Template, in.Hardware, in.Parameters)
 	if err != nil {
 		metrics.CacheErrors.With(labels).Inc()
 		logger.Error(err)
 		return &workflow.CreateResponse{}, err
 	}
+	// the rendered template is checked as well, the hardware data can add
+	// volumes and the template may predate the volume policy
+	if err := s.checkTemplate(data); err != nil {
+		metrics.CacheErrors.With(labels).Inc()
+		logger.Error(err)
+		return &workflow.CreateResponse{}, err
+	}
 
 	wf := db.Workflow{
-		ID:       id.String(),
-		Template: in.Template,
-		Hardware: in.Hardware,
-		State:    workflow.State_value[workflow.State_STATE_PENDING.String()],
+		ID:         id.String(),
+		Template:   in.Template,
+		Hardware:   in.Hardware,
+		Parameters: in.Parameters,
+		Includes:   includes,
+		State:      workflow.State_value[workflow.State_STATE_PENDING.String()],
 	}
 	err = s.db.CreateWorkflow(ctx, wf, data, id)
 	if err != nil {
@@ -73,6 +95,16 @@ func (s *server) CreateWorkflow(ctx context.Context, in *workflow.CreateRequest)
 		return &workflow.CreateResponse{}, err
 	}
 
+	metrics.WorkflowsCurrent.WithLabelValues(stateLabel(workflow.State_STATE_PENDING)).Inc()
+
+	if startsWithApproval(data) {
+		if err := waitForApproval(ctx, s.db, id.String()); err != nil {
+			metrics.CacheErrors.With(labels).Inc()
+			logger.Error(err)
+			return &workflow.CreateResponse{}, err
+		}
+	}
+
 	l := logger.With("workflowID", id.String())
 	l.Info("done " + msg)
 	return &workflow.CreateResponse{Id: id.String()}, err
@@ -102,16 +134,18 @@ func (s *server) GetWorkflow(ctx context.Context, in *workflow.GetRequest) (*wor
 		}
 		l.Error(err)
 	}
-	yamlData, err := createYaml(ctx, s.db, w.Template, w.Hardware)
+	yamlData, _, err := createYaml(ctx, s.db, w.Template, w.Hardware, w.Parameters)
 	if err != nil {
 		return &workflow.Workflow{}, err
 	}
 	wf := &workflow.Workflow{
-		Id:       w.ID,
-		Template: w.Template,
-		Hardware: w.Hardware,
-		State:    state[w.State],
-		Data:     yamlData,
+		Id:         w.ID,
+		Template:   w.Template,
+		Hardware:   w.Hardware,
+		Parameters: w.Parameters,
+		Includes:   templateIncludes(w.Includes),
+		State:      state[w.State],
+		Data:       yamlData,
 	}
 	l := logger.With("workflowID", w.ID)
 	l.Info("done " + msg)
@@ -134,6 +168,7 @@ func (s *server) DeleteWorkflow(ctx context.Context, in *workflow.GetRequest) (*
 	defer timer.ObserveDuration()
 
 	l.Info(msg)
+	wfContext, ctxErr := s.db.GetWorkflowContexts(ctx, in.Id)
 	err := s.db.DeleteWorkflow(ctx, in.Id, workflow.State_value[workflow.State_STATE_RUNNING.String()])
 	if err != nil {
 		metrics.CacheErrors.With(labels).Inc()
@@ -142,6 +177,8 @@ func (s *server) DeleteWorkflow(ctx context.Context, in *workflow.GetRequest) (*
 			l = l.With("detail", pqErr.Detail, "where", pqErr.Where)
 		}
 		l.Error(err)
+	} else if ctxErr == nil {
+		metrics.WorkflowsCurrent.WithLabelValues(stateLabel(workflowState(wfContext))).Dec()
 	}
 	l.Info("done " + msg)
 	return &workflow.Empty{}, err
@@ -167,11 +204,13 @@ func (s *server) ListWorkflows(_ *workflow.Empty, stream workflow.WorkflowServic
 	defer timer.ObserveDuration()
 	err := s.db.ListWorkflows(func(w db.Workflow) error {
 		wf := &workflowpb.Workflow{
-			Id:        w.ID,
-			Template:  w.Template,
-			Hardware:  w.Hardware,
-			CreatedAt: w.CreatedAt,
-			UpdatedAt: w.UpdatedAt,
+			Id:         w.ID,
+			Template:   w.Template,
+			Hardware:   w.Hardware,
+			Parameters: w.Parameters,
+			Includes:   templateIncludes(w.Includes),
+			CreatedAt:  w.CreatedAt,
+			UpdatedAt:  w.UpdatedAt,
 		}
 		return stream.Send(wf)
 	})
@@ -209,13 +248,16 @@ func (s *server) GetWorkflowContext(ctx context.Context, in *workflow.GetRequest
 		l.Error(err)
 	}
 	wf := &workflow.WorkflowContext{
-		WorkflowId:           w.WorkflowId,
-		CurrentWorker:        w.CurrentWorker,
-		CurrentTask:          w.CurrentTask,
-		CurrentAction:        w.CurrentAction,
-		CurrentActionIndex:   w.CurrentActionIndex,
-		CurrentActionState:   workflow.State(w.CurrentActionState),
-		TotalNumberOfActions: w.TotalNumberOfActions,
+		WorkflowId:            w.WorkflowId,
+		CurrentWorker:         w.CurrentWorker,
+		CurrentTask:           w.CurrentTask,
+		CurrentAction:         w.CurrentAction,
+		CurrentActionIndex:    w.CurrentActionIndex,
+		CurrentActionState:    workflow.State(w.CurrentActionState),
+		TotalNumberOfActions:  w.TotalNumberOfActions,
+		CurrentActionProgress: w.CurrentActionProgress,
+		CurrentActionMessage:  w.CurrentActionMessage,
+		Paused:                w.Paused,
 	}
 	l := logger.With(
 		"workflowID", wf.GetWorkflowId(),
@@ -270,37 +312,149 @@ func (s *server) ShowWorkflowEvents(req *workflow.GetRequest, stream workflow.Wo
 	return nil
 }
 
-func createYaml(ctx context.Context, db db.Database, temp string, devices string) (string, error) {
+// createYaml renders a template with its includes expanded, and returns the
+// includes along with the rendered template
+func createYaml(ctx context.Context, db db.Database, temp string, devices string, params map[string]string) (string, []wflow.Include, error) {
 	_, tempData, err := db.GetTemplate(ctx, temp)
 	if err != nil {
-		return "", errors.Wrapf(err, errFailedToGetTemplate, temp)
+		return "", nil, errors.Wrapf(err, errFailedToGetTemplate, temp)
 	}
-	return renderTemplate(temp, tempData, []byte(devices))
-}
-
-func renderTemplate(templateID, tempData string, devices []byte) (string, error) {
-	var hardware map[string]interface{}
-	err := json.Unmarshal(devices, &hardware)
+	tempData, includes, err := wflow.ExpandIncludes(temp, tempData, templateLookup(ctx, db))
 	if err != nil {
-		err = errors.Wrapf(err, errTemplateParsing, templateID)
 		logger.Error(err)
-		return "", err
+		return "", nil, status.Errorf(codes.InvalidArgument, err.Error())
 	}
-
-	t := template.New("workflow-template")
-	_, err = t.Parse(string(tempData))
+	data, err := wflow.RenderTemplate(temp, tempData, []byte(devices), params)
 	if err != nil {
-		err = errors.Wrapf(err, errTemplateParsing, templateID)
 		logger.Error(err)
-		return "", nil
+		// the hardware or the parameters do not match the template
+		return "", nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	return data, includes, nil
+}
+
+// templateIncludes converts the includes recorded on a workflow
+func templateIncludes(includes []wflow.Include) []*workflow.TemplateInclude {
+	var res []*workflow.TemplateInclude
+	for _, in := range includes {
+		res = append(res, &workflow.TemplateInclude{Name: in.Name, TemplateId: in.TemplateID, IncludedBy: in.IncludedBy})
 	}
+	return res
+}
+
+// RetryWorkflow implements workflow.RetryWorkflow
+func (s *server) RetryWorkflow(ctx context.Context, in *workflow.RetryWorkflowRequest) (*workflow.Empty, error) {
+	logger.Info("retryworkflow")
+	labels := prometheus.Labels{"method": "RetryWorkflow", "op": "retry"}
+	metrics.CacheInFlight.With(labels).Inc()
+	defer metrics.CacheInFlight.With(labels).Dec()
+
+	const msg = "retrying a workflow"
+	l := logger.With("workflowID", in.GetId(), "fromAction", in.GetFromAction())
 
-	buf := new(bytes.Buffer)
-	err = t.Execute(buf, hardware)
+	metrics.CacheTotals.With(labels).Inc()
+	timer := prometheus.NewTimer(metrics.CacheDuration.With(labels))
+	defer timer.ObserveDuration()
+
+	ctx, span := tracing.StartWorkflowSpan(ctx, in.GetId(), "RetryWorkflow")
+	defer span.End()
+
+	l.Info(msg)
+	if err := retryWorkflow(ctx, s.db, in.GetId(), in.GetFromAction()); err != nil {
+		metrics.CacheErrors.With(labels).Inc()
+		l.Error(err)
+		return &workflow.Empty{}, err
+	}
+	l.Info("done " + msg)
+	return &workflow.Empty{}, nil
+}
+
+// retryWorkflow resets the state of a failed or timed out workflow so that
+// its workers execute it again from the given action, by default the one
+// which did not succeed. The events, data and outputs of the workflow are
+// kept.
+func retryWorkflow(ctx context.Context, d db.Database, wfID, fromAction string) error {
+	if wfID == "" {
+		return status.Errorf(codes.InvalidArgument, errInvalidWorkflowId)
+	}
+	wfContext, err := d.GetWorkflowContexts(ctx, wfID)
 	if err != nil {
-		err = errors.Wrapf(err, errTemplateParsing, templateID)
-		logger.Error(err)
-		return "", err
+		return status.Errorf(codes.Aborted, err.Error())
+	}
+	if wfContext.GetWorkflowId() == "" {
+		return status.Errorf(codes.NotFound, errWorkflowNotFound, wfID)
+	}
+	prevState := workflowState(wfContext)
+	if prevState != workflow.State_STATE_FAILED && prevState != workflow.State_STATE_TIMEOUT {
+		return status.Errorf(codes.FailedPrecondition, errRetryNotFailed, stateLabel(prevState))
+	}
+	actions, err := d.GetWorkflowActions(ctx, wfID)
+	if err != nil {
+		return status.Errorf(codes.Aborted, err.Error())
+	}
+
+	index := int(wfContext.GetCurrentActionIndex())
+	if fromAction != "" {
+		index, err = retryActionIndex(actions, index, fromAction)
+		if err != nil {
+			return err
+		}
+	}
+	if index < 0 || index >= len(actions.GetActionList()) {
+		return status.Errorf(codes.FailedPrecondition, errRetryInvalidIndex, index, len(actions.GetActionList()))
+	}
+	action := actions.GetActionList()[index]
+
+	// the pending action is started again by its worker as if the previous
+	// one just succeeded
+	wfContext.CurrentWorker = action.GetWorkerId()
+	wfContext.CurrentTask = action.GetTaskName()
+	wfContext.CurrentAction = action.GetName()
+	wfContext.CurrentActionIndex = int64(index)
+	wfContext.CurrentActionState = workflow.State_STATE_PENDING
+	wfContext.CurrentActionProgress = 0
+	wfContext.CurrentActionMessage = msgWorkflowRetried
+	if err := d.UpdateWorkflowState(ctx, wfContext); err != nil {
+		return status.Errorf(codes.Aborted, err.Error())
+	}
+	event := &workflow.WorkflowActionStatus{
+		WorkflowId:   wfID,
+		WorkerId:     action.GetWorkerId(),
+		TaskName:     action.GetTaskName(),
+		ActionName:   action.GetName(),
+		ActionStatus: workflow.State_STATE_PENDING,
+		Message:      msgWorkflowRetried,
+	}
+	if err := d.InsertIntoWorkflowEventTable(ctx, event, time.Now()); err != nil {
+		return status.Errorf(codes.Aborted, err.Error())
+	}
+	observeWorkflow(ctx, d, wfID, workflowTransition{from: prevState, to: workflowState(wfContext)})
+	if err := waitForApproval(ctx, d, wfID); err != nil {
+		return status.Errorf(codes.Aborted, err.Error())
+	}
+	return nil
+}
+
+// retryActionIndex returns the index of the action named by from, among the
+// actions up to the failed one. The action is named by its own name, or by the
+// names of its task and its own as "task/action" when the tasks of the
+// workflow have actions with the same name.
+func retryActionIndex(actions *workflow.WorkflowActionList, failed int, from string) (int, error) {
+	index := -1
+	for i, action := range actions.GetActionList() {
+		if i > failed {
+			break
+		}
+		if action.GetName() != from && action.GetTaskName()+"/"+action.GetName() != from {
+			continue
+		}
+		if index >= 0 {
+			return -1, status.Errorf(codes.InvalidArgument, errRetryAmbiguousName, from)
+		}
+		index = i
+	}
+	if index < 0 {
+		return -1, status.Errorf(codes.InvalidArgument, errRetryInvalidAction, from)
 	}
-	return buf.String(), nil
+	return index, nil
 }
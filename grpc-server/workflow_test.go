@@ -8,8 +8,12 @@ import (
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/tinkerbell/tink/db"
+	"github.com/tinkerbell/tink/db/memory"
 	"github.com/tinkerbell/tink/db/mock"
+	"github.com/tinkerbell/tink/protos/template"
 	"github.com/tinkerbell/tink/protos/workflow"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -110,3 +114,271 @@ func TestCreateWorkflow(t *testing.T) {
 		})
 	}
 }
+
+// TestWorkflowLifecycle runs a workflow from its template to its completion
+// against the in-memory database
+func TestWorkflowLifecycle(t *testing.T) {
+	ctx := context.Background()
+	d := memory.New()
+	s := testServer(d)
+
+	const workerID = "ce2e62ed-826f-4485-a39f-a82bb74338e2"
+	err := d.InsertIntoDB(ctx, `{"id": "`+workerID+`", "network": {"interfaces": [{"dhcp": {"mac": "08:00:27:00:00:01"}}]}}`)
+	assert.NoError(t, err)
+	tmp, err := s.CreateTemplate(ctx, &template.WorkflowTemplate{Name: "hello", Data: templateData})
+	assert.NoError(t, err)
+	res, err := s.CreateWorkflow(ctx, &workflow.CreateRequest{Template: tmp.Id, Hardware: hw})
+	assert.NoError(t, err)
+
+	wfContexts, err := s.GetWorkflowContextList(ctx, &workflow.WorkflowContextRequest{WorkerId: workerID})
+	assert.NoError(t, err)
+	if assert.Len(t, wfContexts.GetWorkflowContexts(), 1) {
+		assert.Equal(t, res.Id, wfContexts.GetWorkflowContexts()[0].GetWorkflowId())
+	}
+
+	for _, state := range []workflow.State{workflow.State_STATE_RUNNING, workflow.State_STATE_SUCCESS} {
+		_, err = s.ReportActionStatus(ctx, &workflow.WorkflowActionStatus{
+			WorkflowId:   res.Id,
+			TaskName:     "hello world",
+			ActionName:   "hello_world",
+			ActionStatus: state,
+			WorkerId:     workerID,
+		})
+		assert.NoError(t, err)
+	}
+
+	wfContext, err := s.GetWorkflowContext(ctx, &workflow.GetRequest{Id: res.Id})
+	assert.NoError(t, err)
+	assert.Equal(t, workflow.State_STATE_SUCCESS, wfContext.GetCurrentActionState())
+	assert.Equal(t, workflow.State_STATE_SUCCESS, workflowState(wfContext))
+
+	var events int
+	assert.NoError(t, d.ShowWorkflowEvents(res.Id, func(*workflow.WorkflowActionStatus) error {
+		events++
+		return nil
+	}))
+	assert.Equal(t, 2, events)
+}
+
+func TestCreateWorkflowParameters(t *testing.T) {
+	const data = `version: "0.1"
+name: hello_world_workflow
+global_timeout: 600
+parameters:
+  - name: greeting
+    required: true
+  - name: repeat
+    type: int
+    default: 1
+tasks:
+  - name: "hello world"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "hello_world"
+      image: hello-world
+      timeout: 60
+      environment:
+        GREETING: '{{ param "greeting" }}'
+        REPEAT: '{{ param "repeat" }}'`
+
+	ctx := context.Background()
+	d := memory.New()
+	s := testServer(d)
+	err := d.InsertIntoDB(ctx, `{"id": "ce2e62ed-826f-4485-a39f-a82bb74338e2", "network": {"interfaces": [{"dhcp": {"mac": "08:00:27:00:00:01"}}]}}`)
+	assert.NoError(t, err)
+	tmp, err := s.CreateTemplate(ctx, &template.WorkflowTemplate{Name: "hello", Data: data})
+	assert.NoError(t, err)
+
+	for _, params := range []map[string]string{
+		nil,
+		{"greeting": "hello", "repeat": "twice"},
+		{"greeting": "hello", "name": "world"},
+	} {
+		_, err = s.CreateWorkflow(ctx, &workflow.CreateRequest{Template: tmp.Id, Hardware: hw, Parameters: params})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), "%v", params)
+	}
+
+	params := map[string]string{"greeting": "hello"}
+	res, err := s.CreateWorkflow(ctx, &workflow.CreateRequest{Template: tmp.Id, Hardware: hw, Parameters: params})
+	assert.NoError(t, err)
+	wf, err := s.GetWorkflow(ctx, &workflow.GetRequest{Id: res.Id})
+	assert.NoError(t, err)
+	assert.Equal(t, params, wf.GetParameters())
+	actions, err := d.GetWorkflowActions(ctx, res.Id)
+	assert.NoError(t, err)
+	if assert.Len(t, actions.GetActionList(), 1) {
+		assert.ElementsMatch(t, []string{"GREETING=hello", "REPEAT=1"}, actions.GetActionList()[0].GetEnvironment())
+	}
+}
+
+func TestRetryWorkflow(t *testing.T) {
+	const (
+		workerID = "ce2e62ed-826f-4485-a39f-a82bb74338e2"
+		data     = `version: "0.1"
+name: disk_wipe
+global_timeout: 600
+tasks:
+  - name: "wipe"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "partition"
+      image: partition
+      timeout: 60
+    - name: "wipe_disk"
+      image: wipe
+      timeout: 60`
+	)
+	ctx := context.Background()
+	d := memory.New()
+	s := testServer(d)
+	err := d.InsertIntoDB(ctx, `{"id": "`+workerID+`", "network": {"interfaces": [{"dhcp": {"mac": "08:00:27:00:00:01"}}]}}`)
+	assert.NoError(t, err)
+	tmp, err := s.CreateTemplate(ctx, &template.WorkflowTemplate{Name: "disk_wipe", Data: data})
+	assert.NoError(t, err)
+	res, err := s.CreateWorkflow(ctx, &workflow.CreateRequest{Template: tmp.Id, Hardware: hw})
+	assert.NoError(t, err)
+
+	report := func(action string, state workflow.State) {
+		_, err := s.ReportActionStatus(ctx, &workflow.WorkflowActionStatus{
+			WorkflowId:   res.Id,
+			TaskName:     "wipe",
+			ActionName:   action,
+			ActionStatus: state,
+			WorkerId:     workerID,
+		})
+		assert.NoError(t, err)
+	}
+	currentState := func() *workflow.WorkflowContext {
+		wfContext, err := d.GetWorkflowContexts(ctx, res.Id)
+		assert.NoError(t, err)
+		return wfContext
+	}
+	retry := func(from string) codes.Code {
+		_, err := s.RetryWorkflow(ctx, &workflow.RetryWorkflowRequest{Id: res.Id, FromAction: from})
+		return status.Code(err)
+	}
+
+	report("partition", workflow.State_STATE_RUNNING)
+	report("partition", workflow.State_STATE_SUCCESS)
+	assert.Equal(t, codes.FailedPrecondition, retry(""))
+	report("wipe_disk", workflow.State_STATE_RUNNING)
+	report("wipe_disk", workflow.State_STATE_FAILED)
+	assert.False(t, isApplicableToSend(ctx, currentState(), workerID, d))
+
+	assert.Equal(t, codes.InvalidArgument, retry("unknown"))
+	assert.Equal(t, codes.OK, retry(""))
+	wfContext := currentState()
+	assert.Equal(t, "wipe_disk", wfContext.GetCurrentAction())
+	assert.Equal(t, int64(1), wfContext.GetCurrentActionIndex())
+	assert.Equal(t, workflow.State_STATE_PENDING, wfContext.GetCurrentActionState())
+	assert.True(t, isApplicableToSend(ctx, wfContext, workerID, d))
+
+	report("wipe_disk", workflow.State_STATE_RUNNING)
+	report("wipe_disk", workflow.State_STATE_TIMEOUT)
+	assert.Equal(t, codes.OK, retry("partition"))
+	wfContext = currentState()
+	assert.Equal(t, "partition", wfContext.GetCurrentAction())
+	assert.Equal(t, int64(0), wfContext.GetCurrentActionIndex())
+
+	report("partition", workflow.State_STATE_RUNNING)
+	report("partition", workflow.State_STATE_SUCCESS)
+	report("wipe_disk", workflow.State_STATE_RUNNING)
+	report("wipe_disk", workflow.State_STATE_SUCCESS)
+	assert.Equal(t, workflow.State_STATE_SUCCESS, workflowState(currentState()))
+
+	// the events of the failed runs are kept
+	var events, retries int
+	assert.NoError(t, d.ShowWorkflowEvents(res.Id, func(e *workflow.WorkflowActionStatus) error {
+		events++
+		if e.GetMessage() == msgWorkflowRetried {
+			retries++
+		}
+		return nil
+	}))
+	assert.Equal(t, 12, events)
+	assert.Equal(t, 2, retries)
+
+	_, err = s.RetryWorkflow(ctx, &workflow.RetryWorkflowRequest{Id: uuid.New().String()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestRetryWorkflowInvalidIndex(t *testing.T) {
+	s := testServer(mock.DB{
+		GetWorkflowContextsFunc: func(ctx context.Context, wfID string) (*workflow.WorkflowContext, error) {
+			return &workflow.WorkflowContext{
+				WorkflowId:           wfID,
+				CurrentAction:        "wipe_disk",
+				CurrentActionIndex:   2,
+				CurrentActionState:   workflow.State_STATE_FAILED,
+				TotalNumberOfActions: 2,
+			}, nil
+		},
+		GetWorkflowActionsFunc: func(ctx context.Context, wfID string) (*workflow.WorkflowActionList, error) {
+			return &workflow.WorkflowActionList{ActionList: []*workflow.WorkflowAction{{Name: "partition"}, {Name: "wipe_disk"}}}, nil
+		},
+	})
+	_, err := s.RetryWorkflow(context.Background(), &workflow.RetryWorkflowRequest{Id: workflowID})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Contains(t, err.Error(), "action index 2 is out of range")
+}
+
+func TestRetryActionIndex(t *testing.T) {
+	actions := &workflow.WorkflowActionList{
+		ActionList: []*workflow.WorkflowAction{
+			{TaskName: "os-installation", Name: "partition"},
+			{TaskName: "os-installation", Name: "install"},
+			{TaskName: "data-disk", Name: "partition"},
+			{TaskName: "data-disk", Name: "format"},
+			{TaskName: "data-disk", Name: "mount"},
+		},
+	}
+	testCases := map[string]struct {
+		from          string
+		failed        int
+		expectedIndex int
+		expectedError string
+	}{
+		"action name": {
+			from:          "install",
+			failed:        3,
+			expectedIndex: 1,
+		},
+		"task and action names": {
+			from:          "data-disk/partition",
+			failed:        3,
+			expectedIndex: 2,
+		},
+		"ambiguous action name": {
+			from:          "partition",
+			failed:        3,
+			expectedError: "several actions are named partition",
+		},
+		"action name unique before the failed action": {
+			from:          "partition",
+			failed:        1,
+			expectedIndex: 0,
+		},
+		"action after the failed one": {
+			from:          "mount",
+			failed:        3,
+			expectedError: "no action mount before the failed one",
+		},
+		"action of another task": {
+			from:          "os-installation/format",
+			failed:        3,
+			expectedError: "no action os-installation/format before the failed one",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			index, err := retryActionIndex(actions, tc.failed, tc.from)
+			if tc.expectedError != "" {
+				assert.Equal(t, codes.InvalidArgument, status.Code(err))
+				assert.Contains(t, err.Error(), tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedIndex, index)
+		})
+	}
+}
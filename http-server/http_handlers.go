@@ -15,6 +15,7 @@ import (
 	"github.com/tinkerbell/tink/protos/hardware"
 	"github.com/tinkerbell/tink/protos/template"
 	"github.com/tinkerbell/tink/protos/workflow"
+	wflow "github.com/tinkerbell/tink/workflow"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -547,7 +548,7 @@ func RegisterWorkflowSvcHandlerFromEndpoint(ctx context.Context, mux *runtime.Se
 
 func tryParseTemplate(data string) error {
 	tmpl := *tt.New("")
-	if _, err := tmpl.Parse(data); err != nil {
+	if _, err := tmpl.Parse(wflow.EscapeRefs(data)); err != nil {
 		return err
 	}
 	return nil
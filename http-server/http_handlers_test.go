@@ -16,12 +16,17 @@ import (
 	"github.com/packethost/pkg/log"
 	"github.com/tinkerbell/tink/protos/hardware"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/test/bufconn"
 )
 
 const bufSize = 1024 * 1024
 
-var lis *bufconn.Listener
+var (
+	lis          *bufconn.Listener
+	healthServer = health.NewServer()
+)
 
 func bufDialer(context.Context, string) (net.Conn, error) {
 	return lis.Dial()
@@ -53,6 +58,7 @@ func TestMain(m *testing.M) {
 	lis = bufconn.Listen(bufSize)
 	s := grpc.NewServer()
 	hardware.RegisterHardwareServiceServer(s, &server{})
+	healthpb.RegisterHealthServer(s, healthServer)
 	go func() {
 		if err := s.Serve(lis); err != nil {
 			logger.Info("Server exited with error: %v", err)
@@ -94,6 +100,71 @@ func TestHardwarePushHandler(t *testing.T) {
 	}
 }
 
+func TestReadinessHandler(t *testing.T) {
+	conn, err := grpc.DialContext(context.Background(), "bufnet", grpc.WithContextDialer(bufDialer), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	handler := readinessHandler(healthpb.NewHealthClient(conn))
+
+	for _, test := range []struct {
+		status   healthpb.HealthCheckResponse_ServingStatus
+		code     int
+		response string
+	}{
+		{healthpb.HealthCheckResponse_SERVING, http.StatusOK, `{"status":"SERVING"}`},
+		{healthpb.HealthCheckResponse_NOT_SERVING, http.StatusServiceUnavailable, `{"status":"NOT_SERVING"}`},
+	} {
+		healthServer.SetServingStatus("", test.status)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, httptest.NewRequest("GET", "/readyz", nil))
+		if resp.Code != test.code {
+			t.Errorf("handler returned wrong status code: got %v want %v", resp.Code, test.code)
+		}
+		if body := resp.Body.String(); body != test.response {
+			t.Errorf("handler returned wrong body: got %v want %v", body, test.response)
+		}
+	}
+}
+
+func TestReadinessHandlerUnreachable(t *testing.T) {
+	handler := readinessHandler(unreachableHealthClient{err: errors.New("dial failed")})
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("GET", "/readyz", nil))
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v", resp.Code, http.StatusServiceUnavailable)
+	}
+	if body, want := resp.Body.String(), `{"status":"UNKNOWN","error":"dial failed"}`; body != want {
+		t.Errorf("handler returned wrong body: got %v want %v", body, want)
+	}
+}
+
+func TestTemplateSchemaHandler(t *testing.T) {
+	for _, test := range []struct {
+		path string
+		code int
+	}{
+		{"/schemas/template/0.1.json", http.StatusOK},
+		{"/schemas/template/0.2.json", http.StatusOK},
+		{"/schemas/template/0.3.json", http.StatusNotFound},
+		{"/schemas/template/0.2", http.StatusNotFound},
+	} {
+		resp := httptest.NewRecorder()
+		templateSchemaHandler(resp, httptest.NewRequest("GET", test.path, nil))
+		if resp.Code != test.code {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", test.path, resp.Code, test.code)
+		}
+		if test.code != http.StatusOK {
+			continue
+		}
+		var schema map[string]interface{}
+		if err := json.Unmarshal(resp.Body.Bytes(), &schema); err != nil {
+			t.Errorf("%s: handler returned invalid json: %v", test.path, err)
+		}
+	}
+}
+
 var handlerTests = map[string]struct {
 	id     string
 	status int
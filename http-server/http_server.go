@@ -10,16 +10,28 @@ import (
 	"net/http"
 	"os"
 	"runtime"
+	"strings"
 	"time"
 
 	grpcRuntime "github.com/grpc-ecosystem/grpc-gateway/runtime"
 	"github.com/packethost/pkg/log"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tinkerbell/tink/tracing"
+	wflow "github.com/tinkerbell/tink/workflow"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
+// templateSchemaPath is where the JSON Schemas of the template format are
+// published, as <version>.json
+const templateSchemaPath = "/schemas/template/"
+
+// readinessTimeout is how long the gRPC server is waited for to tell if it is ready
+const readinessTimeout = 5 * time.Second
+
 var (
 	gitRev         = "unknown"
 	gitRevJSON     []byte
@@ -45,7 +57,8 @@ func SetupHTTP(ctx context.Context, lg log.Logger, certPEM []byte, modTime time.
 
 	mux := grpcRuntime.NewServeMux()
 
-	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	// the trace context of the HTTP requests is propagated to the gRPC server
+	dialOpts := append([]grpc.DialOption{grpc.WithTransportCredentials(creds)}, tracing.DialOptions()...)
 
 	if grpcEndpoint == "" {
 		grpcEndpoint = "localhost:42113"
@@ -77,7 +90,20 @@ func SetupHTTP(ctx context.Context, lg log.Logger, certPEM []byte, modTime time.
 	setupGitRevJSON()
 	http.HandleFunc("/version", versionHandler)
 	http.HandleFunc("/healthz", healthCheckHandler)
-	http.Handle("/", BasicAuth(mux))
+	http.HandleFunc(templateSchemaPath, templateSchemaHandler)
+	// the server is ready when its gRPC server answers that it is serving
+	healthConn, err := grpc.DialContext(ctx, grpcEndpoint, dialOpts...)
+	if err != nil {
+		logger.Error(err)
+		http.HandleFunc("/readyz", readinessHandler(unreachableHealthClient{err: err}))
+	} else {
+		http.HandleFunc("/readyz", readinessHandler(healthpb.NewHealthClient(healthConn)))
+		go func() {
+			<-ctx.Done()
+			_ = healthConn.Close()
+		}()
+	}
+	http.Handle("/", otelhttp.NewHandler(BasicAuth(mux), "gateway"))
 
 	if httpListenAddr == "" {
 		httpListenAddr = ":42114"
@@ -106,6 +132,23 @@ func versionHandler(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(gitRevJSON)
 }
 
+// templateSchemaHandler serves the JSON Schema of a version of the template
+// format, for the editors and the CI jobs checking the templates
+func templateSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, templateSchemaPath)
+	if !strings.HasSuffix(name, ".json") {
+		http.NotFound(w, r)
+		return
+	}
+	schema, err := wflow.JSONSchema(strings.TrimSuffix(name, ".json"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	_, _ = w.Write(schema)
+}
+
 func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	res := struct {
 		GitRev     string  `json:"git_rev"`
@@ -126,6 +169,53 @@ func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(b)
 }
 
+// unreachableHealthClient answers the health checks with the error the
+// connection to the gRPC server failed with, the server is then never ready
+type unreachableHealthClient struct {
+	err error
+}
+
+func (c unreachableHealthClient) Check(context.Context, *healthpb.HealthCheckRequest, ...grpc.CallOption) (*healthpb.HealthCheckResponse, error) {
+	return nil, c.err
+}
+
+func (c unreachableHealthClient) Watch(context.Context, *healthpb.HealthCheckRequest, ...grpc.CallOption) (healthpb.Health_WatchClient, error) {
+	return nil, c.err
+}
+
+// readinessHandler reports whether the gRPC server is ready to serve, i.e.
+// it is listening and its dependencies are healthy
+func readinessHandler(client healthpb.HealthClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		res := struct {
+			Status string `json:"status"`
+			Error  string `json:"error,omitempty"`
+		}{}
+		status := healthpb.HealthCheckResponse_UNKNOWN
+		check, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			res.Error = err.Error()
+		} else {
+			status = check.GetStatus()
+		}
+		res.Status = status.String()
+
+		b, err := json.Marshal(&res)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if status != healthpb.HealthCheckResponse_SERVING {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = w.Write(b)
+	}
+}
+
 func setupGitRevJSON() {
 	res := struct {
 		GitRev  string `json:"git_rev"`
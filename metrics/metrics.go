@@ -20,8 +20,18 @@ var (
 	ingestDuration *prometheus.GaugeVec
 
 	WatchMissTotal prometheus.Counter
+
+	WorkflowsCurrent   *prometheus.GaugeVec
+	WorkflowDuration   prometheus.ObserverVec
+	WorkflowStartDelay prometheus.ObserverVec
+	ActionDuration     prometheus.ObserverVec
+	ActionFailures     *prometheus.CounterVec
+	WorkersActive      prometheus.Gauge
 )
 
+// WorkflowStates are the values of the state label of the workflow metrics
+var WorkflowStates = []string{"pending", "running", "failed", "timeout", "success"}
+
 // SetupMetrics sets the defaults for metrics
 func SetupMetrics(facility string, logger log.Logger) {
 	curryLabels := prometheus.Labels{
@@ -115,6 +125,47 @@ func SetupMetrics(facility string, logger log.Logger) {
 		Name: "watch_miss_count_total",
 		Help: "Number of missed updates due to a blocked channel.",
 	})
+
+	setupWorkflowMetrics(curryLabels)
+}
+
+// setupWorkflowMetrics sets the metrics tracking the execution of the
+// workflows, from their creation to the end of their last action
+func setupWorkflowMetrics(curryLabels prometheus.Labels) {
+	WorkflowsCurrent = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "workflows_current",
+		Help: "Number of workflows by state.",
+	}, []string{"service", "facility", "state"}).MustCurryWith(curryLabels)
+	WorkflowDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "workflow_duration_seconds",
+		Help:    "Duration of the workflows, from their creation to their final state.",
+		Buckets: prometheus.ExponentialBuckets(10, 2, 12),
+	}, []string{"service", "facility", "template", "state"}).MustCurryWith(curryLabels)
+	WorkflowStartDelay = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "workflow_start_delay_seconds",
+		Help:    "Time between the creation of the workflows and the start of their first action.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 14),
+	}, []string{"service", "facility", "template"}).MustCurryWith(curryLabels)
+	ActionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "action_duration_seconds",
+		Help:    "Duration of the workflow actions, as reported by the workers.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 14),
+	}, []string{"service", "facility", "template", "action", "state"}).MustCurryWith(curryLabels)
+	ActionFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "action_failures_total",
+		Help: "Number of workflow actions which failed or timed out.",
+	}, []string{"service", "facility", "template", "action", "state"}).MustCurryWith(curryLabels)
+	WorkersActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Name:        "workers_active",
+		Help:        "Number of workers seen within the worker grace period.",
+		ConstLabels: curryLabels,
+	})
+
+	labels := []prometheus.Labels{}
+	for _, state := range WorkflowStates {
+		labels = append(labels, prometheus.Labels{"state": state})
+	}
+	initGaugeLabels(WorkflowsCurrent, labels)
 }
 
 func initObserverLabels(m prometheus.ObserverVec, l []prometheus.Labels) {
@@ -0,0 +1,109 @@
+// Package tracing sets up the OpenTelemetry tracing of the tink services and
+// maps every workflow to a trace of its own, so that the spans of the server,
+// the database and the workers executing a workflow can be looked up at once.
+package tracing
+
+import (
+	"context"
+	"os"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/propagators"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"google.golang.org/grpc"
+)
+
+const (
+	// EndpointEnv is the environment variable holding the address of the
+	// OTLP collector the spans are exported to. Tracing is disabled if empty.
+	EndpointEnv = "OTEL_EXPORTER_OTLP_ENDPOINT"
+
+	instrumentationName = "github.com/tinkerbell/tink"
+
+	// WorkflowIDKey is the attribute holding the id of a workflow
+	WorkflowIDKey = label.Key("tink.workflow.id")
+)
+
+// Setup installs the trace context propagators and, when a collector is
+// configured, a tracer provider exporting the spans of the service. Spans
+// without parent are only sampled with sampleRoots, so that the periodic
+// requests of the workers are only traced as part of a workflow. The
+// returned function flushes the pending spans.
+func Setup(service string, sampleRoots bool) (func(context.Context) error, error) {
+	global.SetTextMapPropagator(otel.NewCompositeTextMapPropagator(propagators.TraceContext{}, propagators.Baggage{}))
+
+	endpoint := os.Getenv(EndpointEnv)
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exp, err := otlp.NewExporter(otlp.WithInsecure(), otlp.WithAddress(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	root := sdktrace.NeverSample()
+	if sampleRoots {
+		root = sdktrace.AlwaysSample()
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithConfig(sdktrace.Config{DefaultSampler: sdktrace.ParentBased(root)}),
+		sdktrace.WithResource(resource.New(semconv.ServiceNameKey.String(service))),
+		sdktrace.WithBatcher(exp),
+	)
+	global.SetTracerProvider(tp)
+	return exp.Shutdown, nil
+}
+
+// Tracer returns the tracer of the tink services
+func Tracer() trace.Tracer {
+	return global.Tracer(instrumentationName)
+}
+
+// DialOptions are the options tracing the calls of a gRPC client and
+// propagating the trace context to the server
+func DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor()),
+	}
+}
+
+// WorkflowSpanContext returns the span context of the root of the trace of
+// a workflow. Its trace id is the id of the workflow, so that the spans
+// recorded by any service for a workflow end up in the same trace.
+func WorkflowSpanContext(wfID string) (trace.SpanContext, bool) {
+	id, err := uuid.Parse(wfID)
+	if err != nil {
+		return trace.EmptySpanContext(), false
+	}
+	sc := trace.SpanContext{TraceID: trace.ID(id), TraceFlags: trace.FlagsSampled}
+	copy(sc.SpanID[:], id[8:])
+	return sc, sc.IsValid()
+}
+
+// StartWorkflowSpan starts a span in the trace of a workflow. The span the
+// context may already hold, e.g. the one of the request creating the
+// workflow, is linked to it.
+func StartWorkflowSpan(ctx context.Context, wfID, name string, opts ...trace.SpanOption) (context.Context, trace.Span) {
+	opts = append(opts, trace.WithAttributes(WorkflowIDKey.String(wfID)))
+	sc, ok := WorkflowSpanContext(wfID)
+	if !ok {
+		return Tracer().Start(ctx, name, opts...)
+	}
+	if current := trace.SpanFromContext(ctx).SpanContext(); current.IsValid() {
+		if current.TraceID == sc.TraceID {
+			return Tracer().Start(ctx, name, opts...)
+		}
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: current}))
+	}
+	// the workflow root is the parent, rather than the span of the context
+	_, span := Tracer().Start(trace.ContextWithRemoteSpanContext(context.Background(), sc), name, opts...)
+	return trace.ContextWithSpan(ctx, span), span
+}
@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/trace"
+	export "go.opentelemetry.io/otel/sdk/export/trace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const workflowID = "5a6d7564-d699-4e9f-a29c-a5890ccbd768"
+
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*export.SpanData
+}
+
+func (r *spanRecorder) ExportSpans(_ context.Context, spans []*export.SpanData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func (r *spanRecorder) Shutdown(context.Context) error {
+	return nil
+}
+
+func (r *spanRecorder) span(name string) *export.SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func TestWorkflowSpanContext(t *testing.T) {
+	sc, ok := WorkflowSpanContext(workflowID)
+	assert.True(t, ok)
+	assert.True(t, sc.IsSampled())
+	id := uuid.MustParse(workflowID)
+	assert.Equal(t, trace.ID(id), sc.TraceID)
+
+	_, ok = WorkflowSpanContext("not-a-uuid")
+	assert.False(t, ok)
+}
+
+func TestStartWorkflowSpan(t *testing.T) {
+	rec := &spanRecorder{}
+	global.SetTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithConfig(sdktrace.Config{DefaultSampler: sdktrace.ParentBased(sdktrace.NeverSample())}),
+		sdktrace.WithSyncer(rec),
+	))
+	root, _ := WorkflowSpanContext(workflowID)
+
+	// a request creating the workflow, traced on its own
+	ctx, request := Tracer().Start(context.Background(), "request", trace.WithNewRoot())
+	_, create := StartWorkflowSpan(ctx, workflowID, "CreateWorkflow")
+	create.End()
+	request.End()
+
+	// a worker executing the workflow
+	ctx, process := StartWorkflowSpan(context.Background(), workflowID, "processWorkflow")
+	_, execute := Tracer().Start(ctx, "execute")
+	execute.End()
+	process.End()
+
+	span := rec.span("CreateWorkflow")
+	if assert.NotNil(t, span) {
+		assert.Equal(t, root.TraceID, span.SpanContext.TraceID)
+		assert.Equal(t, root.SpanID, span.ParentSpanID)
+		if assert.Len(t, span.Links, 1) {
+			assert.Equal(t, request.SpanContext(), span.Links[0].SpanContext)
+		}
+	}
+	span = rec.span("execute")
+	if assert.NotNil(t, span) {
+		assert.Equal(t, root.TraceID, span.SpanContext.TraceID)
+		assert.Equal(t, process.SpanContext().SpanID, span.ParentSpanID)
+	}
+}
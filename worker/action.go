@@ -1,9 +1,8 @@
-package internal
+package worker
 
 import (
 	"context"
 	"path"
-	"path/filepath"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
@@ -11,6 +10,7 @@ import (
 	"github.com/packethost/pkg/log"
 	"github.com/pkg/errors"
 	pb "github.com/tinkerbell/tink/protos/workflow"
+	wflow "github.com/tinkerbell/tink/workflow"
 )
 
 const (
@@ -32,14 +32,14 @@ func (w *Worker) createContainer(ctx context.Context, cmd []string, wfID string,
 		Env:          action.GetEnvironment(),
 	}
 
-	wfDir := filepath.Join(dataDir, wfID)
+	wfDir := w.workflowDir(wfID)
 	hostConfig := &container.HostConfig{
 		Privileged: true,
 		Binds:      []string{wfDir + ":/workflow"},
 	}
 	hostConfig.Binds = append(hostConfig.Binds, action.GetVolumes()...)
-	w.logger.With("command", cmd).Info("creating container")
-	resp, err := w.registryClient.ContainerCreate(ctx, config, hostConfig, nil, action.GetName())
+	w.logger.With("command", redactCommand(cmd, action.GetSecrets())).Info("creating container")
+	resp, err := w.registryClient.ContainerCreate(ctx, config, hostConfig, nil, w.containerName(wfID, action))
 	if err != nil {
 		return "", errors.Wrap(err, "DOCKER CREATE")
 	}
@@ -104,3 +104,36 @@ func removeContainer(ctx context.Context, l log.Logger, cli *client.Client, id s
 	// send API call to remove the container
 	return cli.ContainerRemove(ctx, id, opts)
 }
+
+// cleanupContainer removes a container created by the worker, even when the
+// context of the action is done because the worker is shutting down
+func cleanupContainer(l log.Logger, cli *client.Client, id string) {
+	if id == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+	if err := removeContainer(ctx, l, cli, id); err != nil {
+		l.With("containerID", id).Error(err)
+	}
+}
+
+// containerName returns the name of the container of an action, which must
+// be unique among the workflows executed concurrently
+func (w *Worker) containerName(wfID string, action *pb.WorkflowAction) string {
+	if w.parallelism > 1 {
+		return wfID + "-" + action.GetName()
+	}
+	return action.GetName()
+}
+
+func redactCommand(cmd []string, secrets []string) []string {
+	if len(secrets) == 0 {
+		return cmd
+	}
+	redacted := make([]string, len(cmd))
+	for i, arg := range cmd {
+		redacted[i] = wflow.RedactSecrets(arg, secrets)
+	}
+	return redacted
+}
@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/packethost/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/tink/artifact"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+)
+
+const (
+	artifactsDir      = "artifacts"
+	artifactChunkSize = 64 * 1024
+
+	errUploadArtifact = "failed to upload artifact"
+)
+
+func (w *Worker) resetArtifacts(wfID string) error {
+	dir := filepath.Join(w.workflowDir(wfID), artifactsDir)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return os.MkdirAll(dir, os.FileMode(0755))
+}
+
+// uploadArtifacts sends the files an action left in the artifacts directory
+// of the workflow to the server. A failed upload does not fail the action.
+func (w *Worker) uploadArtifacts(ctx context.Context, wfID string, action *pb.WorkflowAction, l log.Logger) {
+	dir := filepath.Join(w.workflowDir(wfID), artifactsDir)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		l.Error(err)
+		return
+	}
+	for _, f := range files {
+		if !f.Mode().IsRegular() || !artifact.IsValidName(f.Name()) {
+			l.With("artifact", f.Name()).Info("ignoring invalid artifact")
+			continue
+		}
+		err := w.uploadArtifact(ctx, filepath.Join(dir, f.Name()), &pb.Artifact{
+			WorkflowId: wfID,
+			WorkerId:   w.id,
+			TaskName:   action.GetTaskName(),
+			ActionName: action.GetName(),
+			Name:       f.Name(),
+		})
+		if err != nil {
+			l.With("artifact", f.Name()).Error(errors.Wrap(err, errUploadArtifact))
+			continue
+		}
+		l.With("artifact", f.Name(), "size", f.Size()).Info("artifact uploaded")
+	}
+}
+
+func (w *Worker) uploadArtifact(ctx context.Context, path string, a *pb.Artifact) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stream, err := w.client.UploadArtifact(ctx)
+	if err != nil {
+		return err
+	}
+	err = stream.Send(&pb.UploadArtifactRequest{Data: &pb.UploadArtifactRequest_Artifact{Artifact: a}})
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, artifactChunkSize)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			chunk := &pb.UploadArtifactRequest{Data: &pb.UploadArtifactRequest_Chunk{Chunk: buf[:n]}}
+			if sendErr := stream.Send(chunk); sendErr != nil {
+				// the server ended the stream, its status is returned below
+				if sendErr == io.EOF {
+					break
+				}
+				return sendErr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err = stream.CloseAndRecv()
+	return err
+}
@@ -0,0 +1,88 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+
+	pb "github.com/tinkerbell/tink/protos/workflow"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrMissingWorkerID is returned by New when no worker id is configured
+	ErrMissingWorkerID = errors.New("required worker id")
+	// ErrMissingClient is returned by New when no workflow client is configured
+	ErrMissingClient = errors.New("required workflow client")
+	// ErrMissingRegistry is returned by New when no Docker registry is configured
+	ErrMissingRegistry = errors.New("required DOCKER_REGISTRY")
+)
+
+// ServerError is returned when the worker fails to communicate with tink-server
+type ServerError struct {
+	// Op describes what the worker was trying to do
+	Op string
+	// Code is the gRPC status code returned by the server
+	Code codes.Code
+	Err  error
+}
+
+// isRefused checks if the server refused the status of an action, which
+// happens when an action starts while the workflow is paused or waiting for
+// an approval
+func isRefused(err error) bool {
+	return status.Code(err) == codes.FailedPrecondition
+}
+
+func newServerError(op string, err error) *ServerError {
+	st, _ := status.FromError(err)
+	return &ServerError{Op: op, Code: st.Code(), Err: err}
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Op, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ServerError) Unwrap() error {
+	return e.Err
+}
+
+// ActionError is returned when an action does not complete successfully
+type ActionError struct {
+	WorkflowID string
+	TaskName   string
+	ActionName string
+	// State is the final state of the action, either failed or timeout
+	State pb.State
+	// Err is the error which interrupted the action, if any
+	Err error
+}
+
+func (e *ActionError) Error() string {
+	msg := fmt.Sprintf("action %s of workflow %s ended with state %s", e.ActionName, e.WorkflowID, e.State)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error
+func (e *ActionError) Unwrap() error {
+	return e.Err
+}
+
+// DataError is returned when the worker fails to handle the data of a workflow
+type DataError struct {
+	WorkflowID string
+	Err        error
+}
+
+func (e *DataError) Error() string {
+	return fmt.Sprintf("workflow %s data: %v", e.WorkflowID, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *DataError) Unwrap() error {
+	return e.Err
+}
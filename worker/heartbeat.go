@@ -0,0 +1,88 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+)
+
+const errHeartbeat = "failed to send heartbeat"
+
+// runningAction is an action a worker is executing
+type runningAction struct {
+	workflowID string
+	actionName string
+}
+
+// currentActions are the actions a worker is executing, at most one per
+// workflow. The action started last is reported to the server along with
+// each heartbeat.
+type currentActions struct {
+	mu      sync.RWMutex
+	actions []runningAction
+}
+
+func (c *currentActions) start(wfID, actionName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(wfID)
+	c.actions = append(c.actions, runningAction{workflowID: wfID, actionName: actionName})
+}
+
+func (c *currentActions) finish(wfID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(wfID)
+}
+
+func (c *currentActions) remove(wfID string) {
+	for i, a := range c.actions {
+		if a.workflowID == wfID {
+			c.actions = append(c.actions[:i], c.actions[i+1:]...)
+			return
+		}
+	}
+}
+
+func (c *currentActions) last() (string, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.actions) == 0 {
+		return "", ""
+	}
+	a := c.actions[len(c.actions)-1]
+	return a.workflowID, a.actionName
+}
+
+// sendHeartbeats periodically tells the server that the worker is alive,
+// until the context is cancelled
+func (w *Worker) sendHeartbeats(ctx context.Context) {
+	if w.heartbeatInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(w.heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		w.sendHeartbeat(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) sendHeartbeat(ctx context.Context) {
+	wfID, actionName := w.current.last()
+	_, err := w.client.Heartbeat(ctx, &pb.HeartbeatRequest{
+		WorkerId:   w.id,
+		WorkflowId: wfID,
+		ActionName: actionName,
+	})
+	if err != nil && ctx.Err() == nil {
+		w.logger.With("workerID", w.id).Error(errors.Wrap(err, errHeartbeat))
+	}
+}
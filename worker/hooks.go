@@ -0,0 +1,38 @@
+package worker
+
+import (
+	"time"
+
+	pb "github.com/tinkerbell/tink/protos/workflow"
+)
+
+// ActionEvent describes the execution of an action
+type ActionEvent struct {
+	WorkflowID string
+	Action     *pb.WorkflowAction
+	// State is the state of the action, running when it starts
+	State pb.State
+	// Duration is the time the action took to execute, once finished
+	Duration time.Duration
+	// Err is the error which interrupted the action, if any
+	Err error
+}
+
+// Hooks are functions called by the worker as it executes actions. They are
+// called synchronously and must return quickly.
+type Hooks struct {
+	ActionStarted  func(ActionEvent)
+	ActionFinished func(ActionEvent)
+}
+
+func (h Hooks) actionStarted(e ActionEvent) {
+	if h.ActionStarted != nil {
+		h.ActionStarted(e)
+	}
+}
+
+func (h Hooks) actionFinished(e ActionEvent) {
+	if h.ActionFinished != nil {
+		h.ActionFinished(e)
+	}
+}
@@ -0,0 +1,266 @@
+package worker
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/tinkerbell/tink/artifact"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+	wflow "github.com/tinkerbell/tink/workflow"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var unsafeDirChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+const (
+	// LocalWorkerID is the id of the worker executing a workflow locally,
+	// when none is given
+	LocalWorkerID = "local"
+
+	uploadsDir = "uploads"
+
+	errNoLocalActions      = "the template has no task for worker %s"
+	errLocalUnimplemented  = "%s is not available when a workflow is executed locally"
+	errInvalidArtifactName = "invalid artifact name: %s"
+)
+
+// RunLocal executes the actions of a parsed workflow template on the local
+// machine, without tink-server. Only the tasks of the worker opts.ID are
+// executed, or every task when it is empty. The workflow data, outputs and
+// artifacts are kept in a directory named after the workflow in
+// opts.DataDir, so that they persist across runs. Secret references are
+// left as they are, approval actions are skipped and reboot actions are
+// followed by the next actions without waiting for a reboot. opts.Client is ignored
+// and opts.Registry is optional, action images are used as they are without
+// it.
+func RunLocal(ctx context.Context, opts Options, wf *wflow.Workflow) error {
+	actions := localActions(wf, opts.ID)
+	if opts.ID == "" {
+		opts.ID = LocalWorkerID
+	}
+	if len(actions) == 0 {
+		return errors.Errorf(errNoLocalActions, opts.ID)
+	}
+
+	wfID := localDirName(wf.Name)
+	opts.Client = &localClient{
+		actions: actions,
+		outputs: map[string]map[string]string{},
+		dir:     filepath.Join(opts.DataDir, wfID, uploadsDir),
+	}
+	w, err := newWorker(opts)
+	if err != nil {
+		return err
+	}
+	// the boot id is only used to detect reboots, which are not awaited
+	// locally, and is not available on every development machine
+	bootID, _ := readBootID()
+	return w.processWorkflow(ctx, &pb.WorkflowContext{
+		WorkflowId:           wfID,
+		TotalNumberOfActions: int64(len(actions)),
+	}, bootID)
+}
+
+// localActions returns the actions of a workflow executed locally by the
+// worker id, or by LocalWorkerID when id is empty. The reboot flag is cleared:
+// nothing reports the success of a reboot action locally, the workflow would
+// otherwise stop after it.
+func localActions(wf *wflow.Workflow, id string) []*pb.WorkflowAction {
+	var actions []*pb.WorkflowAction
+	for _, task := range wf.Tasks {
+		if id != "" && task.WorkerAddr != id {
+			continue
+		}
+		for _, action := range wflow.TaskActions(task, id) {
+			if action.GetType() == wflow.ActionTypeApproval {
+				continue
+			}
+			if id == "" {
+				action.WorkerId = LocalWorkerID
+			}
+			action.Reboot = false
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+// localDirName turns a name into the name of a directory which cannot escape
+// its parent, e.g. the id of a local workflow derived from its name
+func localDirName(name string) string {
+	dir := unsafeDirChars.ReplaceAllString(name, "_")
+	if dir == "" || dir == "." || dir == ".." {
+		return "_"
+	}
+	return dir
+}
+
+// localClient stands in for tink-server when a workflow is executed locally.
+// It resolves the outputs of the actions and stores the artifacts in a
+// directory. The workflow data is kept by the worker in its data file. The
+// calls the worker does not make locally are not implemented.
+type localClient struct {
+	mu      sync.Mutex
+	actions []*pb.WorkflowAction
+	outputs map[string]map[string]string
+	dir     string
+}
+
+func (c *localClient) GetWorkflowActions(ctx context.Context, in *pb.WorkflowActionsRequest, opts ...grpc.CallOption) (*pb.WorkflowActionList, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	list := &pb.WorkflowActionList{}
+	for _, action := range c.actions {
+		a := proto.Clone(action).(*pb.WorkflowAction)
+		for i, env := range a.Environment {
+			a.Environment[i] = wflow.ResolveOutputRefs(env, c.outputs)
+		}
+		list.ActionList = append(list.ActionList, a)
+	}
+	return list, nil
+}
+
+func (c *localClient) ReportActionStatus(ctx context.Context, in *pb.WorkflowActionStatus, opts ...grpc.CallOption) (*pb.Empty, error) {
+	if in.GetActionStatus() == pb.State_STATE_SUCCESS && len(in.GetOutputs()) > 0 {
+		c.mu.Lock()
+		c.outputs[in.GetActionName()] = in.GetOutputs()
+		c.mu.Unlock()
+	}
+	return &pb.Empty{}, nil
+}
+
+func (c *localClient) GetWorkflowData(ctx context.Context, in *pb.GetWorkflowDataRequest, opts ...grpc.CallOption) (*pb.GetWorkflowDataResponse, error) {
+	return &pb.GetWorkflowDataResponse{}, nil
+}
+
+func (c *localClient) UpdateWorkflowData(ctx context.Context, in *pb.UpdateWorkflowDataRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	return &pb.Empty{}, nil
+}
+
+func (c *localClient) UploadArtifact(ctx context.Context, opts ...grpc.CallOption) (pb.WorkflowService_UploadArtifactClient, error) {
+	return &localUpload{dir: c.dir}, nil
+}
+
+func (c *localClient) CreateWorkflow(ctx context.Context, in *pb.CreateRequest, opts ...grpc.CallOption) (*pb.CreateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, errLocalUnimplemented, "CreateWorkflow")
+}
+
+func (c *localClient) GetWorkflow(ctx context.Context, in *pb.GetRequest, opts ...grpc.CallOption) (*pb.Workflow, error) {
+	return nil, status.Errorf(codes.Unimplemented, errLocalUnimplemented, "GetWorkflow")
+}
+
+func (c *localClient) DeleteWorkflow(ctx context.Context, in *pb.GetRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, errLocalUnimplemented, "DeleteWorkflow")
+}
+
+func (c *localClient) ListWorkflows(ctx context.Context, in *pb.Empty, opts ...grpc.CallOption) (pb.WorkflowService_ListWorkflowsClient, error) {
+	return nil, status.Errorf(codes.Unimplemented, errLocalUnimplemented, "ListWorkflows")
+}
+
+func (c *localClient) GetWorkflowContext(ctx context.Context, in *pb.GetRequest, opts ...grpc.CallOption) (*pb.WorkflowContext, error) {
+	return nil, status.Errorf(codes.Unimplemented, errLocalUnimplemented, "GetWorkflowContext")
+}
+
+func (c *localClient) ShowWorkflowEvents(ctx context.Context, in *pb.GetRequest, opts ...grpc.CallOption) (pb.WorkflowService_ShowWorkflowEventsClient, error) {
+	return nil, status.Errorf(codes.Unimplemented, errLocalUnimplemented, "ShowWorkflowEvents")
+}
+
+func (c *localClient) RetryWorkflow(ctx context.Context, in *pb.RetryWorkflowRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, errLocalUnimplemented, "RetryWorkflow")
+}
+
+func (c *localClient) PauseWorkflow(ctx context.Context, in *pb.GetRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, errLocalUnimplemented, "PauseWorkflow")
+}
+
+func (c *localClient) ResumeWorkflow(ctx context.Context, in *pb.GetRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, errLocalUnimplemented, "ResumeWorkflow")
+}
+
+func (c *localClient) ApproveWorkflow(ctx context.Context, in *pb.GetRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, errLocalUnimplemented, "ApproveWorkflow")
+}
+
+func (c *localClient) GetWorkflowContextList(ctx context.Context, in *pb.WorkflowContextRequest, opts ...grpc.CallOption) (*pb.WorkflowContextList, error) {
+	return nil, status.Errorf(codes.Unimplemented, errLocalUnimplemented, "GetWorkflowContextList")
+}
+
+func (c *localClient) GetWorkflowContexts(ctx context.Context, in *pb.WorkflowContextRequest, opts ...grpc.CallOption) (pb.WorkflowService_GetWorkflowContextsClient, error) {
+	return nil, status.Errorf(codes.Unimplemented, errLocalUnimplemented, "GetWorkflowContexts")
+}
+
+func (c *localClient) GetWorkflowMetadata(ctx context.Context, in *pb.GetWorkflowDataRequest, opts ...grpc.CallOption) (*pb.GetWorkflowDataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, errLocalUnimplemented, "GetWorkflowMetadata")
+}
+
+func (c *localClient) GetWorkflowDataVersion(ctx context.Context, in *pb.GetWorkflowDataRequest, opts ...grpc.CallOption) (*pb.GetWorkflowDataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, errLocalUnimplemented, "GetWorkflowDataVersion")
+}
+
+func (c *localClient) RegisterWorker(ctx context.Context, in *pb.RegisterWorkerRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, errLocalUnimplemented, "RegisterWorker")
+}
+
+func (c *localClient) Heartbeat(ctx context.Context, in *pb.HeartbeatRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, errLocalUnimplemented, "Heartbeat")
+}
+
+func (c *localClient) ListWorkers(ctx context.Context, in *pb.Empty, opts ...grpc.CallOption) (pb.WorkflowService_ListWorkersClient, error) {
+	return nil, status.Errorf(codes.Unimplemented, errLocalUnimplemented, "ListWorkers")
+}
+
+func (c *localClient) ListArtifacts(ctx context.Context, in *pb.GetRequest, opts ...grpc.CallOption) (pb.WorkflowService_ListArtifactsClient, error) {
+	return nil, status.Errorf(codes.Unimplemented, errLocalUnimplemented, "ListArtifacts")
+}
+
+func (c *localClient) GetArtifact(ctx context.Context, in *pb.GetArtifactRequest, opts ...grpc.CallOption) (pb.WorkflowService_GetArtifactClient, error) {
+	return nil, status.Errorf(codes.Unimplemented, errLocalUnimplemented, "GetArtifact")
+}
+
+// localUpload writes an uploaded artifact to <dir>/<action name>/<name>, the
+// action name being made safe to use as a directory name
+type localUpload struct {
+	grpc.ClientStream
+
+	dir string
+	f   *os.File
+}
+
+func (u *localUpload) Send(req *pb.UploadArtifactRequest) error {
+	if a := req.GetArtifact(); a != nil {
+		if !artifact.IsValidName(a.GetName()) {
+			return errors.Errorf(errInvalidArtifactName, a.GetName())
+		}
+		dir := filepath.Join(u.dir, localDirName(a.GetActionName()))
+		if err := os.MkdirAll(dir, os.FileMode(0755)); err != nil {
+			return err
+		}
+		f, err := os.Create(filepath.Join(dir, a.GetName()))
+		if err != nil {
+			return err
+		}
+		u.f = f
+		return nil
+	}
+	if u.f == nil {
+		return io.ErrClosedPipe
+	}
+	_, err := u.f.Write(req.GetChunk())
+	return err
+}
+
+func (u *localUpload) CloseAndRecv() (*pb.Empty, error) {
+	if u.f == nil {
+		return &pb.Empty{}, nil
+	}
+	return &pb.Empty{}, u.f.Close()
+}
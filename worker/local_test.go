@@ -0,0 +1,111 @@
+package worker
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+	wflow "github.com/tinkerbell/tink/workflow"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRunLocalNoActions(t *testing.T) {
+	wf := &wflow.Workflow{
+		Name: "hello_world_workflow",
+		Tasks: []wflow.Task{
+			{Name: "hello world", WorkerAddr: "08:00:27:00:00:01", Actions: []wflow.Action{{Name: "hello_world", Image: "hello-world"}}},
+		},
+	}
+	err := RunLocal(context.Background(), Options{ID: "08:00:27:00:00:02", Logger: testLogger(t)}, wf)
+	assert.EqualError(t, err, "the template has no task for worker 08:00:27:00:00:02")
+}
+
+func TestLocalActions(t *testing.T) {
+	wf := &wflow.Workflow{
+		Name: "kexec_workflow",
+		Tasks: []wflow.Task{
+			{Name: "install", WorkerAddr: "08:00:27:00:00:01", Actions: []wflow.Action{
+				{Name: "kexec", Image: "kexec", Reboot: true},
+				{Name: "review", Type: wflow.ActionTypeApproval},
+				{Name: "inventory", Image: "inventory"},
+			}},
+		},
+	}
+	actions := localActions(wf, "")
+	assert.Len(t, actions, 2)
+	assert.Equal(t, "kexec", actions[0].GetName())
+	assert.False(t, actions[0].GetReboot())
+	assert.Equal(t, "inventory", actions[1].GetName())
+	for _, action := range actions {
+		assert.Equal(t, LocalWorkerID, action.GetWorkerId())
+	}
+}
+
+func TestLocalDirName(t *testing.T) {
+	assert.Equal(t, "hello_world_workflow", localDirName("hello_world_workflow"))
+	assert.Equal(t, "hello_world", localDirName("hello world"))
+	assert.Equal(t, "_", localDirName(".."))
+	assert.Equal(t, "_", localDirName(""))
+	assert.Equal(t, ".._escape", localDirName("../escape"))
+}
+
+func TestLocalClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tink-worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	c := &localClient{
+		actions: []*pb.WorkflowAction{
+			{Name: "partition", WorkerId: LocalWorkerID},
+			{Name: "install", WorkerId: LocalWorkerID, Environment: []string{"ROOT_UUID={{ outputs.partition.root_uuid }}"}},
+		},
+		outputs: map[string]map[string]string{},
+		dir:     dir,
+	}
+
+	_, err = c.ReportActionStatus(ctx, &pb.WorkflowActionStatus{
+		ActionName:   "partition",
+		ActionStatus: pb.State_STATE_SUCCESS,
+		Outputs:      map[string]string{"root_uuid": "2c1f3c7e"},
+	})
+	assert.NoError(t, err)
+	actions, err := c.GetWorkflowActions(ctx, &pb.WorkflowActionsRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ROOT_UUID=2c1f3c7e"}, actions.GetActionList()[1].GetEnvironment())
+	// the stored actions keep their references
+	assert.Equal(t, "ROOT_UUID={{ outputs.partition.root_uuid }}", c.actions[1].GetEnvironment()[0])
+
+	stream, err := c.UploadArtifact(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Send(&pb.UploadArtifactRequest{Data: &pb.UploadArtifactRequest_Artifact{Artifact: &pb.Artifact{ActionName: "install", Name: "grub.cfg"}}}))
+	assert.NoError(t, stream.Send(&pb.UploadArtifactRequest{Data: &pb.UploadArtifactRequest_Chunk{Chunk: []byte("set default=0")}}))
+	_, err = stream.CloseAndRecv()
+	assert.NoError(t, err)
+	data, err := ioutil.ReadFile(filepath.Join(dir, "install", "grub.cfg"))
+	assert.NoError(t, err)
+	assert.Equal(t, "set default=0", string(data))
+
+	// the artifacts cannot be written out of the directory
+	stream, err = c.UploadArtifact(ctx)
+	assert.NoError(t, err)
+	assert.Error(t, stream.Send(&pb.UploadArtifactRequest{Data: &pb.UploadArtifactRequest_Artifact{Artifact: &pb.Artifact{ActionName: "install", Name: "../../escape"}}}))
+	stream, err = c.UploadArtifact(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Send(&pb.UploadArtifactRequest{Data: &pb.UploadArtifactRequest_Artifact{Artifact: &pb.Artifact{ActionName: "../..", Name: "escape"}}}))
+	_, err = stream.CloseAndRecv()
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, ".._..", "escape"))
+	assert.NoError(t, err)
+
+	// the calls the worker does not make locally fail instead of panicking
+	_, err = c.RegisterWorker(ctx, &pb.RegisterWorkerRequest{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
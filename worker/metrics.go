@@ -0,0 +1,144 @@
+package worker
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+)
+
+const (
+	metricsNamespace = "tink"
+	metricsSubsystem = "worker"
+
+	outcomeSuccess     = "success"
+	outcomeFailed      = "failed"
+	outcomeTimeout     = "timeout"
+	outcomeInterrupted = "interrupted"
+
+	errRegisterMetrics = "failed to register worker metrics"
+)
+
+// workerMetrics are the Prometheus metrics of a worker. They are always
+// collected, and only exposed once registered with Options.Registerer.
+type workerMetrics struct {
+	actionDuration  *prometheus.HistogramVec
+	pullDuration    *prometheus.HistogramVec
+	pullBytes       *prometheus.CounterVec
+	reportRetries   prometheus.Counter
+	dataUploadBytes prometheus.Histogram
+	pollDuration    prometheus.Histogram
+}
+
+func newWorkerMetrics() *workerMetrics {
+	return &workerMetrics{
+		actionDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "action_duration_seconds",
+			Help:      "Duration of the actions executed by the worker, by image and outcome.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 14),
+		}, []string{"image", "outcome"}),
+		pullDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "image_pull_duration_seconds",
+			Help:      "Duration of the pulls of action images, by image and outcome.",
+			Buckets:   prometheus.ExponentialBuckets(0.25, 2, 12),
+		}, []string{"image", "outcome"}),
+		pullBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "image_pull_bytes_total",
+			Help:      "Number of bytes of image layers downloaded, by image.",
+		}, []string{"image"}),
+		reportRetries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "report_action_status_retries_total",
+			Help:      "Number of failed attempts to report an action status.",
+		}),
+		dataUploadBytes: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "workflow_data_upload_bytes",
+			Help:      "Size of the workflow data sent to the server.",
+			Buckets:   prometheus.ExponentialBuckets(256, 4, 10),
+		}),
+		pollDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "poll_duration_seconds",
+			Help:      "Duration of the polls for the workflows assigned to the worker.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+	}
+}
+
+func (m *workerMetrics) register(r prometheus.Registerer) error {
+	collectors := []prometheus.Collector{
+		m.actionDuration,
+		m.pullDuration,
+		m.pullBytes,
+		m.reportRetries,
+		m.dataUploadBytes,
+		m.pollDuration,
+	}
+	for _, c := range collectors {
+		if err := r.Register(c); err != nil {
+			return errors.Wrap(err, errRegisterMetrics)
+		}
+	}
+	return nil
+}
+
+// actionOutcome returns the outcome label of an executed action
+func actionOutcome(status pb.State, err error, interrupted bool) string {
+	switch {
+	case interrupted:
+		return outcomeInterrupted
+	case status == pb.State_STATE_TIMEOUT:
+		return outcomeTimeout
+	case err != nil || status != pb.State_STATE_SUCCESS:
+		return outcomeFailed
+	default:
+		return outcomeSuccess
+	}
+}
+
+// pullProgress is the part of the messages of an image pull needed to
+// count the bytes downloaded
+type pullProgress struct {
+	Status   string `json:"status"`
+	ID       string `json:"id"`
+	Progress struct {
+		Total int64 `json:"total"`
+	} `json:"progressDetail"`
+}
+
+// countPullBytes reads the messages of an image pull and returns the total
+// size of the layers downloaded. Each layer is counted once, even though its
+// size is repeated in every progress message.
+func countPullBytes(r io.Reader) (int64, error) {
+	layers := map[string]int64{}
+	dec := json.NewDecoder(r)
+	for {
+		var msg pullProgress
+		if err := dec.Decode(&msg); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return 0, err
+		}
+		if msg.Status == "Downloading" && msg.Progress.Total > 0 {
+			layers[msg.ID] = msg.Progress.Total
+		}
+	}
+	var total int64
+	for _, size := range layers {
+		total += size
+	}
+	return total, nil
+}
@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+	"google.golang.org/grpc"
+)
+
+type failingReportClient struct {
+	pb.WorkflowServiceClient
+}
+
+func (failingReportClient) ReportActionStatus(context.Context, *pb.WorkflowActionStatus, ...grpc.CallOption) (*pb.Empty, error) {
+	return nil, errors.New("unavailable")
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	_, err := New(Options{ID: "worker", Client: fakeClient{}, Registry: "registry", Registerer: reg})
+	assert.NoError(t, err)
+
+	// a second worker can't register the same metrics
+	_, err = New(Options{ID: "worker", Client: fakeClient{}, Registry: "registry", Registerer: reg})
+	assert.Error(t, err)
+}
+
+func TestActionOutcome(t *testing.T) {
+	testCases := map[string]struct {
+		status      pb.State
+		err         error
+		interrupted bool
+		expected    string
+	}{
+		"success":     {status: pb.State_STATE_SUCCESS, expected: outcomeSuccess},
+		"failed":      {status: pb.State_STATE_FAILED, expected: outcomeFailed},
+		"error":       {status: pb.State_STATE_RUNNING, err: errors.New("DOCKER PULL"), expected: outcomeFailed},
+		"timeout":     {status: pb.State_STATE_TIMEOUT, expected: outcomeTimeout},
+		"interrupted": {status: pb.State_STATE_FAILED, interrupted: true, expected: outcomeInterrupted},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, actionOutcome(tc.status, tc.err, tc.interrupted))
+		})
+	}
+}
+
+func TestCountPullBytes(t *testing.T) {
+	stream := `{"status":"Pulling from tinkerbell/disk-wipe","id":"latest"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"a1"}
+{"status":"Downloading","progressDetail":{"current":100,"total":1000},"id":"a1"}
+{"status":"Downloading","progressDetail":{"current":900,"total":1000},"id":"a1"}
+{"status":"Downloading","progressDetail":{"current":10,"total":500},"id":"b2"}
+{"status":"Download complete","progressDetail":{},"id":"a1"}
+{"status":"Already exists","progressDetail":{},"id":"c3"}
+`
+	n, err := countPullBytes(strings.NewReader(stream))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1500), n)
+
+	_, err = countPullBytes(strings.NewReader("not json"))
+	assert.Error(t, err)
+}
+
+func TestReportActionStatusRetries(t *testing.T) {
+	w := &Worker{
+		client:        failingReportClient{},
+		logger:        testLogger(t),
+		retries:       3,
+		retryInterval: time.Millisecond,
+		metrics:       newWorkerMetrics(),
+	}
+	err := w.reportActionStatus(context.Background(), &pb.WorkflowActionStatus{WorkflowId: "wf"})
+	assert.Error(t, err)
+	assert.Equal(t, float64(3), testutil.ToFloat64(w.metrics.reportRetries))
+}
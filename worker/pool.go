@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// pool runs functions concurrently, up to a limit, and keeps the first error
+// they return
+type pool struct {
+	sem chan struct{}
+	wg  sync.WaitGroup
+
+	mu       sync.Mutex
+	firstErr error
+	failedCh chan struct{}
+}
+
+func newPool(size int) *pool {
+	return &pool{
+		sem:      make(chan struct{}, size),
+		failedCh: make(chan struct{}),
+	}
+}
+
+// run waits for a free slot and runs fn in a new goroutine. It returns false
+// without running fn if the context is done or a function already failed.
+func (p *pool) run(ctx context.Context, fn func() error) bool {
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	case <-p.failedCh:
+		return false
+	}
+	if p.err() != nil {
+		<-p.sem
+		return false
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer func() { <-p.sem }()
+		if err := fn(); err != nil {
+			p.fail(err)
+		}
+	}()
+	return true
+}
+
+func (p *pool) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.firstErr == nil {
+		p.firstErr = err
+		close(p.failedCh)
+	}
+}
+
+// failed returns a channel closed when a function fails
+func (p *pool) failed() <-chan struct{} {
+	return p.failedCh
+}
+
+func (p *pool) err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.firstErr
+}
+
+// wait waits for the running functions to return. It returns the first error
+// they returned, if any, err otherwise.
+func (p *pool) wait(err error) error {
+	p.wg.Wait()
+	if firstErr := p.err(); firstErr != nil {
+		return firstErr
+	}
+	return err
+}
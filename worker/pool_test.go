@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolLimitsConcurrency(t *testing.T) {
+	const size = 3
+	var (
+		mu            sync.Mutex
+		running, peak int
+		ctx           = context.Background()
+		p             = newPool(size)
+	)
+	for i := 0; i < 10; i++ {
+		started := p.run(ctx, func() error {
+			mu.Lock()
+			running++
+			if running > peak {
+				peak = running
+			}
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		})
+		assert.True(t, started)
+	}
+	assert.NoError(t, p.wait(nil))
+	assert.Equal(t, size, peak)
+}
+
+func TestPoolStopsOnFailure(t *testing.T) {
+	failure := errors.New("action failed")
+	p := newPool(1)
+	assert.True(t, p.run(context.Background(), func() error { return failure }))
+	<-p.failed()
+	assert.False(t, p.run(context.Background(), func() error { return nil }))
+	assert.Equal(t, failure, p.wait(context.Canceled))
+}
+
+func TestPoolStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := newPool(1)
+	release := make(chan struct{})
+	assert.True(t, p.run(ctx, func() error {
+		<-release
+		return nil
+	}))
+	cancel()
+	assert.False(t, p.run(ctx, func() error { return nil }))
+	close(release)
+	assert.Equal(t, context.Canceled, p.wait(ctx.Err()))
+}
+
+func TestWorkflowState(t *testing.T) {
+	s := newWorkflowState()
+	assert.True(t, s.acquire("wf1"))
+	assert.False(t, s.acquire("wf1"))
+	assert.True(t, s.acquire("wf2"))
+	s.release("wf1")
+	assert.True(t, s.acquire("wf1"))
+
+	_, ok := s.checksum("wf1")
+	assert.False(t, ok)
+	s.setChecksum("wf1", "sum")
+	sum, ok := s.checksum("wf1")
+	assert.True(t, ok)
+	assert.Equal(t, "sum", sum)
+}
+
+func TestCurrentActions(t *testing.T) {
+	var c currentActions
+	wfID, action := c.last()
+	assert.Empty(t, wfID)
+	assert.Empty(t, action)
+
+	c.start("wf1", "disk-wipe")
+	c.start("wf2", "disk-partition")
+	wfID, action = c.last()
+	assert.Equal(t, "wf2", wfID)
+	assert.Equal(t, "disk-partition", action)
+
+	c.finish("wf2")
+	wfID, action = c.last()
+	assert.Equal(t, "wf1", wfID)
+	assert.Equal(t, "disk-wipe", action)
+}
@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	pb "github.com/tinkerbell/tink/protos/workflow"
+)
+
+const (
+	bootIDFile  = "/proc/sys/kernel/random/boot_id"
+	progressDir = "progress"
+)
+
+// actionProgress is the local record of the action a worker is executing,
+// persisted so that it survives a restart of the worker or a reboot
+type actionProgress struct {
+	ActionName  string    `json:"actionName"`
+	TaskName    string    `json:"taskName"`
+	ActionIndex int       `json:"actionIndex"`
+	BootID      string    `json:"bootID"`
+	StartedAt   time.Time `json:"startedAt"`
+}
+
+// readBootID returns the id the kernel generated for the current boot
+func readBootID() (string, error) {
+	id, err := ioutil.ReadFile(bootIDFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(id)), nil
+}
+
+func (w *Worker) progressFile(wfID string) string {
+	return filepath.Join(w.dataDir, progressDir, wfID)
+}
+
+func (w *Worker) writeProgress(wfID string, p actionProgress) error {
+	if err := os.MkdirAll(filepath.Join(w.dataDir, progressDir), os.FileMode(0755)); err != nil {
+		return err
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(w.progressFile(wfID), data, 0644)
+}
+
+func (w *Worker) readProgress(wfID string) (*actionProgress, error) {
+	data, err := ioutil.ReadFile(w.progressFile(wfID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	p := &actionProgress{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (w *Worker) removeProgress(wfID string) error {
+	err := os.Remove(w.progressFile(wfID))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// removeStaleProgress removes the progress records written before the
+// current boot, the server takes care of the actions they refer to when
+// the worker registers
+func (w *Worker) removeStaleProgress(bootID string) error {
+	files, err := ioutil.ReadDir(filepath.Join(w.dataDir, progressDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, f := range files {
+		p, err := w.readProgress(f.Name())
+		if err != nil {
+			return err
+		}
+		if p != nil && p.BootID != bootID {
+			if err := w.removeProgress(f.Name()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// awaitingReboot checks if the given reboot action has already been executed
+// during the current boot, in which case the worker must wait for the reboot
+// rather than execute it again
+func (w *Worker) awaitingReboot(wfID string, action *pb.WorkflowAction, bootID string) (bool, error) {
+	p, err := w.readProgress(wfID)
+	if err != nil || p == nil {
+		return false, err
+	}
+	return p.ActionName == action.GetName() && p.TaskName == action.GetTaskName() && p.BootID == bootID, nil
+}
@@ -0,0 +1,90 @@
+package worker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+)
+
+// registryConn are the connection details for accessing a Docker registry
+type registryConn struct {
+	registry,
+	user,
+	pwd string
+	output  io.Writer
+	metrics *workerMetrics
+}
+
+// newClient uses the registryConn to create a new Docker Client
+func (r *registryConn) newClient() (*client.Client, error) {
+	c, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+
+	if err != nil {
+		return nil, errors.Wrap(err, "DOCKER CLIENT")
+	}
+
+	return c, nil
+}
+
+// pullImage outputs the contents of the requested image (relative to the
+// registry). Without registry, the image is only pulled if it is not present
+// locally, so that images built on the machine can be used as they are.
+func (r *registryConn) pullImage(ctx context.Context, cli *client.Client, image string) error {
+	if r.registry == "" {
+		if _, _, err := cli.ImageInspectWithRaw(ctx, image); err == nil {
+			return nil
+		}
+	}
+	authConfig := types.AuthConfig{
+		Username:      r.user,
+		Password:      r.pwd,
+		ServerAddress: r.registry,
+	}
+	encodedJSON, err := json.Marshal(authConfig)
+	if err != nil {
+		return errors.Wrap(err, "DOCKER AUTH")
+	}
+	authStr := base64.URLEncoding.EncodeToString(encodedJSON)
+
+	start := time.Now()
+	n, err := r.pull(ctx, cli, image, authStr)
+	outcome := outcomeSuccess
+	if err != nil {
+		outcome = outcomeFailed
+	}
+	r.metrics.pullDuration.WithLabelValues(image, outcome).Observe(time.Since(start).Seconds())
+	r.metrics.pullBytes.WithLabelValues(image).Add(float64(n))
+	return err
+}
+
+// pull pulls an image, copying the progress messages to the output, and
+// returns the number of bytes downloaded
+func (r *registryConn) pull(ctx context.Context, cli *client.Client, image, auth string) (int64, error) {
+	out, err := cli.ImagePull(ctx, imageRef(r.registry, image), types.ImagePullOptions{RegistryAuth: auth})
+	if err != nil {
+		return 0, errors.Wrap(err, "DOCKER PULL")
+	}
+	defer out.Close()
+	tee := io.TeeReader(out, r.output)
+	n, err := countPullBytes(tee)
+	if err != nil {
+		// not a progress stream, still forward it as it is
+		_, err = io.Copy(ioutil.Discard, tee)
+	}
+	return n, err
+}
+
+// imageRef returns the reference of an action image in the registry
+func imageRef(registry, image string) string {
+	if registry == "" {
+		return image
+	}
+	return registry + "/" + image
+}
@@ -0,0 +1,121 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/packethost/pkg/log"
+	"github.com/pkg/errors"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+	wflow "github.com/tinkerbell/tink/workflow"
+)
+
+const (
+	// actionProgressFile is the file, in the workflow directory mounted at
+	// /workflow, an action writes its progress to. Each line is a
+	// percentage optionally followed by a message, e.g. "42 writing image",
+	// the last line being the current progress.
+	actionProgressFile = "progress"
+
+	defaultProgressInterval = 2 * time.Second
+	maxProgressMessage      = 200
+
+	errReportProgress = "failed to report action progress"
+)
+
+// parseActionProgress returns the last progress written by an action
+func parseActionProgress(data []byte) (int32, string, bool) {
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	line := strings.TrimSpace(string(lines[len(lines)-1]))
+	if line == "" {
+		return 0, "", false
+	}
+	fields := strings.SplitN(line, " ", 2)
+	perc, err := strconv.Atoi(strings.TrimSuffix(fields[0], "%"))
+	if err != nil {
+		return 0, "", false
+	}
+	if perc < 0 {
+		perc = 0
+	} else if perc > 100 {
+		perc = 100
+	}
+	var msg string
+	if len(fields) == 2 {
+		msg = strings.ToValidUTF8(strings.TrimSpace(fields[1]), "")
+	}
+	if len(msg) > maxProgressMessage {
+		n := maxProgressMessage
+		for !utf8.RuneStart(msg[n]) {
+			n--
+		}
+		msg = msg[:n]
+	}
+	return int32(perc), msg, true
+}
+
+// watchActionProgress forwards the progress written by an action to the
+// server, as running status updates, until the returned function is called.
+// That function returns once no more update can be sent, so that the final
+// status of the action is always reported last.
+func (w *Worker) watchActionProgress(ctx context.Context, wfID string, action *pb.WorkflowAction, l log.Logger) func() {
+	path := filepath.Join(w.workflowDir(wfID), actionProgressFile)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		l.Error(err)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(w.progressInterval)
+		defer ticker.Stop()
+
+		start := time.Now()
+		var last []byte
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil || bytes.Equal(data, last) {
+				continue
+			}
+			last = data
+			perc, msg, ok := parseActionProgress(data)
+			if !ok {
+				continue
+			}
+			msg = wflow.RedactSecrets(msg, action.GetSecrets())
+			l.With("progress", perc, "message", msg).Info("action progress")
+			_, err = w.client.ReportActionStatus(ctx, &pb.WorkflowActionStatus{
+				WorkflowId:   wfID,
+				TaskName:     action.GetTaskName(),
+				ActionName:   action.GetName(),
+				ActionStatus: pb.State_STATE_RUNNING,
+				Seconds:      int64(time.Since(start).Seconds()),
+				Message:      msg,
+				WorkerId:     action.GetWorkerId(),
+				Progress:     perc,
+			})
+			if err != nil && ctx.Err() == nil {
+				l.Error(errors.Wrap(err, errReportProgress))
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
@@ -0,0 +1,90 @@
+package worker
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+	"google.golang.org/grpc"
+)
+
+func TestParseActionProgress(t *testing.T) {
+	testCases := map[string]struct {
+		data     string
+		progress int32
+		message  string
+		ok       bool
+	}{
+		"percentage":        {data: "42\n", progress: 42, ok: true},
+		"percent sign":      {data: "42%", progress: 42, ok: true},
+		"with message":      {data: "42 writing image\n", progress: 42, message: "writing image", ok: true},
+		"last line":         {data: "10 wiping\n80 writing image\n\n", progress: 80, message: "writing image", ok: true},
+		"out of range":      {data: "150 done", progress: 100, message: "done", ok: true},
+		"empty":             {data: "\n"},
+		"not a percentage":  {data: "writing image"},
+		"truncated message": {data: "1 " + strings.Repeat("é", 150), progress: 1, message: strings.Repeat("é", 100), ok: true},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			progress, message, ok := parseActionProgress([]byte(tc.data))
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.progress, progress)
+			assert.Equal(t, tc.message, message)
+		})
+	}
+}
+
+type progressClient struct {
+	pb.WorkflowServiceClient
+
+	mu      sync.Mutex
+	reports []*pb.WorkflowActionStatus
+}
+
+func (c *progressClient) ReportActionStatus(ctx context.Context, in *pb.WorkflowActionStatus, opts ...grpc.CallOption) (*pb.Empty, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reports = append(c.reports, in)
+	return &pb.Empty{}, nil
+}
+
+func (c *progressClient) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.reports)
+}
+
+func TestWatchActionProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tink-worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const wfID = "ce2e62ed-826f-4485-a39f-a82bb74338e2"
+	c := &progressClient{}
+	w := &Worker{client: c, dataDir: dir, progressInterval: 10 * time.Millisecond, logger: testLogger(t)}
+	assert.NoError(t, w.initWorkflowDir(wfID))
+	path := filepath.Join(w.workflowDir(wfID), actionProgressFile)
+	// left over by a previous action
+	assert.NoError(t, ioutil.WriteFile(path, []byte("100 done"), 0644))
+
+	action := &pb.WorkflowAction{Name: "stream-image", TaskName: "provision", WorkerId: "worker", Secrets: []string{"s3cr3t"}}
+	stop := w.watchActionProgress(context.Background(), wfID, action, w.logger)
+	assert.NoError(t, ioutil.WriteFile(path, []byte("42 writing image with s3cr3t\n"), 0644))
+	assert.Eventually(t, func() bool { return c.count() == 1 }, time.Second, 10*time.Millisecond)
+	stop()
+
+	report := c.reports[0]
+	assert.Equal(t, pb.State_STATE_RUNNING, report.GetActionStatus())
+	assert.Equal(t, int32(42), report.GetProgress())
+	assert.Equal(t, "writing image with ******", report.GetMessage())
+	assert.Equal(t, "stream-image", report.GetActionName())
+}
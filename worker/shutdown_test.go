@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+	"google.golang.org/grpc"
+)
+
+// drainClient serves a single workflow with one action assigned to the worker
+type drainClient struct {
+	progressClient
+}
+
+func (c *drainClient) RegisterWorker(ctx context.Context, in *pb.RegisterWorkerRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	return &pb.Empty{}, nil
+}
+
+func (c *drainClient) GetWorkflowContexts(ctx context.Context, in *pb.WorkflowContextRequest, opts ...grpc.CallOption) (pb.WorkflowService_GetWorkflowContextsClient, error) {
+	return &contextStream{}, nil
+}
+
+func (c *drainClient) GetWorkflowActions(ctx context.Context, in *pb.WorkflowActionsRequest, opts ...grpc.CallOption) (*pb.WorkflowActionList, error) {
+	return &pb.WorkflowActionList{ActionList: []*pb.WorkflowAction{
+		{Name: "disk-wipe", TaskName: "provision", WorkerId: "worker"},
+	}}, nil
+}
+
+type contextStream struct {
+	grpc.ClientStream
+}
+
+func (s *contextStream) Recv() (*pb.WorkflowContext, error) {
+	return nil, io.EOF
+}
+
+func testWorker(t *testing.T, c pb.WorkflowServiceClient) *Worker {
+	dir, err := ioutil.TempDir("", "tink-worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Worker{
+		id:            "worker",
+		client:        c,
+		logger:        testLogger(t),
+		dataDir:       dir,
+		retryInterval: 10 * time.Millisecond,
+		parallelism:   1,
+		state:         newWorkflowState(),
+		draining:      make(chan struct{}),
+		metrics:       newWorkerMetrics(),
+	}
+}
+
+func TestShutdownNotRunning(t *testing.T) {
+	w := testWorker(t, &drainClient{})
+	defer os.RemoveAll(w.dataDir)
+
+	assert.NoError(t, w.Shutdown(context.Background()))
+	assert.True(t, w.isDraining())
+	// shutting down twice is harmless
+	assert.NoError(t, w.Shutdown(context.Background()))
+}
+
+func TestShutdownDrainsRunningWorker(t *testing.T) {
+	c := &drainClient{}
+	w := testWorker(t, c)
+	defer os.RemoveAll(w.dataDir)
+
+	errCh := make(chan error)
+	go func() { errCh <- w.Run(context.Background()) }()
+	assert.Eventually(t, func() bool {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return w.done != nil
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, w.Shutdown(ctx))
+	assert.NoError(t, <-errCh)
+}
+
+func TestProcessWorkflowDraining(t *testing.T) {
+	c := &drainClient{}
+	w := testWorker(t, c)
+	defer os.RemoveAll(w.dataDir)
+	close(w.draining)
+
+	err := w.processWorkflow(context.Background(), &pb.WorkflowContext{WorkflowId: "wf", TotalNumberOfActions: 1}, "boot")
+	assert.NoError(t, err)
+	// the action is neither started nor reported
+	assert.Equal(t, 0, c.count())
+}
@@ -0,0 +1,51 @@
+package worker
+
+import "sync"
+
+// workflowState holds what the worker knows about the workflows it executes,
+// shared by the goroutines processing them
+type workflowState struct {
+	mu      sync.Mutex
+	running map[string]bool
+	dataSHA map[string]string
+}
+
+func newWorkflowState() *workflowState {
+	return &workflowState{
+		running: map[string]bool{},
+		dataSHA: map[string]string{},
+	}
+}
+
+// acquire marks a workflow as being processed, it returns false if it
+// already is
+func (s *workflowState) acquire(wfID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[wfID] {
+		return false
+	}
+	s.running[wfID] = true
+	return true
+}
+
+// release marks a workflow as no longer being processed
+func (s *workflowState) release(wfID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, wfID)
+}
+
+// checksum returns the checksum of the last known data of a workflow
+func (s *workflowState) checksum(wfID string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sum, ok := s.dataSHA[wfID]
+	return sum, ok
+}
+
+func (s *workflowState) setChecksum(wfID, sum string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dataSHA[wfID] = sum
+}
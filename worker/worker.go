@@ -0,0 +1,949 @@
+// Package worker implements the tink worker, which executes the actions of
+// the workflows assigned to a machine. It can be embedded in other programs,
+// cmd/tink-worker being a thin wrapper around it.
+package worker
+
+import (
+	"bufio"
+	"context"
+	sha "crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/packethost/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+	"github.com/tinkerbell/tink/tracing"
+	wflow "github.com/tinkerbell/tink/workflow"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	dataFile      = "data"
+	outputsDir    = "outputs"
+	maxOutputSize = 4096
+
+	// DefaultDataDir is the directory where the worker keeps the data of the
+	// workflows it executes, unless configured otherwise
+	DefaultDataDir = "/worker"
+
+	errGetWfContext       = "failed to get workflow context"
+	errGetWfActions       = "failed to get actions for workflow"
+	errReportActionStatus = "failed to report action status"
+	errUpdateWfData       = "failed to update workflow data"
+	errReadBootID         = "failed to read boot id"
+	errRegisterWorker     = "failed to register worker"
+
+	msgTurn           = "it's turn for a different worker: %s"
+	msgAwaitingReboot = "action executed, waiting for reboot"
+	msgDraining       = "worker shutting down, not starting new actions"
+	msgInterrupted    = "action interrupted by the shutdown of the worker"
+	msgPaused         = "workflow paused, not starting new actions"
+	msgNotStarted     = "action not started"
+
+	msgAwaitingApproval = "waiting for the approval of the workflow"
+
+	// cleanupTimeout bounds the time spent removing the containers and
+	// reporting the interrupted actions once the worker is shutting down
+	cleanupTimeout = 30 * time.Second
+)
+
+// WorkflowMetadata is the metadata related to workflow data
+type WorkflowMetadata struct {
+	WorkerID  string    `json:"workerID"`
+	Action    string    `json:"actionName"`
+	Task      string    `json:"taskName"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	SHA       string    `json:"sha256"`
+}
+
+// Options are the settings of a Worker
+type Options struct {
+	// ID is the id of the worker, as referenced in the workflows
+	ID string
+	// Client is the connection to the workflow service of tink-server
+	Client pb.WorkflowServiceClient
+	// Logger receives the logs of the worker
+	Logger log.Logger
+
+	// Registry is the Docker registry the action images are pulled from,
+	// along with the credentials to access it
+	Registry         string
+	RegistryUsername string
+	RegistryPassword string
+
+	// DataDir is the directory holding the data of the workflows,
+	// DefaultDataDir if empty
+	DataDir string
+	// MaxFileSize is the maximum size in bytes of the workflow data file
+	MaxFileSize int64
+	// Retries is the number of attempts made to report an action status
+	Retries int
+	// RetryInterval is the time to wait between two attempts to report an
+	// action status, as well as between two polls for workflows
+	RetryInterval time.Duration
+	// HeartbeatInterval is the interval between two heartbeats sent to
+	// tink-server, zero disables the heartbeats
+	HeartbeatInterval time.Duration
+	// Parallelism is the maximum number of workflows executed concurrently,
+	// one if zero. Actions of a same workflow are always executed in order.
+	Parallelism int
+
+	// Output receives the output of the image pulls and action containers,
+	// os.Stdout if nil
+	Output io.Writer
+	// Hooks are notified as the actions are executed
+	Hooks Hooks
+	// Registerer, when set, is where the Prometheus metrics of the worker
+	// are registered, e.g. prometheus.DefaultRegisterer
+	Registerer prometheus.Registerer
+}
+
+// Worker details provide all the context needed to run a
+type Worker struct {
+	id             string
+	client         pb.WorkflowServiceClient
+	regConn        *registryConn
+	registryClient *client.Client
+	logger         log.Logger
+	registry       string
+	dataDir        string
+	retries        int
+	retryInterval  time.Duration
+	maxSize        int64
+	output         io.Writer
+	hooks          Hooks
+	metrics        *workerMetrics
+
+	heartbeatInterval time.Duration
+	current           currentActions
+	progressInterval  time.Duration
+
+	parallelism int
+	state       *workflowState
+
+	// draining is closed when the worker is asked to shut down
+	draining  chan struct{}
+	drainOnce sync.Once
+	// interrupt cancels the actions of a running worker, done is closed
+	// once it stopped
+	mu        sync.Mutex
+	interrupt context.CancelFunc
+	done      chan struct{}
+}
+
+// New creates a new Worker, creating a new Docker client
+func New(opts Options) (*Worker, error) {
+	if opts.ID == "" {
+		return nil, ErrMissingWorkerID
+	}
+	if opts.Client == nil {
+		return nil, ErrMissingClient
+	}
+	if opts.Registry == "" {
+		return nil, ErrMissingRegistry
+	}
+	return newWorker(opts)
+}
+
+func newWorker(opts Options) (*Worker, error) {
+	if opts.DataDir == "" {
+		opts.DataDir = DefaultDataDir
+	}
+	if opts.Output == nil {
+		opts.Output = os.Stdout
+	}
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = 1
+	}
+
+	metrics := newWorkerMetrics()
+	if opts.Registerer != nil {
+		if err := metrics.register(opts.Registerer); err != nil {
+			return nil, err
+		}
+	}
+
+	regConn := &registryConn{
+		registry: opts.Registry,
+		user:     opts.RegistryUsername,
+		pwd:      opts.RegistryPassword,
+		output:   opts.Output,
+		metrics:  metrics,
+	}
+	registryClient, err := regConn.newClient()
+	if err != nil {
+		return nil, err
+	}
+	return &Worker{
+		id:             opts.ID,
+		client:         opts.Client,
+		regConn:        regConn,
+		registryClient: registryClient,
+		logger:         opts.Logger,
+		registry:       opts.Registry,
+		dataDir:        opts.DataDir,
+		retries:        opts.Retries,
+		retryInterval:  opts.RetryInterval,
+		maxSize:        opts.MaxFileSize,
+		output:         opts.Output,
+		hooks:          opts.Hooks,
+		metrics:        metrics,
+
+		heartbeatInterval: opts.HeartbeatInterval,
+		progressInterval:  defaultProgressInterval,
+
+		parallelism: opts.Parallelism,
+		state:       newWorkflowState(),
+
+		draining: make(chan struct{}),
+	}, nil
+}
+
+// captureLogs copies the output of a container to the output of the worker,
+// redacting the values of the secrets of the action
+func (w *Worker) captureLogs(ctx context.Context, id string, secrets []string) {
+	reader, err := w.registryClient.ContainerLogs(ctx, id, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+		Timestamps: false,
+	})
+	if err != nil {
+		w.logger.With("containerID", id).Error(errors.Wrap(err, "DOCKER LOGS"))
+		return
+	}
+	defer reader.Close()
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		fmt.Fprintln(w.output, wflow.RedactSecrets(scanner.Text(), secrets))
+	}
+}
+
+func (w *Worker) execute(ctx context.Context, wfID string, action *pb.WorkflowAction) (state pb.State, err error) {
+	l := w.logger.With("workflowID", wfID, "workerID", action.GetWorkerId(), "actionName", action.GetName(), "actionImage", action.GetImage())
+
+	ctx, span := tracing.Tracer().Start(ctx, "execute", trace.WithAttributes(
+		tracing.WorkflowIDKey.String(wfID),
+		label.String("tink.action.name", action.GetName()),
+		label.String("tink.action.image", action.GetImage()),
+	))
+	defer func() {
+		span.SetAttributes(label.String("tink.action.state", state.String()))
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		} else if state != pb.State_STATE_SUCCESS {
+			span.SetStatus(codes.Error, state.String())
+		}
+		span.End()
+	}()
+
+	cli := w.registryClient
+	if err := w.regConn.pullImage(ctx, cli, action.GetImage()); err != nil {
+		return pb.State_STATE_RUNNING, errors.Wrap(err, "DOCKER PULL")
+	}
+	span.AddEvent(ctx, "image pulled")
+	id, err := w.createContainer(ctx, action.Command, wfID, action)
+	if err != nil {
+		return pb.State_STATE_RUNNING, errors.Wrap(err, "DOCKER CREATE")
+	}
+	l.With("containerID", id, "command", action.GetOnTimeout()).Info("container created")
+	span.AddEvent(ctx, "container created", label.String("tink.container.id", id))
+
+	var timeCtx context.Context
+	var cancel context.CancelFunc
+
+	if action.Timeout > 0 {
+		timeCtx, cancel = context.WithTimeout(ctx, time.Duration(action.Timeout)*time.Second)
+	} else {
+		timeCtx, cancel = context.WithTimeout(ctx, 1*time.Hour)
+	}
+	defer cancel()
+
+	err = startContainer(timeCtx, l, cli, id)
+	if err != nil {
+		return pb.State_STATE_RUNNING, errors.Wrap(err, "DOCKER RUN")
+	}
+
+	failedActionStatus := make(chan pb.State)
+
+	// capturing logs of action container in a go-routine
+	go w.captureLogs(ctx, id, action.GetSecrets())
+
+	status, waitErr := waitContainer(timeCtx, cli, id)
+	defer cleanupContainer(l, cli, id)
+
+	if waitErr != nil {
+		return status, errors.Wrap(waitErr, "DOCKER_WAIT")
+	}
+
+	l.With("status", status.String()).Info("container removed")
+	if status != pb.State_STATE_SUCCESS {
+		if status == pb.State_STATE_TIMEOUT && action.OnTimeout != nil {
+			id, err = w.createContainer(ctx, action.OnTimeout, wfID, action)
+			if err != nil {
+				l.Error(errors.Wrap(err, errCreateContainer))
+			}
+			l.With("containerID", id, "status", status.String(), "command", action.GetOnTimeout()).Info("container created")
+			failedActionStatus := make(chan pb.State)
+			go w.captureLogs(ctx, id, action.GetSecrets())
+			go waitFailedContainer(ctx, l, cli, id, failedActionStatus)
+			err = startContainer(ctx, l, cli, id)
+			if err != nil {
+				l.Error(errors.Wrap(err, errFailedToRunCmd))
+			}
+			defer cleanupContainer(l, cli, id)
+			onTimeoutStatus := <-failedActionStatus
+			l.With("status", onTimeoutStatus).Info("action timeout")
+		} else {
+			if action.OnFailure != nil {
+				id, err = w.createContainer(ctx, action.OnFailure, wfID, action)
+				if err != nil {
+					l.Error(errors.Wrap(err, errFailedToRunCmd))
+				}
+				l.With("containerID", id, "actionStatus", status.String(), "command", action.GetOnFailure()).Info("container created")
+				go w.captureLogs(ctx, id, action.GetSecrets())
+				go waitFailedContainer(ctx, l, cli, id, failedActionStatus)
+				err = startContainer(ctx, l, cli, id)
+				if err != nil {
+					l.Error(errors.Wrap(err, errFailedToRunCmd))
+				}
+				defer cleanupContainer(l, cli, id)
+				onFailureStatus := <-failedActionStatus
+				l.With("status", onFailureStatus).Info("action failed")
+			}
+		}
+		l.Info(infoWaitFinished)
+		if err != nil {
+			l.Error(errors.Wrap(err, errFailedToWait))
+		}
+	}
+	l.With("status", status).Info("action container exited")
+	return status, nil
+}
+
+// Run registers the worker with tink-server and executes the actions assigned
+// to it, until the context is done, the worker is shut down or an error
+// occurs. The error returned is either the error of the context, a
+// *ServerError or a *DataError. A failed action only fails its workflow,
+// the other workflows go on. It is nil once the worker has been shut down
+// without interrupting any action.
+func (w *Worker) Run(ctx context.Context) error {
+	l := w.logger.With("workerID", w.id)
+
+	ctx, interrupt := context.WithCancel(ctx)
+	defer interrupt()
+	done := make(chan struct{})
+	defer close(done)
+	w.mu.Lock()
+	w.interrupt, w.done = interrupt, done
+	w.mu.Unlock()
+
+	bootID, err := readBootID()
+	if err != nil {
+		return errors.Wrap(err, errReadBootID)
+	}
+	_, err = w.client.RegisterWorker(ctx, &pb.RegisterWorkerRequest{WorkerId: w.id, BootId: bootID})
+	if err != nil {
+		return newServerError(errRegisterWorker, err)
+	}
+	l.With("bootID", bootID).Info("worker registered")
+	if err := w.removeStaleProgress(bootID); err != nil {
+		l.Error(err)
+	}
+
+	hbCtx, stopHeartbeats := context.WithCancel(ctx)
+	defer stopHeartbeats()
+	go w.sendHeartbeats(hbCtx)
+
+	p := newPool(w.parallelism)
+	for {
+		pollStart := time.Now()
+		res, err := w.client.GetWorkflowContexts(ctx, &pb.WorkflowContextRequest{WorkerId: w.id})
+		if err != nil {
+			if ctx.Err() != nil {
+				return p.wait(ctx.Err())
+			}
+			return p.wait(newServerError(errGetWfContext, err))
+		}
+		for wfContext, err := res.Recv(); err == nil && wfContext != nil; wfContext, err = res.Recv() {
+			if w.isDraining() {
+				break
+			}
+			wfContext := wfContext
+			wfID := wfContext.GetWorkflowId()
+			// the workflow is still being processed since a previous poll
+			if !w.state.acquire(wfID) {
+				continue
+			}
+			started := p.run(ctx, func() error {
+				defer w.state.release(wfID)
+				err := w.processWorkflow(ctx, wfContext, bootID)
+				if err != nil && ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return w.workflowError(err)
+			})
+			if !started {
+				w.state.release(wfID)
+				break
+			}
+		}
+		w.metrics.pollDuration.Observe(time.Since(pollStart).Seconds())
+		if err := p.err(); err != nil {
+			return p.wait(err)
+		}
+		// sleep before asking for new workflows
+		select {
+		case <-ctx.Done():
+			return p.wait(ctx.Err())
+		case <-p.failed():
+			return p.wait(p.err())
+		case <-w.draining:
+			l.Info(msgDraining)
+			return p.wait(nil)
+		case <-time.After(w.retryInterval):
+		}
+	}
+}
+
+// workflowError returns the error of a workflow which stops the worker. The
+// *ActionError of a failed action is only logged, the failure is already
+// reported to tink-server and to the hooks.
+func (w *Worker) workflowError(err error) error {
+	if actionErr, ok := err.(*ActionError); ok {
+		w.logger.With("workerID", w.id, "workflowID", actionErr.WorkflowID).Info(actionErr.Error())
+		return nil
+	}
+	return err
+}
+
+// processWorkflow executes the actions of a workflow, as long as it is the
+// turn of the worker
+func (w *Worker) processWorkflow(ctx context.Context, wfContext *pb.WorkflowContext, bootID string) error {
+	wfID := wfContext.GetWorkflowId()
+	l := w.logger.With("workerID", w.id, "workflowID", wfID)
+	actions, err := w.client.GetWorkflowActions(ctx, &pb.WorkflowActionsRequest{WorkflowId: wfID, WorkerId: w.id})
+	if err != nil {
+		return newServerError(errGetWfActions, err)
+	}
+
+	if wfContext.GetPaused() {
+		l.Info(msgPaused)
+		return nil
+	}
+
+	turn := false
+	actionIndex := 0
+	var nextAction *pb.WorkflowAction
+	if wfContext.GetCurrentAction() == "" {
+		if actions.GetActionList()[0].GetWorkerId() == w.id {
+			actionIndex = 0
+			turn = true
+		}
+	} else {
+		switch wfContext.GetCurrentActionState() {
+		case pb.State_STATE_WAITING:
+			return nil
+		case pb.State_STATE_SUCCESS:
+			if isLastAction(wfContext, actions) {
+				return nil
+			}
+			nextAction = actions.GetActionList()[wfContext.GetCurrentActionIndex()+1]
+			actionIndex = int(wfContext.GetCurrentActionIndex()) + 1
+		case pb.State_STATE_FAILED:
+			return nil
+		case pb.State_STATE_TIMEOUT:
+			return nil
+		default:
+			nextAction = actions.GetActionList()[wfContext.GetCurrentActionIndex()]
+			actionIndex = int(wfContext.GetCurrentActionIndex())
+			// a pending reboot action was retried, it did not start yet
+			if nextAction.GetReboot() && nextAction.GetWorkerId() == w.id && wfContext.GetCurrentActionState() == pb.State_STATE_RUNNING {
+				waiting, err := w.awaitingReboot(wfID, nextAction, bootID)
+				if err != nil {
+					l.Error(err)
+				}
+				if waiting {
+					l.With("actionName", nextAction.GetName()).Info(msgAwaitingReboot)
+					return nil
+				}
+			}
+		}
+		l := l.With(
+			"currentWorker", wfContext.GetCurrentWorker(),
+			"currentTask", wfContext.GetCurrentTask(),
+			"currentAction", wfContext.GetCurrentAction(),
+			"currentActionIndex", strconv.FormatInt(wfContext.GetCurrentActionIndex(), 10),
+			"currentActionState", wfContext.GetCurrentActionState(),
+			"totalNumberOfActions", wfContext.GetTotalNumberOfActions(),
+		)
+		l.Info("current context")
+		if nextAction.GetWorkerId() == w.id {
+			turn = true
+		}
+	}
+	if !turn {
+		return nil
+	}
+
+	// the actions executed by the worker are part of the trace of the workflow
+	ctx, span := tracing.StartWorkflowSpan(ctx, wfID, "processWorkflow", trace.WithAttributes(label.String("tink.worker.id", w.id)))
+	defer span.End()
+
+	l.With("actionName", actions.GetActionList()[actionIndex].GetName(),
+		"taskName", actions.GetActionList()[actionIndex].GetTaskName(),
+	).Info("starting with action")
+	if err := w.initWorkflowDir(wfID); err != nil {
+		return &DataError{WorkflowID: wfID, Err: err}
+	}
+
+	state := wfContext.GetCurrentActionState()
+	for {
+		action := actions.GetActionList()[actionIndex]
+		l := l.With("actionName", action.GetName(),
+			"taskName", action.GetTaskName(),
+		)
+		// the next actions are left to the next run of the worker
+		if w.isDraining() {
+			l.Info(msgDraining)
+			return nil
+		}
+		// the server completes the approval actions, once approved
+		if action.GetType() == wflow.ActionTypeApproval {
+			l.Info(msgAwaitingApproval)
+			return nil
+		}
+		if state != pb.State_STATE_RUNNING {
+			actionStatus := &pb.WorkflowActionStatus{
+				WorkflowId:   wfID,
+				TaskName:     action.GetTaskName(),
+				ActionName:   action.GetName(),
+				ActionStatus: pb.State_STATE_RUNNING,
+				Seconds:      0,
+				Message:      "Started execution",
+				WorkerId:     action.GetWorkerId(),
+			}
+
+			err := w.reportActionStatus(ctx, actionStatus)
+			if isRefused(err) {
+				l.With("reason", status.Convert(err).Message()).Info(msgNotStarted)
+				return nil
+			}
+			if err != nil {
+				return newServerError(errReportActionStatus, err)
+			}
+			l.With("duration", strconv.FormatInt(actionStatus.Seconds, 10)).Info("sent action status")
+		}
+
+		// get workflow data
+		if err := w.getWorkflowData(ctx, l, wfID); err != nil {
+			return err
+		}
+
+		// start every action with empty outputs and artifacts directories
+		if err := w.resetOutputs(wfID); err != nil {
+			l.Error(err)
+		}
+		if err := w.resetArtifacts(wfID); err != nil {
+			l.Error(err)
+		}
+
+		err := w.writeProgress(wfID, actionProgress{
+			ActionName:  action.GetName(),
+			TaskName:    action.GetTaskName(),
+			ActionIndex: actionIndex,
+			BootID:      bootID,
+			StartedAt:   time.Now(),
+		})
+		if err != nil {
+			l.Error(err)
+		}
+
+		// start executing the action
+		w.current.start(wfID, action.GetName())
+		w.hooks.actionStarted(ActionEvent{
+			WorkflowID: wfID,
+			Action:     action,
+			State:      pb.State_STATE_RUNNING,
+		})
+		stopProgress := w.watchActionProgress(ctx, wfID, action, l)
+		start := time.Now()
+		status, err := w.execute(ctx, wfID, action)
+		elapsed := time.Since(start)
+		stopProgress()
+		w.current.finish(wfID)
+		w.metrics.actionDuration.WithLabelValues(action.GetImage(), actionOutcome(status, err, ctx.Err() != nil)).Observe(elapsed.Seconds())
+		if ctx.Err() != nil {
+			return w.reportInterrupted(wfID, action, elapsed, l)
+		}
+		w.uploadArtifacts(ctx, wfID, action, l)
+
+		actionStatus := &pb.WorkflowActionStatus{
+			WorkflowId: wfID,
+			TaskName:   action.GetTaskName(),
+			ActionName: action.GetName(),
+			Seconds:    int64(elapsed.Seconds()),
+			WorkerId:   action.GetWorkerId(),
+		}
+
+		if err != nil || status != pb.State_STATE_SUCCESS {
+			if status == pb.State_STATE_TIMEOUT {
+				actionStatus.ActionStatus = pb.State_STATE_TIMEOUT
+			} else {
+				actionStatus.ActionStatus = pb.State_STATE_FAILED
+			}
+			l.With("actionStatus", actionStatus.ActionStatus.String()).Error(err)
+			w.hooks.actionFinished(ActionEvent{
+				WorkflowID: wfID,
+				Action:     action,
+				State:      actionStatus.ActionStatus,
+				Duration:   elapsed,
+				Err:        err,
+			})
+			if reportErr := w.reportActionStatus(ctx, actionStatus); reportErr != nil {
+				return newServerError(errReportActionStatus, reportErr)
+			}
+			if rmErr := w.removeProgress(wfID); rmErr != nil {
+				l.Error(rmErr)
+			}
+			return &ActionError{
+				WorkflowID: wfID,
+				TaskName:   action.GetTaskName(),
+				ActionName: action.GetName(),
+				State:      actionStatus.ActionStatus,
+				Err:        err,
+			}
+		}
+
+		w.hooks.actionFinished(ActionEvent{
+			WorkflowID: wfID,
+			Action:     action,
+			State:      pb.State_STATE_SUCCESS,
+			Duration:   elapsed,
+		})
+
+		if action.GetReboot() {
+			// the success of a reboot action is confirmed by the
+			// server when the worker registers again after the reboot,
+			// it has no outputs: the templates cannot reference them
+			l.Info(msgAwaitingReboot)
+			return nil
+		}
+
+		actionStatus.ActionStatus = pb.State_STATE_SUCCESS
+		actionStatus.Message = "finished execution successfully"
+		actionStatus.Progress = 100
+		actionStatus.Outputs = w.readOutputs(wfID, l)
+
+		err = w.reportActionStatus(ctx, actionStatus)
+		if err != nil {
+			return newServerError(errReportActionStatus, err)
+		}
+		l.Info("sent action status")
+		state = pb.State_STATE_SUCCESS
+		if err := w.removeProgress(wfID); err != nil {
+			l.Error(err)
+		}
+
+		// send workflow data, if updated
+		if err := w.updateWorkflowData(ctx, actionStatus); err != nil {
+			return err
+		}
+
+		if len(actions.GetActionList()) == actionIndex+1 {
+			l.Info("reached to end of workflow")
+			return nil
+		}
+		nextAction := actions.GetActionList()[actionIndex+1]
+		if nextAction.GetWorkerId() != w.id {
+			l.Debug(fmt.Sprintf(msgTurn, nextAction.GetWorkerId()))
+			return nil
+		}
+		actionIndex = actionIndex + 1
+		// refresh the actions so that the outputs reported so far
+		// are resolved in the environment of the next action
+		actions, err = w.client.GetWorkflowActions(ctx, &pb.WorkflowActionsRequest{WorkflowId: wfID, WorkerId: w.id})
+		if err != nil {
+			return newServerError(errGetWfActions, err)
+		}
+	}
+}
+
+// Shutdown gracefully stops a running worker. It stops starting new actions
+// and waits for the running ones to finish, then for Run to return. When ctx
+// is done first, the running actions are interrupted: their containers are
+// removed and they are reported as failed.
+func (w *Worker) Shutdown(ctx context.Context) error {
+	w.drainOnce.Do(func() { close(w.draining) })
+
+	w.mu.Lock()
+	interrupt, done := w.interrupt, w.done
+	w.mu.Unlock()
+	if done == nil {
+		return nil
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+	interrupt()
+	<-done
+	return ctx.Err()
+}
+
+func (w *Worker) isDraining() bool {
+	select {
+	case <-w.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// reportInterrupted reports an action interrupted as the worker shuts down
+// as failed, the context of the worker being done already
+func (w *Worker) reportInterrupted(wfID string, action *pb.WorkflowAction, elapsed time.Duration, l log.Logger) error {
+	l.Info(msgInterrupted)
+	w.hooks.actionFinished(ActionEvent{
+		WorkflowID: wfID,
+		Action:     action,
+		State:      pb.State_STATE_FAILED,
+		Duration:   elapsed,
+		Err:        context.Canceled,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+	err := w.reportActionStatus(ctx, &pb.WorkflowActionStatus{
+		WorkflowId:   wfID,
+		TaskName:     action.GetTaskName(),
+		ActionName:   action.GetName(),
+		ActionStatus: pb.State_STATE_FAILED,
+		Seconds:      int64(elapsed.Seconds()),
+		Message:      msgInterrupted,
+		WorkerId:     action.GetWorkerId(),
+	})
+	if err != nil {
+		return newServerError(errReportActionStatus, err)
+	}
+	if err := w.removeProgress(wfID); err != nil {
+		l.Error(err)
+	}
+	return context.Canceled
+}
+
+func isLastAction(wfContext *pb.WorkflowContext, actions *pb.WorkflowActionList) bool {
+	return int(wfContext.GetCurrentActionIndex()) == len(actions.GetActionList())-1
+}
+
+func (w *Worker) reportActionStatus(ctx context.Context, actionStatus *pb.WorkflowActionStatus) error {
+	l := w.logger.With("workflowID", actionStatus.GetWorkflowId(),
+		"workerID", actionStatus.GetWorkerId(),
+		"actionName", actionStatus.GetActionName(),
+		"taskName", actionStatus.GetTaskName(),
+	)
+	var err error
+	for r := 1; r <= w.retries; r++ {
+		_, err = w.client.ReportActionStatus(ctx, actionStatus)
+		if isRefused(err) {
+			return err
+		}
+		if err != nil {
+			l.Error(errors.Wrap(err, errReportActionStatus))
+			w.metrics.reportRetries.Inc()
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(w.retryInterval):
+			}
+			continue
+		}
+		return nil
+	}
+	return err
+}
+
+func (w *Worker) workflowDir(wfID string) string {
+	return filepath.Join(w.dataDir, wfID)
+}
+
+// initWorkflowDir creates the directory of a workflow along with an empty
+// data file, unless it already exists
+func (w *Worker) initWorkflowDir(wfID string) error {
+	wfDir := w.workflowDir(wfID)
+	if _, err := os.Stat(wfDir); !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.MkdirAll(wfDir, os.FileMode(0755)); err != nil {
+		return err
+	}
+	f, err := openDataFile(wfDir)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write([]byte("{}")); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (w *Worker) getWorkflowData(ctx context.Context, logger log.Logger, workflowID string) error {
+	l := logger.With("workflowID", workflowID,
+		"workerID", w.id,
+	)
+	res, err := w.client.GetWorkflowData(ctx, &pb.GetWorkflowDataRequest{WorkflowId: workflowID})
+	if err != nil {
+		l.Error(err)
+	}
+
+	if len(res.GetData()) != 0 {
+		f, err := openDataFile(w.workflowDir(workflowID))
+		if err != nil {
+			return &DataError{WorkflowID: workflowID, Err: err}
+		}
+		defer f.Close()
+
+		_, err = f.Write(res.GetData())
+		if err != nil {
+			l.Error(err)
+		}
+		h := sha.New()
+		w.state.setChecksum(workflowID, base64.StdEncoding.EncodeToString(h.Sum(res.Data)))
+	}
+	return nil
+}
+
+func (w *Worker) updateWorkflowData(ctx context.Context, actionStatus *pb.WorkflowActionStatus) error {
+	l := w.logger.With("workflowID", actionStatus.GetWorkflowId(),
+		"workerID", actionStatus.GetWorkerId(),
+		"actionName", actionStatus.GetActionName(),
+		"taskName", actionStatus.GetTaskName(),
+	)
+
+	f, err := openDataFile(w.workflowDir(actionStatus.GetWorkflowId()))
+	if err != nil {
+		return &DataError{WorkflowID: actionStatus.GetWorkflowId(), Err: err}
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		l.Error(err)
+	}
+
+	if isValidDataFile(f, w.maxSize, data, l) {
+		h := sha.New()
+		oldSHA, ok := w.state.checksum(actionStatus.GetWorkflowId())
+		if !ok {
+			checksum := base64.StdEncoding.EncodeToString(h.Sum(data))
+			w.state.setChecksum(actionStatus.GetWorkflowId(), checksum)
+			return w.sendUpdate(ctx, actionStatus, data, checksum)
+		}
+		newSHA := base64.StdEncoding.EncodeToString(h.Sum(data))
+		if !strings.EqualFold(oldSHA, newSHA) {
+			return w.sendUpdate(ctx, actionStatus, data, newSHA)
+		}
+	}
+	return nil
+}
+
+func (w *Worker) sendUpdate(ctx context.Context, st *pb.WorkflowActionStatus, data []byte, checksum string) error {
+	meta := WorkflowMetadata{
+		WorkerID:  st.GetWorkerId(),
+		Action:    st.GetActionName(),
+		Task:      st.GetTaskName(),
+		UpdatedAt: time.Now(),
+		SHA:       checksum,
+	}
+	metadata, err := json.Marshal(meta)
+	if err != nil {
+		return &DataError{WorkflowID: st.GetWorkflowId(), Err: err}
+	}
+
+	w.metrics.dataUploadBytes.Observe(float64(len(data)))
+	_, err = w.client.UpdateWorkflowData(ctx, &pb.UpdateWorkflowDataRequest{
+		WorkflowId: st.GetWorkflowId(),
+		Data:       data,
+		Metadata:   metadata,
+	})
+	if err != nil {
+		return newServerError(errUpdateWfData, err)
+	}
+	return nil
+}
+
+func openDataFile(wfDir string) (*os.File, error) {
+	return os.OpenFile(filepath.Join(wfDir, dataFile), os.O_RDWR|os.O_CREATE, 0644)
+}
+
+func (w *Worker) resetOutputs(wfID string) error {
+	dir := filepath.Join(w.workflowDir(wfID), outputsDir)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return os.MkdirAll(dir, os.FileMode(0755))
+}
+
+// readOutputs collects the outputs written by an action, one file per output
+func (w *Worker) readOutputs(wfID string, l log.Logger) map[string]string {
+	dir := filepath.Join(w.workflowDir(wfID), outputsDir)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		l.Error(err)
+		return nil
+	}
+
+	outputs := map[string]string{}
+	for _, f := range files {
+		if !f.Mode().IsRegular() || !wflow.IsValidOutputName(f.Name()) {
+			l.With("output", f.Name()).Info("ignoring invalid output")
+			continue
+		}
+		if f.Size() > maxOutputSize {
+			l.With("output", f.Name(), "size", f.Size()).Info("ignoring output exceeding the maximum size")
+			continue
+		}
+		value, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			l.Error(err)
+			continue
+		}
+		outputs[f.Name()] = strings.TrimSpace(string(value))
+	}
+	return outputs
+}
+
+func isValidDataFile(f *os.File, maxSize int64, data []byte, l log.Logger) bool {
+	var dataMap map[string]interface{}
+	err := json.Unmarshal(data, &dataMap)
+	if err != nil {
+		l.Error(err)
+		return false
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		l.Error(err)
+		return false
+	}
+
+	return stat.Size() <= maxSize
+}
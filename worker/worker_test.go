@@ -0,0 +1,174 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/packethost/pkg/log"
+	"github.com/stretchr/testify/assert"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+	wflow "github.com/tinkerbell/tink/workflow"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeClient struct {
+	pb.WorkflowServiceClient
+}
+
+func testLogger(t *testing.T) log.Logger {
+	l, err := log.Init("github.com/tinkerbell/tink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l.Package("worker")
+}
+
+func TestNew(t *testing.T) {
+	testCases := map[string]struct {
+		opts        Options
+		expectedErr error
+	}{
+		"missing worker id": {
+			opts:        Options{Client: fakeClient{}, Registry: "registry"},
+			expectedErr: ErrMissingWorkerID,
+		},
+		"missing client": {
+			opts:        Options{ID: "worker", Registry: "registry"},
+			expectedErr: ErrMissingClient,
+		},
+		"missing registry": {
+			opts:        Options{ID: "worker", Client: fakeClient{}},
+			expectedErr: ErrMissingRegistry,
+		},
+		"valid options": {
+			opts: Options{ID: "worker", Client: fakeClient{}, Registry: "registry"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			w, err := New(tc.opts)
+			if tc.expectedErr != nil {
+				assert.Equal(t, tc.expectedErr, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, DefaultDataDir, w.dataDir)
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	cause := status.Error(codes.Unavailable, "connection refused")
+
+	var serverErr *ServerError
+	err := error(newServerError(errReportActionStatus, cause))
+	assert.True(t, errors.As(err, &serverErr))
+	assert.Equal(t, codes.Unavailable, serverErr.Code)
+	assert.True(t, errors.Is(err, cause))
+
+	var actionErr *ActionError
+	err = &ActionError{WorkflowID: "wf", ActionName: "disk-wipe", State: pb.State_STATE_TIMEOUT}
+	assert.True(t, errors.As(err, &actionErr))
+	assert.Equal(t, "action disk-wipe of workflow wf ended with state STATE_TIMEOUT", err.Error())
+}
+
+func TestWorkflowError(t *testing.T) {
+	w := testWorker(t, &drainClient{})
+	defer os.RemoveAll(w.dataDir)
+
+	// a failed action does not stop the other workflows
+	assert.NoError(t, w.workflowError(&ActionError{WorkflowID: "wf", ActionName: "disk-wipe", State: pb.State_STATE_FAILED}))
+	assert.NoError(t, w.workflowError(nil))
+
+	serverErr := newServerError(errReportActionStatus, status.Error(codes.Unavailable, "connection refused"))
+	assert.Equal(t, serverErr, w.workflowError(serverErr))
+	dataErr := &DataError{WorkflowID: "wf", Err: io.ErrUnexpectedEOF}
+	assert.Equal(t, dataErr, w.workflowError(dataErr))
+}
+
+func TestWorkflowDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tink-worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w := &Worker{dataDir: dir}
+	assert.NoError(t, w.initWorkflowDir("wf"))
+	data, err := ioutil.ReadFile(filepath.Join(dir, "wf", dataFile))
+	assert.NoError(t, err)
+	assert.Equal(t, "{}", string(data))
+
+	assert.NoError(t, w.resetOutputs("wf"))
+	outputs := filepath.Join(dir, "wf", outputsDir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(outputs, "root_uuid"), []byte("1234\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(outputs, "root.uuid"), []byte("1234"), 0644))
+	assert.Equal(t, map[string]string{"root_uuid": "1234"}, w.readOutputs("wf", testLogger(t)))
+
+	// the data file of an existing workflow is kept
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "wf", dataFile), []byte(`{"os":"ubuntu"}`), 0644))
+	assert.NoError(t, w.initWorkflowDir("wf"))
+	data, err = ioutil.ReadFile(filepath.Join(dir, "wf", dataFile))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"os":"ubuntu"}`, string(data))
+}
+
+// refusingClient refuses the start of the actions, as tink-server does while
+// a workflow is paused
+type refusingClient struct {
+	drainClient
+	refused int
+}
+
+func (c *refusingClient) ReportActionStatus(ctx context.Context, in *pb.WorkflowActionStatus, opts ...grpc.CallOption) (*pb.Empty, error) {
+	c.refused++
+	return nil, status.Error(codes.FailedPrecondition, "workflow is paused")
+}
+
+func TestProcessWorkflowPaused(t *testing.T) {
+	c := &refusingClient{}
+	w := testWorker(t, c)
+	w.retries = 3
+	defer os.RemoveAll(w.dataDir)
+
+	err := w.processWorkflow(context.Background(), &pb.WorkflowContext{WorkflowId: "wf", TotalNumberOfActions: 1}, "boot")
+	assert.NoError(t, err)
+	// the refusal is not retried
+	assert.Equal(t, 1, c.refused)
+
+	// the actions of a workflow known to be paused are not even reported
+	err = w.processWorkflow(context.Background(), &pb.WorkflowContext{WorkflowId: "wf", TotalNumberOfActions: 1, Paused: true}, "boot")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, c.refused)
+}
+
+// approvalClient serves a workflow starting with an approval action
+type approvalClient struct {
+	drainClient
+}
+
+func (c *approvalClient) GetWorkflowActions(ctx context.Context, in *pb.WorkflowActionsRequest, opts ...grpc.CallOption) (*pb.WorkflowActionList, error) {
+	return &pb.WorkflowActionList{ActionList: []*pb.WorkflowAction{
+		{Name: "review-inventory", TaskName: "provision", WorkerId: "worker", Type: wflow.ActionTypeApproval},
+		{Name: "disk-wipe", TaskName: "provision", WorkerId: "worker"},
+	}}, nil
+}
+
+func TestProcessWorkflowApproval(t *testing.T) {
+	c := &approvalClient{}
+	w := testWorker(t, c)
+	w.retries = 3
+	defer os.RemoveAll(w.dataDir)
+
+	err := w.processWorkflow(context.Background(), &pb.WorkflowContext{WorkflowId: "wf", TotalNumberOfActions: 2}, "boot")
+	assert.NoError(t, err)
+	// the approval action is left to tink-server
+	assert.Equal(t, 0, c.count())
+}
@@ -0,0 +1,136 @@
+package workflow
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	errIncludeCycle    = "include cycle: %s"
+	errIncludeWithTask = "include of %s cannot define a task"
+	errIncludeTemplate = "including template %s"
+)
+
+// includeField matches the include field of a task, found without decoding
+// templates which may not be valid YAML until they are rendered
+var includeField = regexp.MustCompile(`(?m)^[ \t]*(?:-[ \t]+)?include[ \t]*:`)
+
+// Include records a template expanded in place of an include of a workflow
+// template, for the provenance of the workflows
+type Include struct {
+	// Name is the reference to the template, its name or its id
+	Name       string `json:"name"`
+	TemplateID string `json:"template_id"`
+	// IncludedBy is the id of the template holding the include
+	IncludedBy string `json:"included_by"`
+}
+
+// TemplateLookup returns the id and the data of the template a reference of
+// an include points to
+type TemplateLookup func(ref string) (id, data string, err error)
+
+// ExpandIncludes replaces the includes of the template data with the tasks
+// of the included templates, which may include templates in turn. The
+// parameters of the included templates are added to the ones of the template,
+// unless it declares them as well. The template data is returned as it is when
+// it has no include, otherwise it is in the current version of the format, as
+// the included templates may be of any supported version. Only the templates
+// with includes are decoded, they must be valid YAML before being rendered.
+func ExpandIncludes(templateID, data string, lookup TemplateLookup) (string, []Include, error) {
+	if !includeField.MatchString(data) {
+		return data, nil, nil
+	}
+	wf, err := decode([]byte(data))
+	if err != nil {
+		return "", nil, err
+	}
+	if !hasIncludes(wf.Tasks) {
+		return data, nil, nil
+	}
+
+	e := &expansion{lookup: lookup, params: map[string]struct{}{}}
+	for _, p := range wf.Parameters {
+		e.params[p.Name] = struct{}{}
+	}
+	tasks, err := e.expand(wf.Tasks, []string{templateID})
+	if err != nil {
+		return "", nil, err
+	}
+	wf.Tasks = tasks
+	wf.Parameters = append(wf.Parameters, e.extraParams...)
+
+	expanded, err := yaml.Marshal(wf)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(expanded), e.includes, nil
+}
+
+type expansion struct {
+	lookup      TemplateLookup
+	includes    []Include
+	params      map[string]struct{}
+	extraParams []Parameter
+}
+
+// expand expands the includes of tasks, stack holds the ids of the templates
+// being expanded, from the root template
+func (e *expansion) expand(tasks []Task, stack []string) ([]Task, error) {
+	var expanded []Task
+	for _, task := range tasks {
+		if task.Include == "" {
+			expanded = append(expanded, task)
+			continue
+		}
+
+		id, data, err := e.lookup(task.Include)
+		if err != nil {
+			return nil, errors.Wrapf(err, errIncludeTemplate, task.Include)
+		}
+		for i, parent := range stack {
+			if parent == id {
+				cycle := append(append([]string{}, stack[i:]...), id)
+				return nil, errors.Errorf(errIncludeCycle, strings.Join(cycle, " -> "))
+			}
+		}
+		included, err := decode([]byte(data))
+		if err != nil {
+			return nil, errors.Wrapf(err, errIncludeTemplate, task.Include)
+		}
+		e.includes = append(e.includes, Include{Name: task.Include, TemplateID: id, IncludedBy: stack[len(stack)-1]})
+		for _, p := range included.Parameters {
+			if _, ok := e.params[p.Name]; !ok {
+				e.params[p.Name] = struct{}{}
+				e.extraParams = append(e.extraParams, p)
+			}
+		}
+
+		tasks, err := e.expand(included.Tasks, append(stack[:len(stack):len(stack)], id))
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, tasks...)
+	}
+	return expanded, nil
+}
+
+func hasIncludes(tasks []Task) bool {
+	for _, task := range tasks {
+		if task.Include != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// validateInclude checks that a task including a template does not define
+// anything else
+func validateInclude(task Task) error {
+	if task.Name != "" || task.WorkerAddr != "" || len(task.Actions) > 0 || len(task.Volumes) > 0 || len(task.Environment) > 0 {
+		return errors.Errorf(errIncludeWithTask, task.Include)
+	}
+	return nil
+}
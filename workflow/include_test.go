@@ -0,0 +1,183 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	rootTemplate = `
+version: "0.1"
+name: install
+global_timeout: 600
+parameters:
+  - name: disk
+    default: /dev/sda
+tasks:
+  - include: common-disk-wipe
+  - name: "install"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "install"
+      image: install
+`
+	diskWipeTemplate = `
+version: "0.1"
+name: common-disk-wipe
+global_timeout: 600
+parameters:
+  - name: disk
+    default: /dev/sdb
+  - name: passes
+    type: int
+    default: 1
+tasks:
+  - include: common-inventory
+  - name: "wipe"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "wipe"
+      image: wipe
+      environment:
+        DISK: '{{ param "disk" }}'
+        PASSES: '{{ param "passes" }}'
+`
+	inventoryTemplate = `
+version: "0.1"
+name: common-inventory
+global_timeout: 600
+tasks:
+  - name: "inventory"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "inventory"
+      image: inventory
+`
+)
+
+func lookupTemplates(templates map[string]string) TemplateLookup {
+	return func(ref string) (string, string, error) {
+		data, ok := templates[ref]
+		if !ok {
+			return "", "", errors.New("template not found")
+		}
+		return ref + "-id", data, nil
+	}
+}
+
+func TestExpandIncludes(t *testing.T) {
+	lookup := lookupTemplates(map[string]string{
+		"common-disk-wipe": diskWipeTemplate,
+		"common-inventory": inventoryTemplate,
+	})
+	expanded, includes, err := ExpandIncludes("root-id", rootTemplate, lookup)
+	assert.NoError(t, err)
+	assert.Equal(t, []Include{
+		{Name: "common-disk-wipe", TemplateID: "common-disk-wipe-id", IncludedBy: "root-id"},
+		{Name: "common-inventory", TemplateID: "common-inventory-id", IncludedBy: "common-disk-wipe-id"},
+	}, includes)
+
+	rendered, err := RenderTemplate("root-id", expanded, []byte(`{"device_1": "08:00:27:00:00:01"}`), nil)
+	assert.NoError(t, err)
+	wf, err := Parse([]byte(rendered))
+	assert.NoError(t, err)
+	var tasks []string
+	for _, task := range wf.Tasks {
+		tasks = append(tasks, task.Name)
+		assert.Equal(t, "08:00:27:00:00:01", task.WorkerAddr)
+	}
+	assert.Equal(t, []string{"inventory", "wipe", "install"}, tasks)
+	// the parameters declared by the including template take precedence
+	assert.Equal(t, map[string]string{"DISK": "/dev/sda", "PASSES": "1"}, wf.Tasks[1].Actions[0].Environment)
+
+	// the templates without include are kept as they are
+	expanded, includes, err = ExpandIncludes("inventory-id", inventoryTemplate, lookup)
+	assert.NoError(t, err)
+	assert.Equal(t, inventoryTemplate, expanded)
+	assert.Empty(t, includes)
+
+	_, _, err = ExpandIncludes("root-id", rootTemplate, lookupTemplates(nil))
+	assert.Error(t, err)
+}
+
+func TestExpandIncludesControlBlocks(t *testing.T) {
+	const data = `
+version: "0.1"
+name: install
+global_timeout: 600
+tasks:
+  - name: "install"
+    worker: "{{.device_1}}"
+    actions:
+{{- if .wipe }}
+    - name: "wipe"
+      image: wipe
+{{- end }}
+    - name: "install"
+      image: install
+`
+	expanded, includes, err := ExpandIncludes("install-id", data, lookupTemplates(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, data, expanded)
+	assert.Empty(t, includes)
+}
+
+func TestExpandIncludesCycle(t *testing.T) {
+	const loop = `
+version: "0.1"
+name: loop
+global_timeout: 600
+tasks:
+  - include: common-disk-wipe
+`
+	lookup := lookupTemplates(map[string]string{
+		"common-disk-wipe": diskWipeTemplate,
+		"common-inventory": loop,
+	})
+	_, _, err := ExpandIncludes("root-id", rootTemplate, lookup)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "common-disk-wipe-id -> common-inventory-id -> common-disk-wipe-id")
+	}
+
+	_, _, err = ExpandIncludes("common-disk-wipe-id", loop, lookup)
+	assert.Error(t, err)
+}
+
+func TestExpandIncludesVersions(t *testing.T) {
+	const (
+		root = `
+version: "0.2"
+name: install
+description: installs the operating system
+global_timeout: 600
+tasks:
+  - include: common-disk-wipe
+`
+		diskWipe = `
+version: "0.1"
+name: common-disk-wipe
+global_timeout: 600
+tasks:
+  - name: "wipe"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "wipe"
+      image: wipe
+      on-timeout: ["cleanup"]
+      on-failure: ["cleanup"]
+`
+	)
+	expanded, _, err := ExpandIncludes("root-id", root, lookupTemplates(map[string]string{"common-disk-wipe": diskWipe}))
+	assert.NoError(t, err)
+
+	rendered, err := RenderTemplate("root-id", expanded, []byte(`{"device_1": "08:00:27:00:00:01"}`), nil)
+	assert.NoError(t, err)
+	wf, err := Parse([]byte(rendered))
+	if assert.NoError(t, err) {
+		action := wf.Tasks[0].Actions[0]
+		assert.Equal(t, []string{"cleanup"}, action.OnTimeout)
+		assert.Equal(t, []string{"cleanup"}, action.OnFailure)
+	}
+}
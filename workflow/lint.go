@@ -0,0 +1,625 @@
+package workflow
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Severities of the issues found by Lint, a template with errors is rejected
+// while warnings point at likely mistakes
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+var (
+	// paramRef matches a use of a template parameter, e.g. {{ param "disk" }}
+	paramRef = regexp.MustCompile(`\bparam\s+"([^"]*)"`)
+
+	// envName matches the portable names of environment variables
+	envName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+	// yamlErrorLine and templateErrorLine match the positions reported by
+	// the YAML and text/template parsers
+	yamlErrorLine     = regexp.MustCompile(`line (\d+)`)
+	templateErrorLine = regexp.MustCompile(`^template: [^:]*:(\d+):(?:(\d+):)? (.*)$`)
+
+	parameterFields = knownFields(reflect.TypeOf(Parameter{}))
+
+	// versionFields are the fields of each version of the template format,
+	// taken from the structures of the schemas
+	versionFields = schemaFields()
+)
+
+// formatFields are the fields of the templates in a version of the format
+type formatFields struct {
+	workflow, task, action map[string]bool
+	onTimeout, onFailure   string
+}
+
+// Issue is a problem found in a template by Lint. The line and the column are
+// 1-based, zero when the position is unknown.
+type Issue struct {
+	Severity string `json:"severity"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	// Path locates the field at fault, e.g. tasks[0].actions[1].image
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	s := fmt.Sprintf("%d:%d: %s: ", i.Line, i.Column, i.Severity)
+	if i.Path != "" {
+		s += i.Path + ": "
+	}
+	return s + i.Message
+}
+
+// HasErrors checks if any of the issues is an error
+func HasErrors(issues []Issue) bool {
+	for _, i := range issues {
+		if i.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Lint checks a template before it is rendered. Unlike Parse, it goes on after
+// the first problem and reports all the errors and warnings found, sorted by
+// their position.
+func Lint(data string) []Issue {
+	l := &linter{
+		taskNames: map[string]struct{}{},
+		params:    map[string]*yamlv3.Node{},
+		actions:   map[string]struct{}{},
+		reboots:   map[string]struct{}{},
+	}
+	l.lintTemplateActions(data)
+
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal([]byte(data), &doc); err != nil {
+		issue := Issue{Severity: SeverityError, Message: err.Error()}
+		if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			issue.Line, _ = strconv.Atoi(m[1])
+		}
+		l.issues = append(l.issues, issue)
+	} else if len(doc.Content) == 0 {
+		l.errorf(nil, "", "template is empty")
+	} else {
+		l.lintWorkflow(doc.Content[0])
+		l.lintParamRefs(data)
+	}
+
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		if l.issues[i].Line != l.issues[j].Line {
+			return l.issues[i].Line < l.issues[j].Line
+		}
+		return l.issues[i].Column < l.issues[j].Column
+	})
+	return l.issues
+}
+
+type linter struct {
+	issues    []Issue
+	taskNames map[string]struct{}
+	// params are the declarations of the parameters, by name
+	params map[string]*yamlv3.Node
+	// actions are the names of the actions checked so far, whose outputs can
+	// be referenced by the next actions
+	actions map[string]struct{}
+	// reboots are the names of the reboot actions checked so far, which
+	// report no outputs
+	reboots map[string]struct{}
+	// sharedNames are the names of the actions also used by an action of a
+	// previous task, an error when the template references outputs
+	sharedNames  []namedNode
+	usesOutputs  bool
+	totalTimeout int64
+	fields       formatFields
+}
+
+// namedNode is a node along with its path in the template
+type namedNode struct {
+	node *yamlv3.Node
+	path string
+}
+
+func (l *linter) add(severity string, n *yamlv3.Node, path, format string, args ...interface{}) {
+	issue := Issue{Severity: severity, Path: path, Message: fmt.Sprintf(format, args...)}
+	if n != nil {
+		issue.Line, issue.Column = n.Line, n.Column
+	}
+	l.issues = append(l.issues, issue)
+}
+
+func (l *linter) errorf(n *yamlv3.Node, path, format string, args ...interface{}) {
+	l.add(SeverityError, n, path, format, args...)
+}
+
+func (l *linter) warnf(n *yamlv3.Node, path, format string, args ...interface{}) {
+	l.add(SeverityWarning, n, path, format, args...)
+}
+
+// lintTemplateActions checks the syntax of the {{ }} actions of the template
+func (l *linter) lintTemplateActions(data string) {
+	t := template.New("workflow-template").Funcs(template.FuncMap{
+		"param": func(string) interface{} { return nil },
+	})
+	if _, err := t.Parse(EscapeRefs(data)); err != nil {
+		issue := Issue{Severity: SeverityError, Message: err.Error()}
+		if m := templateErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			issue.Line, _ = strconv.Atoi(m[1])
+			issue.Column, _ = strconv.Atoi(m[2])
+			issue.Message = m[3]
+		}
+		l.issues = append(l.issues, issue)
+	}
+}
+
+func (l *linter) lintWorkflow(n *yamlv3.Node) {
+	if n.Kind != yamlv3.MappingNode {
+		l.errorf(n, "", "expected a mapping")
+		return
+	}
+	// the version is checked first as it tells the fields of the template,
+	// the ones of the current version are expected when it is invalid
+	versionNode := mappingValue(n, "version")
+	version, ok := l.str(versionNode, "version")
+	if !ok || !isSupportedVersion(version) {
+		l.errorf(valueOr(versionNode, n), "version", errTemplateInvalidVersion, version)
+		version = CurrentVersion
+	}
+	l.fields = versionFields[version]
+
+	f, _ := l.mapping(n, "", l.fields.workflow)
+	l.name(f["name"], n, "name")
+	l.str(f["description"], "description")
+	l.labels(f["labels"], "labels")
+	globalTimeout, _ := l.integer(f["global_timeout"], "global_timeout")
+	if globalTimeout <= 0 {
+		l.warnf(valueOr(f["global_timeout"], n), "global_timeout", "global_timeout should be a positive number of seconds")
+	}
+
+	for i, p := range l.sequence(f["parameters"], "parameters") {
+		l.lintParameter(p, fmt.Sprintf("parameters[%d]", i))
+	}
+
+	tasks := l.sequence(f["tasks"], "tasks")
+	if len(tasks) == 0 {
+		l.errorf(valueOr(f["tasks"], n), "tasks", "template must have at least one task defined")
+	}
+	for i, task := range tasks {
+		l.lintTask(task, fmt.Sprintf("tasks[%d]", i))
+	}
+
+	if l.usesOutputs {
+		for _, n := range l.sharedNames {
+			l.errorf(n.node, n.path, errActionOutputsName, n.node.Value)
+		}
+	}
+
+	if globalTimeout > 0 && l.totalTimeout > globalTimeout {
+		l.warnf(f["global_timeout"], "global_timeout", "the timeouts of the actions add up to %d seconds, more than the global timeout", l.totalTimeout)
+	}
+}
+
+func (l *linter) lintParameter(n *yamlv3.Node, path string) {
+	f, ok := l.mapping(n, path, parameterFields)
+	if !ok {
+		return
+	}
+	var p Parameter
+	p.Name, _ = l.name(f["name"], n, path+".name")
+	if p.Name != "" {
+		if _, ok := l.params[p.Name]; ok {
+			l.errorf(f["name"], path+".name", errParameterDuplicateName, p.Name)
+		} else {
+			l.params[p.Name] = f["name"]
+		}
+	}
+	p.Type, _ = l.str(f["type"], path+".type")
+	l.str(f["description"], path+".description")
+	p.Required, _ = l.boolean(f["required"], path+".required")
+	p.Values = l.stringList(f["values"], path+".values")
+
+	switch p.Type {
+	case "", ParameterTypeString, ParameterTypeInt, ParameterTypeBool:
+		if len(p.Values) > 0 {
+			l.warnf(f["values"], path+".values", "values are only used by enum parameters")
+		}
+	case ParameterTypeEnum:
+		if len(p.Values) == 0 {
+			l.errorf(valueOr(f["values"], n), path+".values", errParameterNoValues, p.Name)
+		}
+	default:
+		l.errorf(f["type"], path+".type", errParameterInvalidType, p.Name, p.Type)
+		return
+	}
+	if def, ok := l.str(f["default"], path+".default"); ok {
+		if _, err := p.Parse(def); err != nil {
+			l.errorf(f["default"], path+".default", "invalid default: %v", err)
+		}
+		if p.Required {
+			l.warnf(f["required"], path+".required", "a required parameter does not use its default")
+		}
+	}
+}
+
+func (l *linter) lintTask(n *yamlv3.Node, path string) {
+	f, ok := l.mapping(n, path, l.fields.task)
+	if !ok {
+		return
+	}
+	if include := f["include"]; include != nil {
+		name, _ := l.str(include, path+".include")
+		if name == "" {
+			l.errorf(include, path+".include", "include cannot be empty")
+		}
+		if len(f) > 1 {
+			l.errorf(include, path+".include", errIncludeWithTask, name)
+		}
+		return
+	}
+
+	if name, ok := l.name(f["name"], n, path+".name"); ok {
+		if _, dup := l.taskNames[name]; dup {
+			l.errorf(f["name"], path+".name", errTaskDuplicateName, name)
+		}
+		l.taskNames[name] = struct{}{}
+	}
+	l.str(f["description"], path+".description")
+	worker, _ := l.str(f["worker"], path+".worker")
+	switch {
+	case worker == "":
+		l.errorf(valueOr(f["worker"], n), path+".worker", "worker is required")
+	case strings.Contains(worker, "{{"):
+	case isWorkerAddress(worker):
+	default:
+		l.errorf(f["worker"], path+".worker", "invalid worker %s, expected a device reference, a MAC or an IPv4 address", worker)
+	}
+	l.volumes(f["volumes"], path+".volumes")
+	l.environment(f["environment"], path+".environment")
+
+	actions := l.sequence(f["actions"], path+".actions")
+	if len(actions) == 0 {
+		l.warnf(valueOr(f["actions"], n), path+".actions", "task has no action")
+	}
+	names := map[string]struct{}{}
+	for i, action := range actions {
+		l.lintAction(action, fmt.Sprintf("%s.actions[%d]", path, i), names)
+	}
+}
+
+func (l *linter) lintAction(n *yamlv3.Node, path string, names map[string]struct{}) {
+	f, ok := l.mapping(n, path, l.fields.action)
+	if !ok {
+		return
+	}
+	name, ok := l.name(f["name"], n, path+".name")
+	if ok {
+		if _, dup := names[name]; dup {
+			l.errorf(f["name"], path+".name", errActionDuplicateName, name)
+		} else if _, earlier := l.actions[name]; earlier {
+			l.sharedNames = append(l.sharedNames, namedNode{f["name"], path + ".name"})
+		}
+		names[name] = struct{}{}
+	}
+	l.str(f["description"], path+".description")
+
+	actionType, _ := l.str(f["type"], path+".type")
+	image, _ := l.str(f["image"], path+".image")
+	timeout, timeoutOK := l.integer(f["timeout"], path+".timeout")
+	command := l.stringList(f["command"], path+".command")
+	l.stringList(f[l.fields.onTimeout], path+"."+l.fields.onTimeout)
+	l.stringList(f[l.fields.onFailure], path+"."+l.fields.onFailure)
+	reboot, _ := l.boolean(f["reboot"], path+".reboot")
+	l.volumes(f["volumes"], path+".volumes")
+	l.environment(f["environment"], path+".environment")
+
+	switch actionType {
+	case "":
+		switch {
+		case image == "":
+			l.errorf(valueOr(f["image"], n), path+".image", "image is required")
+		case strings.Contains(image, "{{"):
+		case !hasValidImageName(image):
+			l.errorf(f["image"], path+".image", errActionInvalidImage, image)
+		}
+		switch {
+		case timeout < 0:
+			l.errorf(f["timeout"], path+".timeout", "timeout cannot be negative")
+		case timeout == 0 && (timeoutOK || f["timeout"] == nil):
+			l.warnf(valueOr(f["timeout"], n), path+".timeout", "action has no timeout")
+		}
+		l.totalTimeout += timeout
+	case ActionTypeApproval:
+		if image != "" || len(command) > 0 || reboot {
+			l.errorf(f["type"], path+".type", errApprovalWithImage, name)
+		}
+	default:
+		l.errorf(f["type"], path+".type", errActionInvalidType, actionType)
+	}
+
+	// the outputs available to an action are the ones of the previous actions
+	l.outputRefs(n, path)
+	if name != "" {
+		l.actions[name] = struct{}{}
+		if reboot {
+			l.reboots[name] = struct{}{}
+		}
+	}
+}
+
+// outputRefs checks that the output references found in the values of n refer
+// to previous actions
+func (l *linter) outputRefs(n *yamlv3.Node, path string) {
+	if n.Kind == yamlv3.ScalarNode {
+		for _, m := range outputRef.FindAllStringSubmatch(n.Value, -1) {
+			l.usesOutputs = true
+			if _, ok := l.actions[m[1]]; !ok {
+				l.errorf(n, path, "output %s of action %s is used before the action runs", m[2], m[1])
+			} else if _, ok := l.reboots[m[1]]; ok {
+				l.errorf(n, path, errRebootActionOutputs, m[1])
+			}
+		}
+		return
+	}
+	for _, c := range n.Content {
+		l.outputRefs(c, path)
+	}
+}
+
+// lintParamRefs checks that the parameters used by the template are declared
+// and that the declared parameters are used
+func (l *linter) lintParamRefs(data string) {
+	used := map[string]struct{}{}
+	for _, loc := range paramRef.FindAllStringSubmatchIndex(data, -1) {
+		name := data[loc[2]:loc[3]]
+		used[name] = struct{}{}
+		if _, ok := l.params[name]; !ok {
+			line := strings.Count(data[:loc[0]], "\n") + 1
+			column := loc[0] - strings.LastIndex(data[:loc[0]], "\n")
+			l.issues = append(l.issues, Issue{
+				Severity: SeverityError,
+				Line:     line,
+				Column:   column,
+				Message:  fmt.Sprintf(errParameterUndeclared, name),
+			})
+		}
+	}
+	for name, n := range l.params {
+		if _, ok := used[name]; !ok {
+			l.warnf(n, "parameters", "parameter %s is not used", name)
+		}
+	}
+}
+
+// mapping returns the values of the fields of a mapping node, reporting the
+// unknown and duplicated fields
+func (l *linter) mapping(n *yamlv3.Node, path string, known map[string]bool) (map[string]*yamlv3.Node, bool) {
+	for n.Kind == yamlv3.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	if n.Kind != yamlv3.MappingNode {
+		l.errorf(n, path, "expected a mapping")
+		return nil, false
+	}
+	f := map[string]*yamlv3.Node{}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		switch {
+		case !known[key.Value]:
+			l.errorf(key, path, "unknown field %s", key.Value)
+		case f[key.Value] != nil:
+			l.errorf(key, path, "duplicated field %s", key.Value)
+		default:
+			f[key.Value] = value
+		}
+	}
+	return f, true
+}
+
+// name checks the name of a template, task, action or parameter
+func (l *linter) name(n, parent *yamlv3.Node, path string) (string, bool) {
+	name, _ := l.str(n, path)
+	if hasEmptyName(name) {
+		l.errorf(valueOr(n, parent), path, errEmptyName)
+		return "", false
+	}
+	if !hasValidLength(name) {
+		l.errorf(n, path, errInvalidLength, name)
+		return name, false
+	}
+	return name, true
+}
+
+func (l *linter) str(n *yamlv3.Node, path string) (string, bool) {
+	if n == nil {
+		return "", false
+	}
+	var s string
+	if n.Kind != yamlv3.ScalarNode || n.Decode(&s) != nil {
+		l.errorf(n, path, "expected a string")
+		return "", false
+	}
+	return s, true
+}
+
+func (l *linter) integer(n *yamlv3.Node, path string) (int64, bool) {
+	if n == nil {
+		return 0, false
+	}
+	var i int64
+	if n.Decode(&i) != nil {
+		l.errorf(n, path, "expected an integer")
+		return 0, false
+	}
+	return i, true
+}
+
+func (l *linter) boolean(n *yamlv3.Node, path string) (bool, bool) {
+	if n == nil {
+		return false, false
+	}
+	var b bool
+	if n.Decode(&b) != nil {
+		l.errorf(n, path, "expected a boolean")
+		return false, false
+	}
+	return b, true
+}
+
+func (l *linter) sequence(n *yamlv3.Node, path string) []*yamlv3.Node {
+	if n == nil || n.Tag == "!!null" {
+		return nil
+	}
+	if n.Kind != yamlv3.SequenceNode {
+		l.errorf(n, path, "expected a list")
+		return nil
+	}
+	return n.Content
+}
+
+func (l *linter) stringList(n *yamlv3.Node, path string) []string {
+	var values []string
+	for i, item := range l.sequence(n, path) {
+		if s, ok := l.str(item, fmt.Sprintf("%s[%d]", path, i)); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+func (l *linter) volumes(n *yamlv3.Node, path string) {
+	for i, item := range l.sequence(n, path) {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		spec, ok := l.str(item, itemPath)
+		if !ok || strings.Contains(spec, "{{") {
+			continue
+		}
+		if _, err := ParseVolume(spec); err != nil {
+			l.errorf(item, itemPath, "%v", err)
+		}
+	}
+}
+
+func (l *linter) environment(n *yamlv3.Node, path string) {
+	if n == nil || n.Tag == "!!null" {
+		return
+	}
+	if n.Kind != yamlv3.MappingNode {
+		l.errorf(n, path, "expected a mapping")
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		switch {
+		case key.Value == "" || strings.Contains(key.Value, "="):
+			l.errorf(key, path, "invalid environment variable name %q", key.Value)
+		case !envName.MatchString(key.Value):
+			l.warnf(key, path, "environment variable name %s is not portable", key.Value)
+		}
+		l.str(value, path+"."+key.Value)
+	}
+}
+
+func (l *linter) labels(n *yamlv3.Node, path string) {
+	if n == nil || n.Tag == "!!null" {
+		return
+	}
+	if n.Kind != yamlv3.MappingNode {
+		l.errorf(n, path, "expected a mapping")
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if key.Value == "" {
+			l.errorf(key, path, "label name cannot be empty")
+		}
+		l.str(value, path+"."+key.Value)
+	}
+}
+
+// isWorkerAddress checks if s is an address tink-server resolves to a worker
+func isWorkerAddress(s string) bool {
+	if _, err := net.ParseMAC(s); err == nil {
+		return true
+	}
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() != nil
+}
+
+func valueOr(n, parent *yamlv3.Node) *yamlv3.Node {
+	if n != nil {
+		return n
+	}
+	return parent
+}
+
+// schemaFields returns the fields of the versions of the template format, by
+// version, from the yaml tags of their structures
+func schemaFields() map[string]formatFields {
+	all := map[string]formatFields{}
+	for _, s := range schemas {
+		workflow := reflect.TypeOf(s.format()).Elem()
+		task := fieldElem(workflow, "Tasks")
+		action := fieldElem(task, "Actions")
+		all[s.version] = formatFields{
+			workflow:  knownFields(workflow),
+			task:      knownFields(task),
+			action:    knownFields(action),
+			onTimeout: yamlName(fieldByName(action, "OnTimeout")),
+			onFailure: yamlName(fieldByName(action, "OnFailure")),
+		}
+	}
+	return all
+}
+
+// knownFields returns the names of the fields of a structure in a template
+func knownFields(t reflect.Type) map[string]bool {
+	known := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		if name := yamlName(t.Field(i)); name != "" {
+			known[name] = true
+		}
+	}
+	return known
+}
+
+// yamlName returns the name of a field of a structure in a template
+func yamlName(f reflect.StructField) string {
+	tag := f.Tag.Get("yaml")
+	if tag == "-" {
+		return ""
+	}
+	if i := strings.Index(tag, ","); i >= 0 {
+		tag = tag[:i]
+	}
+	if tag == "" {
+		return strings.ToLower(f.Name)
+	}
+	return tag
+}
+
+// fieldElem returns the type of the elements of a slice field of a structure
+func fieldElem(t reflect.Type, name string) reflect.Type {
+	return fieldByName(t, name).Type.Elem()
+}
+
+func fieldByName(t reflect.Type, name string) reflect.StructField {
+	f, ok := t.FieldByName(name)
+	if !ok {
+		panic(fmt.Sprintf("%s has no field %s", t, name))
+	}
+	return f
+}
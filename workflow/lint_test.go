@@ -0,0 +1,202 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintValidTemplates(t *testing.T) {
+	for _, data := range []string{validTemplate, validTemplateWithReboot} {
+		assert.Empty(t, Lint(data), data)
+	}
+	// the warnings do not prevent these templates from being used
+	for _, data := range []string{validTemplateWithApproval, parametersTemplate, rootTemplate} {
+		issues := Lint(data)
+		assert.NotEmpty(t, issues, data)
+		assert.False(t, HasErrors(issues), data)
+	}
+}
+
+func TestLint(t *testing.T) {
+	const data = `version: "0.3"
+name: install
+global_timeout: 60
+parameters:
+  - name: disk
+    type: path
+  - name: unused
+tasks:
+  - name: "install"
+    worker: "not a worker"
+    actions:
+    - name: "partition"
+      image: "disk-partition:$#@"
+      timeout: 30
+      environment:
+        ROOT-UUID: '{{ outputs.format.root_uuid }}'
+        DISK: '{{ param "disk" }}'
+    - name: "format"
+      image: format
+      timeout: 60
+      retries: 3
+    - name: "partition"
+      image: partition
+      volumes:
+        - /dev
+  - name: "install"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "kexec"
+      image: kexec
+      timeout: 90
+      command: '{{ param "cmdline" }}'
+`
+	issues := Lint(data)
+	assert.True(t, HasErrors(issues))
+
+	type position struct {
+		severity  string
+		line, col int
+		path      string
+	}
+	var got []position
+	for _, i := range issues {
+		got = append(got, position{severity: i.Severity, line: i.Line, col: i.Column, path: i.Path})
+	}
+	assert.Equal(t, []position{
+		{SeverityError, 1, 10, "version"},
+		{SeverityWarning, 3, 17, "global_timeout"},
+		{SeverityError, 6, 11, "parameters[0].type"},
+		{SeverityWarning, 7, 11, "parameters"},
+		{SeverityError, 10, 13, "tasks[0].worker"},
+		{SeverityError, 13, 14, "tasks[0].actions[0].image"},
+		{SeverityWarning, 16, 9, "tasks[0].actions[0].environment"},
+		{SeverityError, 16, 20, "tasks[0].actions[0]"},
+		{SeverityError, 21, 7, "tasks[0].actions[1]"},
+		{SeverityWarning, 22, 7, "tasks[0].actions[2].timeout"},
+		{SeverityError, 22, 13, "tasks[0].actions[2].name"},
+		{SeverityError, 25, 11, "tasks[0].actions[2].volumes[0]"},
+		{SeverityError, 26, 11, "tasks[1].name"},
+		{SeverityError, 32, 16, "tasks[1].actions[0].command"},
+		{SeverityError, 32, 20, ""},
+	}, got)
+}
+
+func TestLintOutputActionNames(t *testing.T) {
+	const data = `version: "0.1"
+name: wipe
+global_timeout: 600
+tasks:
+  - name: "first"
+    worker: "08:00:27:00:00:01"
+    actions:
+    - name: "wipe"
+      image: wipe
+      timeout: 60
+  - name: "second"
+    worker: "08:00:27:00:00:02"
+    actions:
+    - name: "wipe"
+      image: wipe
+      timeout: 60
+    - name: "report"
+      image: report
+      timeout: 60
+      environment:
+        SERIAL: '{{ outputs.wipe.serial }}'
+`
+	issues := Lint(data)
+	assert.Equal(t, []Issue{{
+		Severity: SeverityError,
+		Line:     14,
+		Column:   13,
+		Path:     "tasks[1].actions[0].name",
+		Message:  "two actions in a template using outputs cannot have same name: wipe",
+	}}, issues)
+
+	// the actions may share names when no output is referenced
+	assert.Empty(t, Lint(strings.Replace(data, "{{ outputs.wipe.serial }}", "unknown", 1)))
+}
+
+func TestLintRebootOutputs(t *testing.T) {
+	const data = `version: "0.2"
+name: kexec
+global_timeout: 600
+tasks:
+  - name: "install"
+    worker: "08:00:27:00:00:01"
+    actions:
+    - name: "kexec"
+      image: kexec
+      timeout: 60
+      reboot: true
+    - name: "report"
+      image: report
+      timeout: 60
+      environment:
+        KERNEL: '{{ outputs.kexec.kernel }}'
+`
+	issues := Lint(data)
+	if assert.Len(t, issues, 1) {
+		assert.Equal(t, "outputs of reboot action kexec cannot be used, it reports no outputs", issues[0].Message)
+		assert.Equal(t, 16, issues[0].Line)
+	}
+}
+
+func TestLintAliases(t *testing.T) {
+	const data = `version: "0.2"
+name: wipe
+global_timeout: 600
+tasks:
+  - name: "first"
+    worker: "08:00:27:00:00:01"
+    actions:
+    - &wipe
+      name: "wipe"
+      image: wipe
+      timeout: 60
+  - name: "second"
+    worker: "08:00:27:00:00:02"
+    actions:
+    - *wipe
+`
+	assert.Empty(t, Lint(data))
+}
+
+func TestSchemaFields(t *testing.T) {
+	assert.Equal(t, "on-timeout", versionFields[Version01].onTimeout)
+	assert.Equal(t, "on-failure", versionFields[Version01].onFailure)
+	assert.False(t, versionFields[Version01].action["description"])
+	assert.Equal(t, "on_timeout", versionFields[Version02].onTimeout)
+	assert.Equal(t, "on_failure", versionFields[Version02].onFailure)
+	assert.True(t, versionFields[Version02].workflow["labels"])
+	assert.True(t, versionFields[Version02].task["include"])
+	assert.True(t, parameterFields["values"])
+}
+
+func TestLintSyntaxErrors(t *testing.T) {
+	issues := Lint(invalidTemplate)
+	if assert.Len(t, issues, 1) {
+		assert.Equal(t, SeverityError, issues[0].Severity)
+		assert.Equal(t, 8, issues[0].Line)
+	}
+
+	issues = Lint(`version: "0.1"
+name: test
+global_timeout: 600
+tasks:
+  - name: "test"
+    worker: "{{ .device_1 "
+    actions:
+    - name: "test"
+      image: test
+      timeout: 60
+`)
+	if assert.Len(t, issues, 1) {
+		assert.Equal(t, Issue{Severity: SeverityError, Line: 6, Message: "unterminated quoted string"}, issues[0])
+	}
+
+	assert.Len(t, Lint(""), 1)
+}
@@ -0,0 +1,40 @@
+package workflow
+
+import (
+	"regexp"
+)
+
+var (
+	// outputRef matches a reference to the output of a previous action,
+	// e.g. {{ outputs.partition.root_uuid }}
+	outputRef = regexp.MustCompile(`{{\s*outputs\.([a-zA-Z0-9_-]+)\.([a-zA-Z0-9_-]+)\s*}}`)
+
+	outputName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+)
+
+// EscapeOutputRefs rewrites the output references in a template so that they
+// are left untouched when the template is rendered against the hardware data.
+// They are resolved later, when the action is dispatched to a worker.
+func EscapeOutputRefs(data string) string {
+	return outputRef.ReplaceAllStringFunc(data, func(ref string) string {
+		return "{{`" + ref + "`}}"
+	})
+}
+
+// ResolveOutputRefs replaces the output references in s with the values
+// reported by previous actions. References to outputs which are not
+// available yet are kept as they are.
+func ResolveOutputRefs(s string, outputs map[string]map[string]string) string {
+	return outputRef.ReplaceAllStringFunc(s, func(ref string) string {
+		match := outputRef.FindStringSubmatch(ref)
+		if val, ok := outputs[match[1]][match[2]]; ok {
+			return val
+		}
+		return ref
+	})
+}
+
+// IsValidOutputName checks if name can be used as the name of an action output
+func IsValidOutputName(name string) bool {
+	return outputName.MatchString(name)
+}
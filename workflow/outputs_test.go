@@ -0,0 +1,96 @@
+package workflow
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscapeOutputRefs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{
+			name:     "no output references",
+			data:     `worker: "{{.device_1}}"`,
+			expected: `worker: "08:00:27:00:00:01"`,
+		},
+		{
+			name:     "single output reference",
+			data:     `ROOT_UUID: "{{ outputs.partition.root_uuid }}"`,
+			expected: `ROOT_UUID: "{{ outputs.partition.root_uuid }}"`,
+		},
+		{
+			name:     "output reference without spaces and hyphenated action",
+			data:     `ROOT_UUID: "{{outputs.disk-partition.root_uuid}}"`,
+			expected: `ROOT_UUID: "{{outputs.disk-partition.root_uuid}}"`,
+		},
+		{
+			name:     "output reference mixed with hardware data",
+			data:     `worker: "{{.device_1}}" ROOT_UUID: "{{ outputs.partition.root_uuid }}"`,
+			expected: `worker: "08:00:27:00:00:01" ROOT_UUID: "{{ outputs.partition.root_uuid }}"`,
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			tmpl, err := template.New("test").Parse(EscapeOutputRefs(test.data))
+			assert.NoError(t, err)
+
+			buf := new(bytes.Buffer)
+			err = tmpl.Execute(buf, map[string]string{"device_1": "08:00:27:00:00:01"})
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, buf.String())
+		})
+	}
+}
+
+func TestResolveOutputRefs(t *testing.T) {
+	outputs := map[string]map[string]string{
+		"partition": {
+			"root_uuid": "0f5d8a5c-55f5-4ab0-8d48-6b2b1e1a5ef6",
+		},
+	}
+	testCases := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{
+			name:     "no output references",
+			value:    "MIRROR_HOST=192.168.1.2",
+			expected: "MIRROR_HOST=192.168.1.2",
+		},
+		{
+			name:     "available output",
+			value:    "ROOT_UUID={{ outputs.partition.root_uuid }}",
+			expected: "ROOT_UUID=0f5d8a5c-55f5-4ab0-8d48-6b2b1e1a5ef6",
+		},
+		{
+			name:     "output not reported yet",
+			value:    "BOOT_UUID={{ outputs.partition.boot_uuid }}",
+			expected: "BOOT_UUID={{ outputs.partition.boot_uuid }}",
+		},
+		{
+			name:     "action not executed yet",
+			value:    "KERNEL={{ outputs.install-kernel.version }}",
+			expected: "KERNEL={{ outputs.install-kernel.version }}",
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, ResolveOutputRefs(test.value, outputs))
+		})
+	}
+}
+
+func TestIsValidOutputName(t *testing.T) {
+	assert.True(t, IsValidOutputName("root_uuid"))
+	assert.True(t, IsValidOutputName("root-uuid"))
+	assert.False(t, IsValidOutputName(""))
+	assert.False(t, IsValidOutputName("root.uuid"))
+	assert.False(t, IsValidOutputName("../data"))
+}
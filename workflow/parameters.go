@@ -0,0 +1,198 @@
+package workflow
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Types of the template parameters
+const (
+	ParameterTypeString = "string"
+	ParameterTypeInt    = "int"
+	ParameterTypeBool   = "bool"
+	ParameterTypeEnum   = "enum"
+)
+
+const (
+	errParameterDuplicateName = "two parameters in a template cannot have same name: %s"
+	errParameterInvalidType   = "invalid type of parameter %s: %s"
+	errParameterNoValues      = "enum parameter %s must list its values"
+	errParameterDefault       = "invalid default of parameter %s"
+	errParameterUnknown       = "unknown parameter: %s"
+	errParameterUndeclared    = "template uses an undeclared parameter: %s"
+	errParameterRequired      = "missing required parameter: %s"
+	errParameterValue         = "invalid value of parameter %s"
+)
+
+// Parameter is a typed value given to a template when a workflow is created,
+// used in the template with {{ param "name" }}
+type Parameter struct {
+	Name        string   `yaml:"name"`
+	Type        string   `yaml:"type,omitempty"` // string when empty
+	Description string   `yaml:"description,omitempty"`
+	Default     *string  `yaml:"default,omitempty"`
+	Required    bool     `yaml:"required,omitempty"`
+	Values      []string `yaml:"values,omitempty"` // allowed values of an enum
+}
+
+// Parse converts the string value of the parameter to its type, an int64 for
+// int parameters, a bool for bool parameters and a string otherwise
+func (p Parameter) Parse(value string) (interface{}, error) {
+	switch p.Type {
+	case "", ParameterTypeString:
+		return value, nil
+	case ParameterTypeInt:
+		return strconv.ParseInt(value, 10, 64)
+	case ParameterTypeBool:
+		return strconv.ParseBool(value)
+	case ParameterTypeEnum:
+		for _, v := range p.Values {
+			if v == value {
+				return value, nil
+			}
+		}
+		return nil, errors.Errorf("%q is not one of %s", value, strings.Join(p.Values, ", "))
+	}
+	return nil, errors.Errorf(errParameterInvalidType, p.Name, p.Type)
+}
+
+// zero returns the value of an optional parameter without default which is
+// not given
+func (p Parameter) zero() interface{} {
+	switch p.Type {
+	case ParameterTypeInt:
+		return int64(0)
+	case ParameterTypeBool:
+		return false
+	}
+	return ""
+}
+
+// topLevelField matches the first line of a top-level field of a template,
+// or of a {{ }} action ending it
+var topLevelField = regexp.MustCompile(`^[^\s#-]`)
+
+// TemplateParameters returns the parameters declared by a template. Only the
+// top-level parameters field is decoded: the template is not rendered yet
+// and its {{ }} actions may make the rest of it invalid YAML.
+func TemplateParameters(data string) ([]Parameter, error) {
+	var declared struct {
+		Parameters []Parameter `yaml:"parameters"`
+	}
+	if err := yaml.Unmarshal([]byte(parametersField(data)), &declared); err != nil {
+		return nil, errors.Wrap(err, "parsing yaml data")
+	}
+	if err := validateParameters(declared.Parameters); err != nil {
+		return nil, err
+	}
+	return declared.Parameters, nil
+}
+
+// parametersField returns the lines of the top-level parameters field of a
+// template
+func parametersField(data string) string {
+	var lines []string
+	in := false
+	for _, line := range strings.Split(data, "\n") {
+		if topLevelField.MatchString(line) {
+			in = strings.HasPrefix(line, "parameters:")
+		}
+		if in {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ResolveParameters checks the values given to the parameters of a template
+// and returns them converted to their types, the parameters which are not
+// given take their default value
+func ResolveParameters(declared []Parameter, values map[string]string) (map[string]interface{}, error) {
+	known := map[string]struct{}{}
+	for _, p := range declared {
+		known[p.Name] = struct{}{}
+	}
+	var unknown []string
+	for name := range values {
+		if _, ok := known[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, errors.Errorf(errParameterUnknown, strings.Join(unknown, ", "))
+	}
+
+	resolved := map[string]interface{}{}
+	for _, p := range declared {
+		value, ok := values[p.Name]
+		switch {
+		case ok:
+		case p.Default != nil:
+			value = *p.Default
+		case p.Required:
+			return nil, errors.Errorf(errParameterRequired, p.Name)
+		default:
+			resolved[p.Name] = p.zero()
+			continue
+		}
+		v, err := p.Parse(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, errParameterValue, p.Name)
+		}
+		resolved[p.Name] = v
+	}
+	return resolved, nil
+}
+
+// validateParameters validates the parameter declarations of a template
+func validateParameters(params []Parameter) error {
+	names := make(map[string]struct{})
+	for _, p := range params {
+		if hasEmptyName(p.Name) {
+			return errors.New(errEmptyName)
+		}
+		if !hasValidLength(p.Name) {
+			return errors.Errorf(errInvalidLength, p.Name)
+		}
+		if _, ok := names[p.Name]; ok {
+			return errors.Errorf(errParameterDuplicateName, p.Name)
+		}
+		names[p.Name] = struct{}{}
+
+		switch p.Type {
+		case "", ParameterTypeString, ParameterTypeInt, ParameterTypeBool:
+		case ParameterTypeEnum:
+			if len(p.Values) == 0 {
+				return errors.Errorf(errParameterNoValues, p.Name)
+			}
+		default:
+			return errors.Errorf(errParameterInvalidType, p.Name, p.Type)
+		}
+		if p.Default != nil {
+			if _, err := p.Parse(*p.Default); err != nil {
+				return errors.Wrapf(err, errParameterDefault, p.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// ParseParameterValues parses the key=value pairs given on a command line to
+// the parameters of a template
+func ParseParameterValues(pairs []string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, pair := range pairs {
+		i := strings.Index(pair, "=")
+		if i <= 0 {
+			return nil, errors.Errorf("invalid parameter %q, expected key=value", pair)
+		}
+		values[pair[:i]] = pair[i+1:]
+	}
+	return values, nil
+}
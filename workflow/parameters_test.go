@@ -0,0 +1,120 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const parametersTemplate = `
+version: "0.1"
+name: install
+global_timeout: 600
+parameters:
+  - name: disk
+    required: true
+  - name: partitions
+    type: int
+    default: 2
+  - name: wipe
+    type: bool
+  - name: fs
+    type: enum
+    values: [ext4, xfs]
+    default: ext4
+tasks:
+  - name: "install"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "install"
+      image: install
+      environment:
+        DISK: '{{ param "disk" }}'
+        PARTITIONS: '{{ param "partitions" }}'
+        FS: '{{ param "fs" }}'
+        WIPE: '{{ if param "wipe" }}yes{{ else }}no{{ end }}'
+`
+
+func TestRenderTemplateParameters(t *testing.T) {
+	hardware := []byte(`{"device_1": "08:00:27:00:00:01"}`)
+	render := func(params map[string]string) (map[string]string, error) {
+		rendered, err := RenderTemplate("install", parametersTemplate, hardware, params)
+		if err != nil {
+			return nil, err
+		}
+		wf, err := Parse([]byte(rendered))
+		if err != nil {
+			return nil, err
+		}
+		return wf.Tasks[0].Actions[0].Environment, nil
+	}
+
+	env, err := render(map[string]string{"disk": "/dev/sda"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"DISK": "/dev/sda", "PARTITIONS": "2", "FS": "ext4", "WIPE": "no"}, env)
+
+	env, err = render(map[string]string{"disk": "/dev/sdb", "partitions": "3", "wipe": "true", "fs": "xfs"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"DISK": "/dev/sdb", "PARTITIONS": "3", "FS": "xfs", "WIPE": "yes"}, env)
+
+	for name, params := range map[string]map[string]string{
+		"missing required": {},
+		"unknown":          {"disk": "/dev/sda", "size": "10"},
+		"invalid int":      {"disk": "/dev/sda", "partitions": "two"},
+		"invalid bool":     {"disk": "/dev/sda", "wipe": "maybe"},
+		"invalid enum":     {"disk": "/dev/sda", "fs": "btrfs"},
+	} {
+		_, err := render(params)
+		assert.Error(t, err, name)
+	}
+
+	_, err = RenderTemplate("install", `worker: '{{ param "disk" }}'`, hardware, nil)
+	assert.Error(t, err)
+}
+
+func TestRenderTemplateControlBlocks(t *testing.T) {
+	const data = `
+version: "0.1"
+name: install
+global_timeout: 600
+parameters:
+  - name: wipe
+    type: bool
+tasks:
+  - name: "install"
+    worker: "{{.device_1}}"
+    actions:
+{{- if param "wipe" }}
+    - name: "wipe"
+      image: wipe
+{{- end }}
+    - name: "install"
+      image: install
+`
+	hardware := []byte(`{"device_1": "08:00:27:00:00:01"}`)
+	for wipe, actions := range map[string]int{"true": 2, "false": 1} {
+		rendered, err := RenderTemplate("install", data, hardware, map[string]string{"wipe": wipe})
+		if !assert.NoError(t, err, wipe) {
+			continue
+		}
+		wf, err := Parse([]byte(rendered))
+		if assert.NoError(t, err, wipe) {
+			assert.Len(t, wf.Tasks[0].Actions, actions, wipe)
+		}
+	}
+
+	declared, err := TemplateParameters(data)
+	assert.NoError(t, err)
+	assert.Equal(t, []Parameter{{Name: "wipe", Type: ParameterTypeBool}}, declared)
+}
+
+func TestParseParameterValues(t *testing.T) {
+	values, err := ParseParameterValues([]string{"disk=/dev/sda", "cmdline=console=ttyS0", "empty="})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"disk": "/dev/sda", "cmdline": "console=ttyS0", "empty": ""}, values)
+
+	_, err = ParseParameterValues([]string{"disk"})
+	assert.Error(t, err)
+	_, err = ParseParameterValues([]string{"=/dev/sda"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,202 @@
+package workflow
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Versions of the template format
+const (
+	Version01 = "0.1"
+	// Version02 adds the descriptions of the templates, tasks and actions,
+	// the labels of the templates, and names the on_timeout and on_failure
+	// fields of the actions like the other fields
+	Version02 = "0.2"
+
+	// CurrentVersion is the version the templates are converted to when read
+	CurrentVersion = Version02
+)
+
+const errSchemaDowngrade = "cannot convert a template from version %s to %s"
+
+// schema describes a version of the template format
+type schema struct {
+	version string
+	// format returns the structure holding a template of this version, to
+	// reject the fields unknown to the version
+	format func() interface{}
+	// upgrade converts the root node of a template of this version to the
+	// next version, it is nil for the current version
+	upgrade    func(*yamlv3.Node)
+	jsonSchema string
+}
+
+// schemas are the supported versions of the template format, from the oldest
+var schemas = []schema{
+	{
+		version:    Version01,
+		format:     func() interface{} { return &workflowV01{} },
+		upgrade:    upgrade01,
+		jsonSchema: jsonSchemaV01,
+	},
+	{
+		version:    Version02,
+		format:     func() interface{} { return &Workflow{} },
+		jsonSchema: jsonSchemaV02,
+	},
+}
+
+// SchemaVersions returns the supported versions of the template format, from
+// the oldest
+func SchemaVersions() []string {
+	versions := make([]string, len(schemas))
+	for i, s := range schemas {
+		versions[i] = s.version
+	}
+	return versions
+}
+
+// JSONSchema returns the JSON Schema of a version of the template format
+func JSONSchema(version string) ([]byte, error) {
+	i := schemaIndex(version)
+	if i < 0 {
+		return nil, errors.Errorf(errTemplateInvalidVersion, version)
+	}
+	return []byte(schemas[i].jsonSchema), nil
+}
+
+// Convert converts a template to a newer version of the template format. The
+// comments, the layout and the {{ }} actions of the template are kept, only
+// the fields which changed between the versions are rewritten. The template is
+// returned as it is when it already has the version.
+func Convert(data []byte, version string) ([]byte, error) {
+	from, err := templateVersion(data)
+	if err != nil {
+		return nil, err
+	}
+	fi, ti := schemaIndex(from), schemaIndex(version)
+	if fi < 0 {
+		return nil, errors.Errorf(errTemplateInvalidVersion, from)
+	}
+	if ti < 0 {
+		return nil, errors.Errorf(errTemplateInvalidVersion, version)
+	}
+	if ti < fi {
+		return nil, errors.Errorf(errSchemaDowngrade, from, version)
+	}
+	if err := yaml.UnmarshalStrict(data, schemas[fi].format()); err != nil {
+		return nil, errors.Wrap(err, "parsing yaml data")
+	}
+	if fi == ti {
+		return data, nil
+	}
+
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrap(err, "parsing yaml data")
+	}
+	for _, s := range schemas[fi:ti] {
+		s.upgrade(doc.Content[0])
+	}
+
+	var buf bytes.Buffer
+	enc := yamlv3.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decode reads a template of any supported version into a Workflow of the
+// current version
+func decode(data []byte) (*Workflow, error) {
+	converted, err := Convert(data, CurrentVersion)
+	if err != nil {
+		return nil, err
+	}
+	var wf Workflow
+	if err := yaml.UnmarshalStrict(converted, &wf); err != nil {
+		return nil, errors.Wrap(err, "parsing yaml data")
+	}
+	return &wf, nil
+}
+
+// templateVersion returns the version of the template format of a template
+func templateVersion(data []byte) (string, error) {
+	var v struct {
+		Version string `yaml:"version"`
+	}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return "", errors.Wrap(err, "parsing yaml data")
+	}
+	return v.Version, nil
+}
+
+func schemaIndex(version string) int {
+	for i, s := range schemas {
+		if s.version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+func isSupportedVersion(version string) bool {
+	return schemaIndex(version) >= 0
+}
+
+// upgrade01 converts a template from the version 0.1 to 0.2
+func upgrade01(root *yamlv3.Node) {
+	setValue(root, "version", Version02)
+	for _, task := range sequenceItems(mappingValue(root, "tasks")) {
+		for _, action := range sequenceItems(mappingValue(task, "actions")) {
+			renameKey(action, "on-timeout", "on_timeout")
+			renameKey(action, "on-failure", "on_failure")
+		}
+	}
+}
+
+// mappingValue returns the value of a key of a mapping node, nil when n is not
+// a mapping or does not have the key
+func mappingValue(n *yamlv3.Node, key string) *yamlv3.Node {
+	if n == nil || n.Kind != yamlv3.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func sequenceItems(n *yamlv3.Node) []*yamlv3.Node {
+	if n == nil || n.Kind != yamlv3.SequenceNode {
+		return nil
+	}
+	return n.Content
+}
+
+func setValue(n *yamlv3.Node, key, value string) {
+	if v := mappingValue(n, key); v != nil {
+		v.Kind, v.Tag, v.Value = yamlv3.ScalarNode, "!!str", value
+	}
+}
+
+func renameKey(n *yamlv3.Node, from, to string) {
+	if n == nil || n.Kind != yamlv3.MappingNode {
+		return
+	}
+	for i := 0; i < len(n.Content); i += 2 {
+		if n.Content[i].Value == from {
+			n.Content[i].Value = to
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package workflow
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	templateV01 = `version: "0.1"
+name: install
+global_timeout: 600
+parameters:
+  - name: disk
+    default: /dev/sda
+tasks:
+  # the worker is given when the workflow is created
+  - name: "install"
+    worker: "{{.device_1}}"
+    actions:
+      - name: "disk-wipe"
+        image: disk-wipe
+        timeout: 90
+        environment:
+          DISK: '{{ param "disk" }}'
+        on-timeout: ["echo", "timeout"]
+        on-failure: ["echo", "failure"]
+`
+
+	templateV02 = `version: "0.2"
+name: install
+description: installs the operating system
+labels:
+  os: ubuntu
+global_timeout: 600
+tasks:
+  - name: "install"
+    description: runs on the machine being provisioned
+    worker: "08:00:27:00:00:01"
+    actions:
+      - name: "disk-wipe"
+        description: wipes the disk
+        image: disk-wipe
+        timeout: 90
+        on_failure: ["echo", "failure"]
+`
+)
+
+func TestConvert(t *testing.T) {
+	converted, err := Convert([]byte(templateV01), Version02)
+	require.NoError(t, err)
+
+	// only the changed fields are rewritten
+	assert.Contains(t, string(converted), `version: "0.2"`)
+	assert.Contains(t, string(converted), "# the worker is given when the workflow is created")
+	assert.Contains(t, string(converted), `DISK: '{{ param "disk" }}'`)
+	assert.Contains(t, string(converted), `on_timeout: ["echo", "timeout"]`)
+	assert.NotContains(t, string(converted), "on-failure")
+
+	original, err := RenderTemplate("id", templateV01, []byte(`{"device_1": "08:00:27:00:00:01"}`), nil)
+	require.NoError(t, err)
+	rendered, err := RenderTemplate("id", string(converted), []byte(`{"device_1": "08:00:27:00:00:01"}`), nil)
+	require.NoError(t, err)
+	wf, err := Parse([]byte(original))
+	require.NoError(t, err)
+	convertedWf, err := Parse([]byte(rendered))
+	require.NoError(t, err)
+	assert.Equal(t, wf, convertedWf)
+
+	// the templates of the current version are kept as they are
+	same, err := Convert(converted, Version02)
+	require.NoError(t, err)
+	assert.Equal(t, converted, same)
+}
+
+func TestConvertErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		data    string
+		version string
+		err     string
+	}{
+		{
+			name:    "downgrade",
+			data:    templateV02,
+			version: Version01,
+			err:     "cannot convert a template from version 0.2 to 0.1",
+		},
+		{
+			name:    "unknown version",
+			data:    templateV01,
+			version: "1.0",
+			err:     "invalid template version: 1.0",
+		},
+		{
+			name:    "unknown template version",
+			data:    strings.Replace(templateV01, `"0.1"`, `"0.3"`, 1),
+			version: Version02,
+			err:     "invalid template version: 0.3",
+		},
+		{
+			name:    "field of a later version",
+			data:    strings.Replace(templateV02, `"0.2"`, `"0.1"`, 1),
+			version: Version02,
+			err:     "field description not found",
+		},
+		{
+			name:    "field of an earlier version",
+			data:    strings.Replace(templateV01, `"0.1"`, `"0.2"`, 1),
+			version: Version02,
+			err:     "field on-timeout not found",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Convert([]byte(test.data), test.version)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+}
+
+func TestParseVersions(t *testing.T) {
+	wf, err := Parse([]byte(templateV02))
+	require.NoError(t, err)
+	assert.Equal(t, Version02, wf.Version)
+	assert.Equal(t, "installs the operating system", wf.Description)
+	assert.Equal(t, map[string]string{"os": "ubuntu"}, wf.Labels)
+	assert.Equal(t, "wipes the disk", wf.Tasks[0].Actions[0].Description)
+	assert.Equal(t, []string{"echo", "failure"}, wf.Tasks[0].Actions[0].OnFailure)
+
+	// the templates of the older versions are converted when read
+	wf, err = Parse([]byte(validTemplate))
+	require.NoError(t, err)
+	assert.Equal(t, CurrentVersion, wf.Version)
+}
+
+func TestLintVersions(t *testing.T) {
+	assert.Empty(t, Lint(templateV02))
+
+	issues := Lint(strings.Replace(templateV02, "on_failure", "on-failure", 1))
+	require.Len(t, issues, 1)
+	assert.Equal(t, "unknown field on-failure", issues[0].Message)
+}
+
+func TestJSONSchemas(t *testing.T) {
+	formats := map[string][]interface{}{
+		Version01: {workflowV01{}, taskV01{}, actionV01{}},
+		Version02: {Workflow{}, Task{}, Action{}},
+	}
+	assert.Equal(t, []string{Version01, Version02}, SchemaVersions())
+
+	for _, version := range SchemaVersions() {
+		data, err := JSONSchema(version)
+		require.NoError(t, err)
+
+		var schema struct {
+			Properties  map[string]json.RawMessage
+			Definitions map[string]struct {
+				Properties map[string]json.RawMessage
+			}
+		}
+		require.NoError(t, json.Unmarshal(data, &schema), version)
+
+		// the schema describes the fields of the format
+		properties := []map[string]json.RawMessage{
+			schema.Properties,
+			schema.Definitions["task"].Properties,
+			schema.Definitions["action"].Properties,
+		}
+		for i, format := range formats[version] {
+			assert.Equal(t, yamlFields(format), keys(properties[i]), version)
+		}
+		assert.Equal(t, yamlFields(Parameter{}), keys(schema.Definitions["parameter"].Properties), version)
+	}
+
+	_, err := JSONSchema("0.3")
+	assert.Error(t, err)
+}
+
+func yamlFields(v interface{}) []string {
+	var names []string
+	typ := reflect.TypeOf(v)
+	for i := 0; i < typ.NumField(); i++ {
+		names = append(names, strings.Split(typ.Field(i).Tag.Get("yaml"), ",")[0])
+	}
+	sort.Strings(names)
+	return names
+}
+
+func keys(m map[string]json.RawMessage) []string {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,70 @@
+package workflow
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Redacted replaces the values of the secrets in the output of the actions
+const Redacted = "******"
+
+var (
+	// secretRef matches a reference to a secret, e.g. {{ secret "ipmi-password" }}
+	secretRef = regexp.MustCompile(`{{\s*secret\s+"([a-zA-Z0-9_.-]+)"\s*}}`)
+
+	secretName = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+)
+
+// EscapeSecretRefs rewrites the secret references in a template so that they
+// are left untouched when the template is rendered against the hardware data.
+// They are resolved when the action is dispatched to its worker, so that the
+// values of the secrets are never stored in the workflows.
+func EscapeSecretRefs(data string) string {
+	return secretRef.ReplaceAllStringFunc(data, func(ref string) string {
+		return "{{`" + ref + "`}}"
+	})
+}
+
+// EscapeRefs escapes both the output and the secret references of a template
+func EscapeRefs(data string) string {
+	return EscapeSecretRefs(EscapeOutputRefs(data))
+}
+
+// SecretRefs returns the names of the secrets referenced in s
+func SecretRefs(s string) []string {
+	var names []string
+	for _, match := range secretRef.FindAllStringSubmatch(s, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// ResolveSecretRefs replaces the secret references in s with their values
+func ResolveSecretRefs(s string, secrets map[string]string) string {
+	return secretRef.ReplaceAllStringFunc(s, func(ref string) string {
+		match := secretRef.FindStringSubmatch(ref)
+		if val, ok := secrets[match[1]]; ok {
+			return val
+		}
+		return ref
+	})
+}
+
+// RedactSecrets replaces the values of the given secrets found in s
+func RedactSecrets(s string, values []string) string {
+	// redact the longest values first, in case a value contains another one
+	sorted := append([]string(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, v := range sorted {
+		if v != "" {
+			s = strings.Replace(s, v, Redacted, -1)
+		}
+	}
+	return s
+}
+
+// IsValidSecretName checks if name can be used as the name of a secret
+func IsValidSecretName(name string) bool {
+	return secretName.MatchString(name) && len(name) <= 200
+}
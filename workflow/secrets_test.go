@@ -0,0 +1,70 @@
+package workflow
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscapeSecretRefs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{
+			name:     "no secret references",
+			data:     `worker: "{{.device_1}}"`,
+			expected: `worker: "08:00:27:00:00:01"`,
+		},
+		{
+			name:     "secret reference",
+			data:     `IPMI_PASSWORD: '{{ secret "ipmi-password" }}'`,
+			expected: `IPMI_PASSWORD: '{{ secret "ipmi-password" }}'`,
+		},
+		{
+			name:     "secret and output references mixed with hardware data",
+			data:     `worker: "{{.device_1}}" PASSWORD: '{{secret "root.password"}}' ROOT_UUID: "{{ outputs.partition.root_uuid }}"`,
+			expected: `worker: "08:00:27:00:00:01" PASSWORD: '{{secret "root.password"}}' ROOT_UUID: "{{ outputs.partition.root_uuid }}"`,
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			tmpl, err := template.New("test").Parse(EscapeRefs(test.data))
+			assert.NoError(t, err)
+
+			buf := new(bytes.Buffer)
+			err = tmpl.Execute(buf, map[string]string{"device_1": "08:00:27:00:00:01"})
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, buf.String())
+		})
+	}
+}
+
+func TestSecretRefs(t *testing.T) {
+	assert.Empty(t, SecretRefs("MIRROR_HOST=192.168.1.2"))
+	assert.Equal(t, []string{"ipmi-user", "ipmi-password"},
+		SecretRefs(`IPMI={{ secret "ipmi-user" }}:{{ secret "ipmi-password" }}`))
+}
+
+func TestResolveSecretRefs(t *testing.T) {
+	secrets := map[string]string{"ipmi-password": "s3cr3t"}
+	assert.Equal(t, "IPMI_PASSWORD=s3cr3t", ResolveSecretRefs(`IPMI_PASSWORD={{ secret "ipmi-password" }}`, secrets))
+	assert.Equal(t, `TOKEN={{ secret "token" }}`, ResolveSecretRefs(`TOKEN={{ secret "token" }}`, secrets))
+}
+
+func TestRedactSecrets(t *testing.T) {
+	assert.Equal(t, "login with admin:******", RedactSecrets("login with admin:s3cr3t", []string{"s3cr3t"}))
+	assert.Equal(t, "token ******", RedactSecrets("token abcdef", []string{"abc", "abcdef"}))
+	assert.Equal(t, "nothing to hide", RedactSecrets("nothing to hide", []string{""}))
+}
+
+func TestIsValidSecretName(t *testing.T) {
+	assert.True(t, IsValidSecretName("ipmi-password"))
+	assert.True(t, IsValidSecretName("root.password_1"))
+	assert.False(t, IsValidSecretName(""))
+	assert.False(t, IsValidSecretName("ipmi password"))
+	assert.False(t, IsValidSecretName(`ipmi"password`))
+}
@@ -0,0 +1,117 @@
+package workflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"text/template"
+
+	"github.com/pkg/errors"
+	pb "github.com/tinkerbell/tink/protos/workflow"
+)
+
+const errTemplateParsing = "failed to parse template with ID: %s"
+
+// RenderTemplate renders the template data against the hardware, a JSON
+// object mapping the device references of the template to the workers, and
+// the values of the parameters declared by the template. The output and
+// secret references are kept as they are.
+func RenderTemplate(templateID, data string, hardware []byte, params map[string]string) (string, error) {
+	var devices map[string]interface{}
+	err := json.Unmarshal(hardware, &devices)
+	if err != nil {
+		return "", errors.Wrapf(err, errTemplateParsing, templateID)
+	}
+
+	declared, err := TemplateParameters(data)
+	if err != nil {
+		return "", errors.Wrapf(err, errTemplateParsing, templateID)
+	}
+	values, err := ResolveParameters(declared, params)
+	if err != nil {
+		return "", errors.Wrapf(err, errTemplateParsing, templateID)
+	}
+
+	t := template.New("workflow-template").Funcs(template.FuncMap{
+		"param": func(name string) (interface{}, error) {
+			v, ok := values[name]
+			if !ok {
+				return nil, errors.Errorf(errParameterUndeclared, name)
+			}
+			return v, nil
+		},
+	})
+	_, err = t.Parse(EscapeRefs(data))
+	if err != nil {
+		return "", errors.Wrapf(err, errTemplateParsing, templateID)
+	}
+
+	buf := new(bytes.Buffer)
+	err = t.Execute(buf, devices)
+	if err != nil {
+		return "", errors.Wrapf(err, errTemplateParsing, templateID)
+	}
+	return buf.String(), nil
+}
+
+// TaskActions returns the actions of a task executed by the given worker,
+// with the environment and volumes of the task merged into every action
+func TaskActions(task Task, workerID string) []*pb.WorkflowAction {
+	taskVolumes := mergeVolumes(nil, task.Volumes)
+
+	var actions []*pb.WorkflowAction
+	for _, ac := range task.Actions {
+		acenvs := map[string]string{}
+		for key, val := range task.Environment {
+			acenvs[key] = val
+		}
+		for key, val := range ac.Environment {
+			acenvs[key] = val
+		}
+
+		envs := []string{}
+		for key, val := range acenvs {
+			envs = append(envs, key+"="+val)
+		}
+
+		volumes := mergeVolumes(taskVolumes, ac.Volumes)
+		acVolumes := []string{}
+		for _, v := range volumes {
+			acVolumes = append(acVolumes, v)
+		}
+
+		actions = append(actions, &pb.WorkflowAction{
+			TaskName:    task.Name,
+			WorkerId:    workerID,
+			Name:        ac.Name,
+			Image:       ac.Image,
+			Timeout:     ac.Timeout,
+			Command:     ac.Command,
+			OnTimeout:   ac.OnTimeout,
+			OnFailure:   ac.OnFailure,
+			Environment: envs,
+			Volumes:     acVolumes,
+			Reboot:      ac.Reboot,
+			Type:        ac.Type,
+		})
+	}
+	return actions
+}
+
+// mergeVolumes adds the given volume specs to a copy of volumes, keyed by
+// their source, a spec replacing the volume with the same source
+func mergeVolumes(volumes map[string]string, specs []string) map[string]string {
+	merged := map[string]string{}
+	for k, v := range volumes {
+		merged[k] = v
+	}
+	for _, spec := range specs {
+		v, err := ParseVolume(spec)
+		if err != nil {
+			// kept as it is, the volumes are validated when parsing a workflow
+			merged[spec] = spec
+			continue
+		}
+		merged[v.Source] = v.String()
+	}
+	return merged
+}
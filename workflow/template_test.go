@@ -0,0 +1,51 @@
+package workflow
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	const data = `
+version: "0.1"
+name: install
+global_timeout: 600
+tasks:
+  - name: "install"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "install"
+      image: install
+      environment:
+        ROOT_UUID: "{{ outputs.partition.root_uuid }}"
+        PASSWORD: '{{ secret "root-password" }}'
+`
+	rendered, err := RenderTemplate("install", data, []byte(`{"device_1": "08:00:27:00:00:01"}`), nil)
+	assert.NoError(t, err)
+	wf, err := Parse([]byte(rendered))
+	assert.NoError(t, err)
+	assert.Equal(t, "08:00:27:00:00:01", wf.Tasks[0].WorkerAddr)
+	assert.Equal(t, "{{ outputs.partition.root_uuid }}", wf.Tasks[0].Actions[0].Environment["ROOT_UUID"])
+	assert.Equal(t, `{{ secret "root-password" }}`, wf.Tasks[0].Actions[0].Environment["PASSWORD"])
+
+	_, err = RenderTemplate("install", data, []byte(`not json`), nil)
+	assert.Error(t, err)
+	_, err = RenderTemplate("install", "{{ .device_1 ", []byte(`{}`), nil)
+	assert.Error(t, err)
+}
+
+func TestTaskActions(t *testing.T) {
+	wf := workflow()
+	actions := TaskActions(wf.Tasks[0], "worker")
+	assert.Len(t, actions, 4)
+	for _, action := range actions {
+		assert.Equal(t, "pre-installation", action.GetTaskName())
+		assert.Equal(t, "worker", action.GetWorkerId())
+		assert.Equal(t, []string{"MIRROR_HOST=192.168.1.2"}, action.GetEnvironment())
+	}
+	volumes := actions[1].GetVolumes()
+	sort.Strings(volumes)
+	assert.Equal(t, []string{"/dev/console:/dev/console", "/dev:/dev", "/lib/firmware:/lib/firmware:ro", "/statedir:/statedir"}, volumes)
+}
@@ -3,7 +3,6 @@ package workflow
 import (
 	"github.com/docker/distribution/reference"
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v2"
 )
 
 const (
@@ -12,23 +11,26 @@ const (
 	errTemplateInvalidVersion = "invalid template version: %s"
 	errTaskDuplicateName      = "two tasks in a template cannot have same name: %s"
 	errActionDuplicateName    = "two actions in a task cannot have same name: %s"
+	errActionOutputsName      = "two actions in a template using outputs cannot have same name: %s"
+	errRebootActionOutputs    = "outputs of reboot action %s cannot be used, it reports no outputs"
 	errActionInvalidImage     = "invalid action image: %s"
+	errActionInvalidType      = "invalid action type: %s"
+	errApprovalWithImage      = "approval action cannot run an image: %s"
 )
 
-// Parse parses the template yaml content into a Workflow
+// Parse parses the template yaml content into a Workflow, the templates of
+// the older versions of the format are converted to the current version
 func Parse(yamlContent []byte) (*Workflow, error) {
-	var workflow Workflow
-
-	err := yaml.UnmarshalStrict(yamlContent, &workflow)
+	workflow, err := decode(yamlContent)
 	if err != nil {
-		return &Workflow{}, errors.Wrap(err, "parsing yaml data")
+		return &Workflow{}, err
 	}
 
-	if err = validate(&workflow); err != nil {
+	if err = validate(workflow); err != nil {
 		return &Workflow{}, errors.Wrap(err, "validating workflow template")
 	}
 
-	return &workflow, nil
+	return workflow, nil
 }
 
 // validate validates a workflow template against certain requirements
@@ -40,16 +42,30 @@ func validate(wf *Workflow) error {
 		return errors.Errorf(errInvalidLength, wf.Name)
 	}
 
-	if wf.Version != "0.1" {
+	if !isSupportedVersion(wf.Version) {
 		return errors.Errorf(errTemplateInvalidVersion, wf.Version)
 	}
 
+	if err := validateParameters(wf.Parameters); err != nil {
+		return err
+	}
+
 	if len(wf.Tasks) == 0 {
 		return errors.New("template must have at least one task defined")
 	}
 
+	if err := validateOutputActions(wf.Tasks); err != nil {
+		return err
+	}
+
 	taskNameMap := make(map[string]struct{})
 	for _, task := range wf.Tasks {
+		if task.Include != "" {
+			if err := validateInclude(task); err != nil {
+				return err
+			}
+			continue
+		}
 		if hasEmptyName(task.Name) {
 			return errors.New(errEmptyName)
 		}
@@ -63,6 +79,9 @@ func validate(wf *Workflow) error {
 		}
 
 		taskNameMap[task.Name] = struct{}{}
+		if err := validateVolumes(task.Volumes); err != nil {
+			return err
+		}
 		actionNameMap := make(map[string]struct{})
 		for _, action := range task.Actions {
 			if hasEmptyName(action.Name) {
@@ -73,8 +92,21 @@ func validate(wf *Workflow) error {
 				return errors.Errorf(errInvalidLength, action.Name)
 			}
 
-			if !hasValidImageName(action.Image) {
-				return errors.Errorf(errActionInvalidImage, action.Image)
+			switch action.Type {
+			case "":
+				if !hasValidImageName(action.Image) {
+					return errors.Errorf(errActionInvalidImage, action.Image)
+				}
+			case ActionTypeApproval:
+				if action.Image != "" || len(action.Command) > 0 || action.Reboot {
+					return errors.Errorf(errApprovalWithImage, action.Name)
+				}
+			default:
+				return errors.Errorf(errActionInvalidType, action.Type)
+			}
+
+			if err := validateVolumes(action.Volumes); err != nil {
+				return err
 			}
 
 			_, ok := actionNameMap[action.Name]
@@ -87,6 +119,57 @@ func validate(wf *Workflow) error {
 	return nil
 }
 
+// validateOutputActions checks that the actions have unique names across the
+// tasks when the template references outputs, as the references only name the
+// action, and that no reference names a reboot action
+func validateOutputActions(tasks []Task) error {
+	if !usesOutputs(tasks) {
+		return nil
+	}
+	taskOf := map[string]string{}
+	reboots := map[string]struct{}{}
+	for _, task := range tasks {
+		for _, action := range task.Actions {
+			if other, ok := taskOf[action.Name]; ok && other != task.Name {
+				return errors.Errorf(errActionOutputsName, action.Name)
+			}
+			taskOf[action.Name] = task.Name
+			if action.Reboot {
+				reboots[action.Name] = struct{}{}
+			}
+		}
+	}
+	// the success of a reboot action is reported by tink-server once the
+	// worker boots again, without the outputs of the action
+	for _, task := range tasks {
+		for _, action := range task.Actions {
+			for _, value := range action.Environment {
+				for _, m := range outputRef.FindAllStringSubmatch(value, -1) {
+					if _, ok := reboots[m[1]]; ok {
+						return errors.Errorf(errRebootActionOutputs, m[1])
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// usesOutputs checks if an action of the tasks references the outputs of
+// another action
+func usesOutputs(tasks []Task) bool {
+	for _, task := range tasks {
+		for _, action := range task.Actions {
+			for _, value := range action.Environment {
+				if outputRef.MatchString(value) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
 func hasEmptyName(name string) bool {
 	return name == ""
 }
@@ -20,6 +20,38 @@ tasks:
       timeout: 60
 `
 
+	validTemplateWithReboot = `
+version: "0.1"
+name: kexec_workflow
+global_timeout: 600
+tasks:
+  - name: "install"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "kexec"
+      image: kexec
+      timeout: 90
+      reboot: true
+`
+
+	validTemplateWithApproval = `
+version: "0.1"
+name: wipe_workflow
+global_timeout: 600
+tasks:
+  - name: "wipe"
+    worker: "{{.device_1}}"
+    actions:
+    - name: "inventory"
+      image: inventory
+      timeout: 90
+    - name: "review-inventory"
+      type: approval
+    - name: "disk-wipe"
+      image: disk-wipe
+      timeout: 600
+`
+
 	invalidTemplate = `
 version: "0.1"
 name: hello_world_workflow
@@ -46,6 +78,14 @@ func TestParse(t *testing.T) {
 			name:    "valid template",
 			content: []byte(validTemplate),
 		},
+		{
+			name:    "valid template with reboot action",
+			content: []byte(validTemplateWithReboot),
+		},
+		{
+			name:    "valid template with approval action",
+			content: []byte(validTemplateWithApproval),
+		},
 		{
 			name:          "invalid template",
 			content:       []byte(invalidTemplate),
@@ -133,6 +173,81 @@ func TestValidateTemplate(t *testing.T) {
 			wf:            workflow(withActionInvalidImage()),
 			expectedError: true,
 		},
+		{
+			name:          "action type is invalid",
+			wf:            workflow(withActionInvalidType()),
+			expectedError: true,
+		},
+		{
+			name:          "approval action runs an image",
+			wf:            workflow(withApprovalImage()),
+			expectedError: true,
+		},
+		{
+			name: "approval action",
+			wf:   workflow(withApproval()),
+		},
+		{
+			name:          "task volume is invalid",
+			wf:            workflow(withTaskInvalidVolume()),
+			expectedError: true,
+		},
+		{
+			name:          "action volume is invalid",
+			wf:            workflow(withActionInvalidVolume()),
+			expectedError: true,
+		},
+		{
+			name: "parameters",
+			wf:   workflow(withParameters()),
+		},
+		{
+			name:          "parameter type is invalid",
+			wf:            workflow(withParameters(), withParameterInvalidType()),
+			expectedError: true,
+		},
+		{
+			name:          "parameter name is duplicated",
+			wf:            workflow(withParameters(), withParameterDuplicateName()),
+			expectedError: true,
+		},
+		{
+			name:          "enum parameter has no values",
+			wf:            workflow(withParameters(), withEnumWithoutValues()),
+			expectedError: true,
+		},
+		{
+			name:          "parameter default is invalid",
+			wf:            workflow(withParameters(), withParameterInvalidDefault()),
+			expectedError: true,
+		},
+		{
+			name: "task includes a template",
+			wf:   workflow(withInclude()),
+		},
+		{
+			name:          "task includes a template and defines actions",
+			wf:            workflow(withIncludeActions()),
+			expectedError: true,
+		},
+		{
+			name: "actions of two tasks have same name",
+			wf:   workflow(withSecondTask()),
+		},
+		{
+			name: "action uses an output",
+			wf:   workflow(withOutputRef()),
+		},
+		{
+			name:          "actions of two tasks using outputs have same name",
+			wf:            workflow(withSecondTask(), withOutputRef()),
+			expectedError: true,
+		},
+		{
+			name:          "action uses an output of a reboot action",
+			wf:            workflow(withOutputRef(), withRebootAction()),
+			expectedError: true,
+		},
 		{
 			name: "valid task name",
 			wf:   workflow(),
@@ -223,6 +338,38 @@ func withTaskDuplicateName() workflowModifier {
 	return func(wf *Workflow) { wf.Tasks = append(wf.Tasks, wf.Tasks[0]) }
 }
 
+func withTaskInvalidVolume() workflowModifier {
+	return func(wf *Workflow) { wf.Tasks[0].Volumes = append(wf.Tasks[0].Volumes, "/dev") }
+}
+
+func withInclude() workflowModifier {
+	return func(wf *Workflow) { wf.Tasks = append(wf.Tasks, Task{Include: "common-disk-wipe"}) }
+}
+
+func withIncludeActions() workflowModifier {
+	return func(wf *Workflow) { wf.Tasks[0].Include = "common-disk-wipe" }
+}
+
+func withSecondTask() workflowModifier {
+	return func(wf *Workflow) {
+		wf.Tasks = append(wf.Tasks, Task{
+			Name:       "post-installation",
+			WorkerAddr: "08:00:27:00:00:02",
+			Actions:    []Action{{Name: "disk-wipe", Image: "disk-wipe", Timeout: 90}},
+		})
+	}
+}
+
+func withOutputRef() workflowModifier {
+	return func(wf *Workflow) {
+		wf.Tasks[0].Actions[1].Environment = map[string]string{"DISK": "{{ outputs.disk-wipe.disk }}"}
+	}
+}
+
+func withRebootAction() workflowModifier {
+	return func(wf *Workflow) { wf.Tasks[0].Actions[0].Reboot = true }
+}
+
 // invalid action modifiers
 
 func withActionInvalidName() workflowModifier {
@@ -243,6 +390,57 @@ func withActionInvalidImage() workflowModifier {
 	return func(wf *Workflow) { wf.Tasks[0].Actions[0].Image = "action-image-with-$#@-" }
 }
 
+func withActionInvalidType() workflowModifier {
+	return func(wf *Workflow) { wf.Tasks[0].Actions[0].Type = "manual" }
+}
+
+func withApprovalImage() workflowModifier {
+	return func(wf *Workflow) { wf.Tasks[0].Actions[0].Type = ActionTypeApproval }
+}
+
+func withApproval() workflowModifier {
+	return func(wf *Workflow) {
+		wf.Tasks[0].Actions[0] = Action{Name: "review", Type: ActionTypeApproval}
+	}
+}
+
+func withActionInvalidVolume() workflowModifier {
+	return func(wf *Workflow) { wf.Tasks[0].Actions[1].Volumes = []string{"statedir:statedir"} }
+}
+
+// parameter modifiers
+
+func withParameters() workflowModifier {
+	return func(wf *Workflow) {
+		size := "10"
+		wf.Parameters = []Parameter{
+			{Name: "disk", Required: true},
+			{Name: "size", Type: ParameterTypeInt, Default: &size},
+			{Name: "wipe", Type: ParameterTypeBool},
+			{Name: "fs", Type: ParameterTypeEnum, Values: []string{"ext4", "xfs"}},
+		}
+	}
+}
+
+func withParameterInvalidType() workflowModifier {
+	return func(wf *Workflow) { wf.Parameters[0].Type = "float" }
+}
+
+func withParameterDuplicateName() workflowModifier {
+	return func(wf *Workflow) { wf.Parameters = append(wf.Parameters, wf.Parameters[0]) }
+}
+
+func withEnumWithoutValues() workflowModifier {
+	return func(wf *Workflow) { wf.Parameters[3].Values = nil }
+}
+
+func withParameterInvalidDefault() workflowModifier {
+	return func(wf *Workflow) {
+		size := "ten"
+		wf.Parameters[1].Default = &size
+	}
+}
+
 // invalid template modifiers
 
 func withTemplateInvalidName() workflowModifier {
@@ -257,7 +455,7 @@ func withTemplateLongName() workflowModifier {
 
 func withTemplateInvalidVersion() workflowModifier {
 	return func(wf *Workflow) {
-		wf.Version = "0.2"
+		wf.Version = "0.3"
 	}
 }
 
@@ -1,25 +1,69 @@
 package workflow
 
-// Workflow represents a workflow to be executed
+// Workflow represents a workflow to be executed, in the current version of
+// the template format
 type Workflow struct {
-	Version       string `yaml:"version"`
-	Name          string `yaml:"name"`
-	ID            string `yaml:"id"`
-	GlobalTimeout int    `yaml:"global_timeout"`
-	Tasks         []Task `yaml:"tasks"`
+	Version       string            `yaml:"version"`
+	Name          string            `yaml:"name"`
+	ID            string            `yaml:"id"`
+	Description   string            `yaml:"description,omitempty"`
+	Labels        map[string]string `yaml:"labels,omitempty"`
+	GlobalTimeout int               `yaml:"global_timeout"`
+	Parameters    []Parameter       `yaml:"parameters,omitempty"`
+	Tasks         []Task            `yaml:"tasks"`
 }
 
 // Task represents a task to be executed as part of a workflow
 type Task struct {
 	Name        string            `yaml:"name"`
+	Description string            `yaml:"description,omitempty"`
 	WorkerAddr  string            `yaml:"worker"`
 	Actions     []Action          `yaml:"actions"`
 	Volumes     []string          `yaml:"volumes"`
 	Environment map[string]string `yaml:"environment"`
+	Include     string            `yaml:"include,omitempty"` // name or id of a template whose tasks replace this one
 }
 
 // Action is the basic executional unit for a workflow
 type Action struct {
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description,omitempty"`
+	Image       string            `yaml:"image"`
+	Timeout     int64             `yaml:"timeout"`
+	Command     []string          `yaml:"command"`
+	OnTimeout   []string          `yaml:"on_timeout"`
+	OnFailure   []string          `yaml:"on_failure"`
+	Volumes     []string          `yaml:"volumes,omitempty"`
+	Environment map[string]string `yaml:"environment,omitempty"`
+	Reboot      bool              `yaml:"reboot,omitempty"`
+	Type        string            `yaml:"type,omitempty"` // empty for the actions running an image
+}
+
+// ActionTypeApproval is the type of the actions which do not run anything,
+// but block the workflow until it is approved
+const ActionTypeApproval = "approval"
+
+// workflowV01 is a workflow in the version 0.1 of the template format, only
+// used to reject the fields unknown to this version before converting it
+type workflowV01 struct {
+	Version       string      `yaml:"version"`
+	Name          string      `yaml:"name"`
+	ID            string      `yaml:"id"`
+	GlobalTimeout int         `yaml:"global_timeout"`
+	Parameters    []Parameter `yaml:"parameters,omitempty"`
+	Tasks         []taskV01   `yaml:"tasks"`
+}
+
+type taskV01 struct {
+	Name        string            `yaml:"name"`
+	WorkerAddr  string            `yaml:"worker"`
+	Actions     []actionV01       `yaml:"actions"`
+	Volumes     []string          `yaml:"volumes"`
+	Environment map[string]string `yaml:"environment"`
+	Include     string            `yaml:"include,omitempty"`
+}
+
+type actionV01 struct {
 	Name        string            `yaml:"name"`
 	Image       string            `yaml:"image"`
 	Timeout     int64             `yaml:"timeout"`
@@ -28,4 +72,6 @@ type Action struct {
 	OnFailure   []string          `yaml:"on-failure"`
 	Volumes     []string          `yaml:"volumes,omitempty"`
 	Environment map[string]string `yaml:"environment,omitempty"`
+	Reboot      bool              `yaml:"reboot,omitempty"`
+	Type        string            `yaml:"type,omitempty"`
 }
@@ -0,0 +1,219 @@
+package workflow
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	errInvalidVolume       = "invalid volume %s: %s"
+	errVolumeNotAllowed    = "volume %s is not allowed by the volume policy"
+	errVolumeMustBeRO      = "volume %s must be mounted read-only"
+	errInvalidVolumePolicy = "invalid volume policy rule %s"
+)
+
+var (
+	// volumeName matches the name of a Docker named volume
+	volumeName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+	// volumeOptions are the options Docker accepts for a bind mount
+	volumeOptions = map[string]bool{
+		"ro":         true,
+		"rw":         true,
+		"z":          true,
+		"Z":          true,
+		"shared":     true,
+		"rshared":    true,
+		"slave":      true,
+		"rslave":     true,
+		"private":    true,
+		"rprivate":   true,
+		"nocopy":     true,
+		"consistent": true,
+		"cached":     true,
+		"delegated":  true,
+	}
+)
+
+// Volume is a volume mounted into an action container, in the format of
+// the Docker binds: source:target[:options]
+type Volume struct {
+	// Source is an absolute path on the host or the name of a volume
+	Source string
+	// Target is the absolute path the volume is mounted at in the container
+	Target string
+	// Options are the comma separated mount options, e.g. ro
+	Options []string
+}
+
+// ParseVolume parses a volume spec
+func ParseVolume(spec string) (Volume, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Volume{}, errors.Errorf(errInvalidVolume, spec, "expected source:target[:options]")
+	}
+	v := Volume{Source: parts[0], Target: parts[1]}
+	if !path.IsAbs(v.Source) && !volumeName.MatchString(v.Source) {
+		return Volume{}, errors.Errorf(errInvalidVolume, spec, "the source must be an absolute path or a volume name")
+	}
+	if !path.IsAbs(v.Target) {
+		return Volume{}, errors.Errorf(errInvalidVolume, spec, "the target must be an absolute path")
+	}
+	if len(parts) == 3 {
+		v.Options = strings.Split(parts[2], ",")
+		mode := ""
+		for _, opt := range v.Options {
+			if !volumeOptions[opt] {
+				return Volume{}, errors.Errorf(errInvalidVolume, spec, "unknown option "+opt)
+			}
+			if opt == "ro" || opt == "rw" {
+				if mode != "" {
+					return Volume{}, errors.Errorf(errInvalidVolume, spec, "more than one mode")
+				}
+				mode = opt
+			}
+		}
+	}
+	return v, nil
+}
+
+// String returns the spec of the volume
+func (v Volume) String() string {
+	if len(v.Options) == 0 {
+		return v.Source + ":" + v.Target
+	}
+	return v.Source + ":" + v.Target + ":" + strings.Join(v.Options, ",")
+}
+
+// ReadOnly checks if the volume is mounted read-only
+func (v Volume) ReadOnly() bool {
+	for _, opt := range v.Options {
+		if opt == "ro" {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBindMount checks if the source of the volume is a path on the host
+func (v Volume) IsBindMount() bool {
+	return path.IsAbs(v.Source)
+}
+
+// VolumePolicy restricts the volumes the actions of a template can mount
+type VolumePolicy struct {
+	rules []volumeRule
+}
+
+// volumeRule allows a host path and everything below it, or a named volume
+type volumeRule struct {
+	source   string
+	readOnly bool
+}
+
+// ParseVolumePolicy parses a comma separated list of rules, each one being a
+// host path or a volume name optionally followed by :ro, when the volume
+// must be mounted read-only, or :rw. For example
+// "/dev,/lib/firmware:ro,/statedir" allows /dev and /statedir, and
+// /lib/firmware read-only.
+func ParseVolumePolicy(s string) (*VolumePolicy, error) {
+	p := &VolumePolicy{}
+	for _, spec := range strings.Split(s, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		parts := strings.Split(spec, ":")
+		r := volumeRule{source: parts[0]}
+		if len(parts) > 2 || (len(parts) == 2 && parts[1] != "ro" && parts[1] != "rw") {
+			return nil, errors.Errorf(errInvalidVolumePolicy, spec)
+		}
+		if len(parts) == 2 {
+			r.readOnly = parts[1] == "ro"
+		}
+		if path.IsAbs(r.source) {
+			r.source = path.Clean(r.source)
+		} else if !volumeName.MatchString(r.source) {
+			return nil, errors.Errorf(errInvalidVolumePolicy, spec)
+		}
+		p.rules = append(p.rules, r)
+	}
+	return p, nil
+}
+
+// CheckVolume checks a volume against the policy. A nil policy allows every
+// volume.
+func (p *VolumePolicy) CheckVolume(v Volume) error {
+	if p == nil {
+		return nil
+	}
+	source := v.Source
+	if v.IsBindMount() {
+		source = path.Clean(source)
+	}
+	allowed := false
+	for _, r := range p.rules {
+		if !r.matches(source) {
+			continue
+		}
+		if !r.readOnly || v.ReadOnly() {
+			return nil
+		}
+		allowed = true
+	}
+	if allowed {
+		return errors.Errorf(errVolumeMustBeRO, v)
+	}
+	return errors.Errorf(errVolumeNotAllowed, v)
+}
+
+// Check checks all the volumes of the tasks and actions of a workflow
+// against the policy
+func (p *VolumePolicy) Check(wf *Workflow) error {
+	if p == nil {
+		return nil
+	}
+	check := func(specs []string) error {
+		for _, spec := range specs {
+			v, err := ParseVolume(spec)
+			if err != nil {
+				return err
+			}
+			if err := p.CheckVolume(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, task := range wf.Tasks {
+		if err := check(task.Volumes); err != nil {
+			return err
+		}
+		for _, action := range task.Actions {
+			if err := check(action.Volumes); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r volumeRule) matches(source string) bool {
+	if !path.IsAbs(r.source) {
+		return source == r.source
+	}
+	return source == r.source || r.source == "/" || strings.HasPrefix(source, r.source+"/")
+}
+
+// validateVolumes checks the format of the given volume specs
+func validateVolumes(specs []string) error {
+	for _, spec := range specs {
+		if _, err := ParseVolume(spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
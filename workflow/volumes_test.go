@@ -0,0 +1,82 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVolume(t *testing.T) {
+	testCases := map[string]struct {
+		spec          string
+		volume        Volume
+		expectedError bool
+	}{
+		"bind mount":           {spec: "/dev:/dev", volume: Volume{Source: "/dev", Target: "/dev"}},
+		"read-only bind mount": {spec: "/lib/firmware:/lib/firmware:ro", volume: Volume{Source: "/lib/firmware", Target: "/lib/firmware", Options: []string{"ro"}}},
+		"named volume":         {spec: "statedir:/statedir:rw,z", volume: Volume{Source: "statedir", Target: "/statedir", Options: []string{"rw", "z"}}},
+		"missing target":       {spec: "/dev", expectedError: true},
+		"empty source":         {spec: ":/dev", expectedError: true},
+		"relative source":      {spec: "./dev:/dev", expectedError: true},
+		"relative target":      {spec: "/dev:dev", expectedError: true},
+		"unknown option":       {spec: "/dev:/dev:rx", expectedError: true},
+		"two modes":            {spec: "/dev:/dev:ro,rw", expectedError: true},
+		"too many parts":       {spec: "/dev:/dev:ro:rw", expectedError: true},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			v, err := ParseVolume(tc.spec)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.volume, v)
+			assert.Equal(t, tc.spec, v.String())
+		})
+	}
+}
+
+func TestVolumePolicy(t *testing.T) {
+	p, err := ParseVolumePolicy("/dev, /lib/firmware:ro, /statedir:rw, statedir")
+	assert.NoError(t, err)
+
+	testCases := map[string]struct {
+		spec          string
+		expectedError bool
+	}{
+		"allowed path":               {spec: "/dev:/dev"},
+		"allowed sub path":           {spec: "/dev/console:/dev/console"},
+		"read-only path":             {spec: "/lib/firmware:/lib/firmware:ro"},
+		"read-only path mounted rw":  {spec: "/lib/firmware:/lib/firmware", expectedError: true},
+		"allowed named volume":       {spec: "statedir:/statedir"},
+		"other named volume":         {spec: "data:/data", expectedError: true},
+		"other path":                 {spec: "/etc:/etc", expectedError: true},
+		"path sharing a prefix":      {spec: "/devices:/devices", expectedError: true},
+		"path escaping allowed path": {spec: "/dev/../etc:/etc", expectedError: true},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			v, err := ParseVolume(tc.spec)
+			assert.NoError(t, err)
+			err = p.CheckVolume(v)
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	wf := workflow()
+	assert.NoError(t, p.Check(wf))
+	wf.Tasks[0].Actions[0].Volumes = []string{"/:/host"}
+	assert.EqualError(t, p.Check(wf), "volume /:/host is not allowed by the volume policy")
+	var nilPolicy *VolumePolicy
+	assert.NoError(t, nilPolicy.Check(wf))
+
+	for _, policy := range []string{"/dev:rx", "/dev:ro:rw", "./dev"} {
+		_, err := ParseVolumePolicy(policy)
+		assert.Error(t, err, policy)
+	}
+}